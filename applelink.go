@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"ciderbot/types"
+	"context"
 	"crypto/ecdsa"
 	"crypto/x509"
 	"encoding/json"
@@ -9,23 +11,40 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func applelinkRequest(credentials *types.AppleCredentials, requestURL string, httpMethod string) ([]byte, error) {
+func applelinkRequest(ctx context.Context, credentials *types.AppleCredentials, requestURL string, httpMethod string) ([]byte, error) {
+	return applelinkRequestWithBody(ctx, credentials, requestURL, httpMethod, nil)
+}
+
+func applelinkRequestWithBody(ctx context.Context, credentials *types.AppleCredentials, requestURL string, httpMethod string, payload interface{}) ([]byte, error) {
 	var response []byte
-	req, err := http.NewRequest(httpMethod, requestURL, nil)
+	var requestBody io.Reader
+	if payload != nil {
+		encodedPayload, err := json.Marshal(payload)
+		if err != nil {
+			slog.ErrorContext(ctx, "applelink: could not encode request body", "error", err)
+			return response, err
+		}
+		requestBody = bytes.NewReader(encodedPayload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, httpMethod, requestURL, requestBody)
 	if err != nil {
-		fmt.Printf("applelink: could not create request: %s\n", err)
+		slog.ErrorContext(ctx, "applelink: could not create request", "error", err)
 		return response, err
 	}
 
 	storeToken, err := getAppStoreToken(credentials)
 	if err != nil {
-		fmt.Printf("applelink: could not create store token: %s\n", err)
+		slog.ErrorContext(ctx, "applelink: could not create store token", "error", err)
 		return response, err
 	}
 
@@ -35,21 +54,24 @@ func applelinkRequest(credentials *types.AppleCredentials, requestURL string, ht
 	req.Header.Set("X-AppStoreConnect-Issuer-Id", credentials.IssuerID)
 	req.Header.Set("X-AppStoreConnect-Token", storeToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	startedAt := time.Now()
+	resp, err := applelinkClient.Do(req)
 
 	if err != nil {
-		fmt.Printf("applelink: request failed: %s\n", err)
+		observeApplelinkRequest(httpMethod, requestURL, 0, startedAt)
+		slog.ErrorContext(ctx, "applelink: request failed", "error", err)
 		return response, err
 	}
+	defer resp.Body.Close()
+	observeApplelinkRequest(httpMethod, requestURL, resp.StatusCode, startedAt)
 
 	if resp.StatusCode > 299 {
-		return response, fmt.Errorf("applelink: request failed with status - %d", resp.StatusCode)
+		return response, statusError{service: "applelink", statusCode: resp.StatusCode}
 	}
 
-	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Printf("applelink: could not read response body: %s\n", err)
+		slog.ErrorContext(ctx, "applelink: could not read response body", "error", err)
 		return body, err
 	}
 
@@ -57,120 +79,404 @@ func applelinkRequest(credentials *types.AppleCredentials, requestURL string, ht
 
 }
 
-func getAppMetadata(credentials *types.AppleCredentials) (types.AppMetadata, error) {
+func getAppMetadata(ctx context.Context, credentials *types.AppleCredentials) (types.AppMetadata, error) {
 	appMetadata := types.AppMetadata{}
 
 	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s", applelinkHost, credentials.BundleID)
-	body, err := applelinkRequest(credentials, requestURL, http.MethodGet)
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodGet)
+	if err != nil {
+		return appMetadata, err
+	}
 
 	err = json.Unmarshal(body, &appMetadata)
 	if err != nil {
-		fmt.Printf("applelink: could not parse reponse body: %s\n", err)
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
 		return appMetadata, err
 	}
 
 	return appMetadata, err
 }
 
-func getAppCurrentStatus(credentials *types.AppleCredentials) ([]types.AppCurrentStatus, error) {
+func getAppCurrentStatus(ctx context.Context, credentials *types.AppleCredentials) ([]types.AppCurrentStatus, error) {
 	var appCurrentStatuses []types.AppCurrentStatus
 
 	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/current_status", applelinkHost, credentials.BundleID)
 
-	body, err := applelinkRequest(credentials, requestURL, http.MethodGet)
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodGet)
+	if err != nil {
+		return appCurrentStatuses, err
+	}
+
 	err = json.Unmarshal(body, &appCurrentStatuses)
 	if err != nil {
-		fmt.Printf("applelink: could not parse reponse body: %s\n", err)
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
 		return appCurrentStatuses, err
 	}
 
 	return appCurrentStatuses, err
 }
 
-func getBetaGroups(credentials *types.AppleCredentials) ([]types.BetaGroup, error) {
+func getBetaGroups(ctx context.Context, credentials *types.AppleCredentials) ([]types.BetaGroup, error) {
 	var betaGroups []types.BetaGroup
 
 	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/groups", applelinkHost, credentials.BundleID)
 
-	body, err := applelinkRequest(credentials, requestURL, http.MethodGet)
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodGet)
+	if err != nil {
+		return betaGroups, err
+	}
+
 	err = json.Unmarshal(body, &betaGroups)
 	if err != nil {
-		fmt.Printf("applelink: could not parse reponse body: %s\n", err)
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
 		return betaGroups, err
 	}
 
 	return betaGroups, err
 }
 
-func getInflightRelease(credentials *types.AppleCredentials) (types.Release, error) {
+func getBuilds(ctx context.Context, credentials *types.AppleCredentials, versionString string, limit int) ([]types.Build, error) {
+	var builds []types.Build
+
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	if versionString != "" {
+		query.Set("version_string", versionString)
+	}
+
+	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/builds?%s", applelinkHost, credentials.BundleID, query.Encode())
+
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodGet)
+	if err != nil {
+		return builds, err
+	}
+
+	err = json.Unmarshal(body, &builds)
+	if err != nil {
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
+		return builds, err
+	}
+
+	return builds, err
+}
+
+func getBuild(ctx context.Context, credentials *types.AppleCredentials, buildNumber string) (types.Build, error) {
+	var build types.Build
+
+	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/builds/%s", applelinkHost, credentials.BundleID, url.PathEscape(buildNumber))
+
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodGet)
+	if err != nil {
+		return build, err
+	}
+
+	err = json.Unmarshal(body, &build)
+	if err != nil {
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
+		return build, err
+	}
+
+	return build, err
+}
+
+func getCustomerReviews(ctx context.Context, credentials *types.AppleCredentials, territory string, limit int) ([]types.CustomerReview, error) {
+	var customerReviews []types.CustomerReview
+
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	if territory != "" {
+		query.Set("territory", territory)
+	}
+
+	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/customer_reviews?%s", applelinkHost, credentials.BundleID, query.Encode())
+
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodGet)
+	if err != nil {
+		return customerReviews, err
+	}
+
+	err = json.Unmarshal(body, &customerReviews)
+	if err != nil {
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
+		return customerReviews, err
+	}
+
+	return customerReviews, err
+}
+
+func replyToCustomerReview(ctx context.Context, credentials *types.AppleCredentials, reviewID string, responseBody string) (types.CustomerReviewResponse, error) {
+	var reviewResponse types.CustomerReviewResponse
+
+	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/customer_reviews/%s/response", applelinkHost, credentials.BundleID, url.PathEscape(reviewID))
+	payload := map[string]string{"response_body": responseBody}
+
+	body, err := applelinkRequestWithBody(ctx, credentials, requestURL, http.MethodPost, payload)
+	if err != nil {
+		return reviewResponse, err
+	}
+
+	err = json.Unmarshal(body, &reviewResponse)
+	if err != nil {
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
+		return reviewResponse, err
+	}
+
+	return reviewResponse, err
+}
+
+func getInflightRelease(ctx context.Context, credentials *types.AppleCredentials) (types.Release, error) {
+	var inflightRelease types.Release
+
+	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/release", applelinkHost, credentials.BundleID)
+
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodGet)
+	if err != nil {
+		return inflightRelease, err
+	}
+
+	err = json.Unmarshal(body, &inflightRelease)
+	if err != nil {
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
+		return inflightRelease, err
+	}
+
+	return inflightRelease, err
+}
+
+func getInflightLocalizations(ctx context.Context, credentials *types.AppleCredentials) ([]types.Localization, error) {
+	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/release/localizations", applelinkHost, credentials.BundleID)
+	return getLocalizations(ctx, credentials, requestURL)
+}
+
+func getLiveLocalizations(ctx context.Context, credentials *types.AppleCredentials) ([]types.Localization, error) {
+	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/release/live/localizations", applelinkHost, credentials.BundleID)
+	return getLocalizations(ctx, credentials, requestURL)
+}
+
+func getLocalizations(ctx context.Context, credentials *types.AppleCredentials, requestURL string) ([]types.Localization, error) {
+	var localizations []types.Localization
+
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodGet)
+	if err != nil {
+		return localizations, err
+	}
+
+	err = json.Unmarshal(body, &localizations)
+	if err != nil {
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
+		return localizations, err
+	}
+
+	return localizations, err
+}
+
+func getReviewSubmission(ctx context.Context, credentials *types.AppleCredentials) (types.ReviewSubmission, error) {
+	var reviewSubmission types.ReviewSubmission
+
+	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/release/review", applelinkHost, credentials.BundleID)
+
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodGet)
+	if err != nil {
+		return reviewSubmission, err
+	}
+
+	err = json.Unmarshal(body, &reviewSubmission)
+	if err != nil {
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
+		return reviewSubmission, err
+	}
+
+	return reviewSubmission, err
+}
+
+func getResolutionCenterMessages(ctx context.Context, credentials *types.AppleCredentials) ([]types.ResolutionCenterMessage, error) {
+	var messages []types.ResolutionCenterMessage
+
+	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/release/review/messages", applelinkHost, credentials.BundleID)
+
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodGet)
+	if err != nil {
+		return messages, err
+	}
+
+	err = json.Unmarshal(body, &messages)
+	if err != nil {
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
+		return messages, err
+	}
+
+	return messages, err
+}
+
+func createVersion(ctx context.Context, credentials *types.AppleCredentials, versionString string, releaseType string, earliestReleaseDate *time.Time, phasedRelease bool) (types.Release, error) {
+	var inflightRelease types.Release
+
+	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/release", applelinkHost, credentials.BundleID)
+	payload := map[string]interface{}{
+		"version":           versionString,
+		"release_type":      releaseType,
+		"is_phased_release": phasedRelease,
+	}
+	if earliestReleaseDate != nil {
+		payload["earliest_release_date"] = earliestReleaseDate.UTC().Format(time.RFC3339)
+	}
+
+	body, err := applelinkRequestWithBody(ctx, credentials, requestURL, http.MethodPost, payload)
+	if err != nil {
+		return inflightRelease, err
+	}
+
+	err = json.Unmarshal(body, &inflightRelease)
+	if err != nil {
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
+		return inflightRelease, err
+	}
+
+	return inflightRelease, err
+}
+
+// updateInflightRelease changes the release settings of the inflight version,
+// e.g. the release type, the earliest release date and the phased release.
+func updateInflightRelease(ctx context.Context, credentials *types.AppleCredentials, changes map[string]interface{}) (types.Release, error) {
 	var inflightRelease types.Release
 
 	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/release", applelinkHost, credentials.BundleID)
 
-	body, err := applelinkRequest(credentials, requestURL, http.MethodGet)
+	body, err := applelinkRequestWithBody(ctx, credentials, requestURL, http.MethodPatch, changes)
+	if err != nil {
+		return inflightRelease, err
+	}
+
 	err = json.Unmarshal(body, &inflightRelease)
 	if err != nil {
-		fmt.Printf("applelink: could not parse reponse body: %s\n", err)
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
 		return inflightRelease, err
 	}
 
 	return inflightRelease, err
 }
 
-func getLiveRelease(credentials *types.AppleCredentials) (types.Release, error) {
+func releaseInflightVersion(ctx context.Context, credentials *types.AppleCredentials) (types.Release, error) {
+	var liveRelease types.Release
+
+	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/release/start", applelinkHost, credentials.BundleID)
+
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodPost)
+	if err != nil {
+		return liveRelease, err
+	}
+
+	err = json.Unmarshal(body, &liveRelease)
+	if err != nil {
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
+		return liveRelease, err
+	}
+
+	return liveRelease, err
+}
+
+// getSalesReport downloads the gzipped daily Sales and Trends summary report of the vendor for a day.
+func getSalesReport(ctx context.Context, credentials *types.AppleCredentials, vendorNumber string, reportDate time.Time) ([]byte, error) {
+	params := url.Values{}
+	params.Set("vendor_number", vendorNumber)
+	params.Set("frequency", "DAILY")
+	params.Set("report_date", reportDate.Format("2006-01-02"))
+
+	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/sales_reports?%s", applelinkHost, credentials.BundleID, params.Encode())
+
+	return applelinkRequest(ctx, credentials, requestURL, http.MethodGet)
+}
+
+func attachBuild(ctx context.Context, credentials *types.AppleCredentials, buildID string) (types.Release, error) {
+	var inflightRelease types.Release
+
+	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/release/build", applelinkHost, credentials.BundleID)
+	payload := map[string]string{"build_id": buildID}
+
+	body, err := applelinkRequestWithBody(ctx, credentials, requestURL, http.MethodPatch, payload)
+	if err != nil {
+		return inflightRelease, err
+	}
+
+	err = json.Unmarshal(body, &inflightRelease)
+	if err != nil {
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
+		return inflightRelease, err
+	}
+
+	return inflightRelease, err
+}
+
+func getLiveRelease(ctx context.Context, credentials *types.AppleCredentials) (types.Release, error) {
 	var liveRelease types.Release
 
 	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/release/live", applelinkHost, credentials.BundleID)
 
-	body, err := applelinkRequest(credentials, requestURL, http.MethodGet)
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodGet)
+	if err != nil {
+		return liveRelease, err
+	}
+
 	err = json.Unmarshal(body, &liveRelease)
 	if err != nil {
-		fmt.Printf("applelink: could not parse reponse body: %s\n", err)
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
 		return liveRelease, err
 	}
 
 	return liveRelease, err
 }
 
-func pauseLiveRelease(credentials *types.AppleCredentials) (types.Release, error) {
+func pauseLiveRelease(ctx context.Context, credentials *types.AppleCredentials) (types.Release, error) {
 	var liveRelease types.Release
 
 	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/release/live/rollout/pause", applelinkHost, credentials.BundleID)
 
-	body, err := applelinkRequest(credentials, requestURL, http.MethodPatch)
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodPatch)
+	if err != nil {
+		return liveRelease, err
+	}
+
 	err = json.Unmarshal(body, &liveRelease)
 	if err != nil {
-		fmt.Printf("applelink: could not parse reponse body: %s\n", err)
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
 		return liveRelease, err
 	}
 
 	return liveRelease, err
 }
 
-func resumeLiveRelease(credentials *types.AppleCredentials) (types.Release, error) {
+func resumeLiveRelease(ctx context.Context, credentials *types.AppleCredentials) (types.Release, error) {
 	var liveRelease types.Release
 
 	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/release/live/rollout/resume", applelinkHost, credentials.BundleID)
 
-	body, err := applelinkRequest(credentials, requestURL, http.MethodPatch)
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodPatch)
+	if err != nil {
+		return liveRelease, err
+	}
+
 	err = json.Unmarshal(body, &liveRelease)
 	if err != nil {
-		fmt.Printf("applelink: could not parse reponse body: %s\n", err)
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
 		return liveRelease, err
 	}
 
 	return liveRelease, err
 }
 
-func releaseToAll(credentials *types.AppleCredentials) (types.Release, error) {
+func releaseToAll(ctx context.Context, credentials *types.AppleCredentials) (types.Release, error) {
 	var liveRelease types.Release
 
 	requestURL := fmt.Sprintf("%s/apple/connect/v1/apps/%s/release/live/rollout/complete", applelinkHost, credentials.BundleID)
 
-	body, err := applelinkRequest(credentials, requestURL, http.MethodPatch)
+	body, err := applelinkRequest(ctx, credentials, requestURL, http.MethodPatch)
+	if err != nil {
+		return liveRelease, err
+	}
+
 	err = json.Unmarshal(body, &liveRelease)
 	if err != nil {
-		fmt.Printf("applelink: could not parse reponse body: %s\n", err)
+		slog.ErrorContext(ctx, "applelink: could not parse response body", "error", err)
 		return liveRelease, err
 	}
 
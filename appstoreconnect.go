@@ -0,0 +1,1237 @@
+package main
+
+import (
+	"bytes"
+	"ciderbot/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAppStoreConnectHost = "https://api.appstoreconnect.apple.com"
+	appStoreConnectPageLimit   = 200
+	liveAppStoreState          = "READY_FOR_SALE"
+)
+
+// Versions in these states are no longer being worked on or rolled out.
+var pastAppStoreStates = map[string]bool{
+	"REPLACED_WITH_NEW_VERSION":   true,
+	"REMOVED_FROM_SALE":           true,
+	"DEVELOPER_REMOVED_FROM_SALE": true,
+}
+
+// The attributes of an App Store version that the commands change, by the
+// name the commands use for them.
+var appStoreVersionAttributes = map[string]string{
+	"release_type":          "releaseType",
+	"earliest_release_date": "earliestReleaseDate",
+}
+
+// ascDocument is a JSON:API response of App Store Connect. Data is either a
+// single resource or a list of them, depending on the endpoint.
+type ascDocument struct {
+	Data     json.RawMessage `json:"data"`
+	Included []ascResource   `json:"included"`
+	Links    struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+type ascResource struct {
+	Type          string                     `json:"type"`
+	Id            string                     `json:"id"`
+	Attributes    json.RawMessage            `json:"attributes"`
+	Relationships map[string]ascRelationship `json:"relationships"`
+}
+
+// ascRelationship points at one resource, many resources or none.
+type ascRelationship struct {
+	Data json.RawMessage `json:"data"`
+}
+
+type ascIdentifier struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+}
+
+type ascVersionAttributes struct {
+	VersionString       string     `json:"versionString"`
+	AppStoreState       string     `json:"appStoreState"`
+	ReleaseType         string     `json:"releaseType"`
+	EarliestReleaseDate *time.Time `json:"earliestReleaseDate"`
+	Downloadable        bool       `json:"downloadable"`
+	CreatedDate         time.Time  `json:"createdDate"`
+}
+
+type ascBuildAttributes struct {
+	Version         string    `json:"version"`
+	ProcessingState string    `json:"processingState"`
+	UploadedDate    time.Time `json:"uploadedDate"`
+	ExpirationDate  time.Time `json:"expirationDate"`
+	Expired         bool      `json:"expired"`
+	MinOsVersion    string    `json:"minOsVersion"`
+}
+
+type ascPhasedReleaseAttributes struct {
+	PhasedReleaseState string    `json:"phasedReleaseState"`
+	StartDate          time.Time `json:"startDate"`
+	TotalPauseDuration int       `json:"totalPauseDuration"`
+	CurrentDayNumber   int       `json:"currentDayNumber"`
+}
+
+type ascLocalizationAttributes struct {
+	Description     string `json:"description"`
+	Locale          string `json:"locale"`
+	Keywords        string `json:"keywords"`
+	MarketingUrl    string `json:"marketingUrl"`
+	PromotionalText string `json:"promotionalText"`
+	SupportUrl      string `json:"supportUrl"`
+	WhatsNew        string `json:"whatsNew"`
+}
+
+type ascCustomerReviewAttributes struct {
+	Rating           int       `json:"rating"`
+	Title            string    `json:"title"`
+	Body             string    `json:"body"`
+	ReviewerNickname string    `json:"reviewerNickname"`
+	Territory        string    `json:"territory"`
+	CreatedDate      time.Time `json:"createdDate"`
+}
+
+type ascReviewResponseAttributes struct {
+	ResponseBody     string    `json:"responseBody"`
+	State            string    `json:"state"`
+	LastModifiedDate time.Time `json:"lastModifiedDate"`
+}
+
+// appStoreConnectStore talks to the App Store Connect API directly, signing
+// requests with the key of the user instead of going through applelink.
+type appStoreConnectStore struct {
+	credentials *types.AppleCredentials
+	app         *types.AppMetadata
+}
+
+func newAppStoreConnectStore(credentials *types.AppleCredentials) *appStoreConnectStore {
+	return &appStoreConnectStore{credentials: credentials}
+}
+
+func (store *appStoreConnectStore) Platform() string {
+	return iosPlatform
+}
+
+// AppMetadata looks the app up by its bundle id, the other requests need its id.
+func (store *appStoreConnectStore) AppMetadata(ctx context.Context) (types.AppMetadata, error) {
+	if store.app != nil {
+		return *store.app, nil
+	}
+
+	query := url.Values{}
+	query.Set("filter[bundleId]", store.credentials.BundleID)
+	query.Set("fields[apps]", "name,bundleId,sku")
+
+	apps, _, err := store.getAll(ctx, "/v1/apps", query)
+	if err != nil {
+		return types.AppMetadata{}, err
+	}
+
+	if len(apps) == 0 {
+		return types.AppMetadata{}, fmt.Errorf("appstoreconnect: no app found with the bundle id %s", store.credentials.BundleID)
+	}
+
+	var attributes struct {
+		Name     string `json:"name"`
+		BundleId string `json:"bundleId"`
+		Sku      string `json:"sku"`
+	}
+	if err := decodeAttributes(ctx, apps[0], &attributes); err != nil {
+		return types.AppMetadata{}, err
+	}
+
+	store.app = &types.AppMetadata{
+		Id:       apps[0].Id,
+		Name:     attributes.Name,
+		BundleId: attributes.BundleId,
+		Sku:      attributes.Sku,
+	}
+
+	return *store.app, nil
+}
+
+// CurrentStatus lists the latest build of every beta group, along with the
+// version that is live on the App Store.
+func (store *appStoreConnectStore) CurrentStatus(ctx context.Context) ([]types.AppCurrentStatus, error) {
+	var statuses []types.AppCurrentStatus
+
+	liveRelease, err := store.LiveRelease(ctx)
+	if err == nil {
+		status := types.AppCurrentStatus{Name: "production"}
+		status.Builds = append(status.Builds, currentStatusBuild(liveRelease.BuildId, liveRelease.BuildNumber, liveRelease.AppStoreState, liveRelease.VersionName, liveRelease.PhasedRelease.StartDate))
+		statuses = append(statuses, status)
+	}
+
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return statuses, err
+	}
+
+	query := url.Values{}
+	query.Set("filter[app]", appInfo.Id)
+	query.Set("fields[betaGroups]", "name")
+
+	groups, _, err := store.getAll(ctx, "/v1/betaGroups", query)
+	if err != nil {
+		return statuses, err
+	}
+
+	for _, group := range groups {
+		var groupAttributes struct {
+			Name string `json:"name"`
+		}
+		if err := decodeAttributes(ctx, group, &groupAttributes); err != nil {
+			return statuses, err
+		}
+
+		buildQuery := url.Values{}
+		buildQuery.Set("filter[app]", appInfo.Id)
+		buildQuery.Set("filter[betaGroups]", group.Id)
+		buildQuery.Set("sort", "-uploadedDate")
+		buildQuery.Set("include", "preReleaseVersion")
+		buildQuery.Set("limit", "1")
+
+		builds, included, err := store.getPage(ctx, "/v1/builds", buildQuery)
+		if err != nil {
+			return statuses, err
+		}
+
+		status := types.AppCurrentStatus{Name: groupAttributes.Name}
+		for _, resource := range builds {
+			build, err := toAppStoreConnectBuild(ctx, resource, included)
+			if err != nil {
+				return statuses, err
+			}
+
+			status.Builds = append(status.Builds, currentStatusBuild(build.Id, build.BuildNumber, build.ProcessingState, build.VersionString, build.UploadedDate))
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (store *appStoreConnectStore) BetaGroups(ctx context.Context) ([]types.BetaGroup, error) {
+	var betaGroups []types.BetaGroup
+
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return betaGroups, err
+	}
+
+	query := url.Values{}
+	query.Set("filter[app]", appInfo.Id)
+	query.Set("fields[betaGroups]", "name,isInternalGroup")
+
+	groups, _, err := store.getAll(ctx, "/v1/betaGroups", query)
+	if err != nil {
+		return betaGroups, err
+	}
+
+	for _, group := range groups {
+		var attributes struct {
+			Name            string `json:"name"`
+			IsInternalGroup bool   `json:"isInternalGroup"`
+		}
+		if err := decodeAttributes(ctx, group, &attributes); err != nil {
+			return betaGroups, err
+		}
+
+		betaGroup := types.BetaGroup{Id: group.Id, Name: attributes.Name, Internal: attributes.IsInternalGroup}
+
+		testerQuery := url.Values{}
+		testerQuery.Set("fields[betaTesters]", "firstName,lastName,email")
+
+		testers, _, err := store.getAll(ctx, fmt.Sprintf("/v1/betaGroups/%s/betaTesters", group.Id), testerQuery)
+		if err != nil {
+			return betaGroups, err
+		}
+
+		for _, tester := range testers {
+			var testerAttributes struct {
+				FirstName string `json:"firstName"`
+				LastName  string `json:"lastName"`
+				Email     string `json:"email"`
+			}
+			if err := decodeAttributes(ctx, tester, &testerAttributes); err != nil {
+				return betaGroups, err
+			}
+
+			betaGroup.Testers = append(betaGroup.Testers, struct {
+				Name  string `json:"name"`
+				Email string `json:"email"`
+			}{
+				Name:  strings.TrimSpace(testerAttributes.FirstName + " " + testerAttributes.LastName),
+				Email: testerAttributes.Email,
+			})
+		}
+
+		betaGroups = append(betaGroups, betaGroup)
+	}
+
+	return betaGroups, nil
+}
+
+// InflightRelease is the newest version that is not live yet.
+func (store *appStoreConnectStore) InflightRelease(ctx context.Context) (types.Release, error) {
+	releases, err := store.releases(ctx)
+	if err != nil {
+		return types.Release{}, err
+	}
+
+	for _, release := range releases {
+		if release.AppStoreState != liveAppStoreState && !pastAppStoreStates[release.AppStoreState] {
+			return release, nil
+		}
+	}
+
+	return types.Release{}, fmt.Errorf("appstoreconnect: there is no inflight release")
+}
+
+func (store *appStoreConnectStore) LiveRelease(ctx context.Context) (types.Release, error) {
+	releases, err := store.releases(ctx)
+	if err != nil {
+		return types.Release{}, err
+	}
+
+	for _, release := range releases {
+		if release.AppStoreState == liveAppStoreState {
+			return release, nil
+		}
+	}
+
+	return types.Release{}, fmt.Errorf("appstoreconnect: there is no live release")
+}
+
+func (store *appStoreConnectStore) PauseRollout(ctx context.Context) (types.Release, error) {
+	return store.updatePhasedRelease(ctx, "PAUSED")
+}
+
+func (store *appStoreConnectStore) ResumeRollout(ctx context.Context) (types.Release, error) {
+	return store.updatePhasedRelease(ctx, "ACTIVE")
+}
+
+func (store *appStoreConnectStore) CompleteRollout(ctx context.Context) (types.Release, error) {
+	return store.updatePhasedRelease(ctx, "COMPLETE")
+}
+
+func (store *appStoreConnectStore) updatePhasedRelease(ctx context.Context, state string) (types.Release, error) {
+	liveRelease, err := store.LiveRelease(ctx)
+	if err != nil {
+		return liveRelease, err
+	}
+
+	if liveRelease.PhasedRelease.Id == "" {
+		return liveRelease, fmt.Errorf("appstoreconnect: %s is not a phased release", liveRelease.VersionName)
+	}
+
+	payload := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":       "appStoreVersionPhasedReleases",
+			"id":         liveRelease.PhasedRelease.Id,
+			"attributes": map[string]interface{}{"phasedReleaseState": state},
+		},
+	}
+
+	requestURL := store.resourceURL(fmt.Sprintf("/v1/appStoreVersionPhasedReleases/%s", liveRelease.PhasedRelease.Id), nil)
+	phasedRelease, _, err := store.send(ctx, http.MethodPatch, requestURL, payload)
+	if err != nil {
+		return liveRelease, err
+	}
+
+	if err := applyPhasedRelease(ctx, &liveRelease, phasedRelease); err != nil {
+		return liveRelease, err
+	}
+
+	return liveRelease, nil
+}
+
+// Builds lists the latest builds of the app, newest first, optionally only
+// those of one version.
+func (store *appStoreConnectStore) Builds(ctx context.Context, versionString string, limit int) ([]types.Build, error) {
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("filter[app]", appInfo.Id)
+	query.Set("sort", "-uploadedDate")
+	query.Set("include", "preReleaseVersion,betaGroups,appStoreVersion")
+	query.Set("limit", fmt.Sprint(limit))
+	if versionString != "" {
+		query.Set("filter[preReleaseVersion.version]", versionString)
+	}
+
+	resources, included, err := store.getPage(ctx, "/v1/builds", query)
+	if err != nil {
+		return nil, err
+	}
+
+	var builds []types.Build
+	for _, resource := range resources {
+		build, err := toAppStoreConnectBuild(ctx, resource, included)
+		if err != nil {
+			return nil, err
+		}
+		builds = append(builds, build)
+	}
+
+	return builds, nil
+}
+
+func (store *appStoreConnectStore) Build(ctx context.Context, buildNumber string) (types.Build, error) {
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return types.Build{}, err
+	}
+
+	query := url.Values{}
+	query.Set("filter[app]", appInfo.Id)
+	query.Set("filter[version]", buildNumber)
+	query.Set("include", "preReleaseVersion,betaGroups,appStoreVersion")
+	query.Set("limit", "1")
+
+	resources, included, err := store.getPage(ctx, "/v1/builds", query)
+	if err != nil {
+		return types.Build{}, err
+	}
+
+	if len(resources) == 0 {
+		return types.Build{}, statusError{service: "appstoreconnect", statusCode: http.StatusNotFound}
+	}
+
+	return toAppStoreConnectBuild(ctx, resources[0], included)
+}
+
+// AttachBuild submits a build with the inflight version.
+func (store *appStoreConnectStore) AttachBuild(ctx context.Context, buildID string) (types.Release, error) {
+	inflightRelease, err := store.InflightRelease(ctx)
+	if err != nil {
+		return inflightRelease, err
+	}
+
+	payload := map[string]interface{}{
+		"data": ascIdentifier{Type: "builds", Id: buildID},
+	}
+
+	requestURL := store.resourceURL(fmt.Sprintf("/v1/appStoreVersions/%s/relationships/build", inflightRelease.Id), nil)
+	if _, _, err := store.send(ctx, http.MethodPatch, requestURL, payload); err != nil {
+		return inflightRelease, err
+	}
+
+	return store.InflightRelease(ctx)
+}
+
+func (store *appStoreConnectStore) CreateVersion(ctx context.Context, versionString string, releaseType string, earliestReleaseDate *time.Time, phasedRelease bool) (types.Release, error) {
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return types.Release{}, err
+	}
+
+	attributes := map[string]interface{}{
+		"platform":      "IOS",
+		"versionString": versionString,
+		"releaseType":   releaseType,
+	}
+	if earliestReleaseDate != nil {
+		attributes["earliestReleaseDate"] = earliestReleaseDate.UTC().Format(time.RFC3339)
+	}
+
+	payload := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":       "appStoreVersions",
+			"attributes": attributes,
+			"relationships": map[string]interface{}{
+				"app": map[string]interface{}{"data": ascIdentifier{Type: "apps", Id: appInfo.Id}},
+			},
+		},
+	}
+
+	version, included, err := store.send(ctx, http.MethodPost, store.resourceURL("/v1/appStoreVersions", nil), payload)
+	if err != nil {
+		return types.Release{}, err
+	}
+
+	release, err := toAppStoreConnectRelease(ctx, version, included)
+	if err != nil {
+		return release, err
+	}
+
+	if !phasedRelease {
+		return release, nil
+	}
+
+	err = store.createPhasedRelease(ctx, &release)
+	return release, err
+}
+
+// UpdateInflightRelease changes the release type, the release date and
+// whether the inflight version is released in phases.
+func (store *appStoreConnectStore) UpdateInflightRelease(ctx context.Context, changes map[string]interface{}) (types.Release, error) {
+	inflightRelease, err := store.InflightRelease(ctx)
+	if err != nil {
+		return inflightRelease, err
+	}
+
+	attributes := map[string]interface{}{}
+	for change, value := range changes {
+		if attribute, ok := appStoreVersionAttributes[change]; ok {
+			attributes[attribute] = value
+		}
+	}
+
+	if len(attributes) > 0 {
+		payload := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type":       "appStoreVersions",
+				"id":         inflightRelease.Id,
+				"attributes": attributes,
+			},
+		}
+
+		requestURL := store.resourceURL(fmt.Sprintf("/v1/appStoreVersions/%s", inflightRelease.Id), nil)
+		if _, _, err := store.send(ctx, http.MethodPatch, requestURL, payload); err != nil {
+			return inflightRelease, err
+		}
+	}
+
+	if phased, ok := changes["is_phased_release"].(bool); ok {
+		switch {
+		case phased && inflightRelease.PhasedRelease.Id == "":
+			if err := store.createPhasedRelease(ctx, &inflightRelease); err != nil {
+				return inflightRelease, err
+			}
+		case !phased && inflightRelease.PhasedRelease.Id != "":
+			requestURL := store.resourceURL(fmt.Sprintf("/v1/appStoreVersionPhasedReleases/%s", inflightRelease.PhasedRelease.Id), nil)
+			if _, _, err := store.send(ctx, http.MethodDelete, requestURL, nil); err != nil {
+				return inflightRelease, err
+			}
+		}
+	}
+
+	return store.InflightRelease(ctx)
+}
+
+// ReleaseInflight releases a version that is pending developer release.
+func (store *appStoreConnectStore) ReleaseInflight(ctx context.Context) (types.Release, error) {
+	inflightRelease, err := store.InflightRelease(ctx)
+	if err != nil {
+		return inflightRelease, err
+	}
+
+	payload := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type": "appStoreVersionReleaseRequests",
+			"relationships": map[string]interface{}{
+				"appStoreVersion": map[string]interface{}{"data": ascIdentifier{Type: "appStoreVersions", Id: inflightRelease.Id}},
+			},
+		},
+	}
+
+	if _, _, err := store.send(ctx, http.MethodPost, store.resourceURL("/v1/appStoreVersionReleaseRequests", nil), payload); err != nil {
+		return inflightRelease, err
+	}
+
+	releases, err := store.releases(ctx)
+	if err != nil {
+		return inflightRelease, err
+	}
+
+	for _, release := range releases {
+		if release.Id == inflightRelease.Id {
+			return release, nil
+		}
+	}
+
+	return inflightRelease, nil
+}
+
+func (store *appStoreConnectStore) InflightLocalizations(ctx context.Context) ([]types.Localization, error) {
+	inflightRelease, err := store.InflightRelease(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.localizations(ctx, inflightRelease.Id)
+}
+
+func (store *appStoreConnectStore) LiveLocalizations(ctx context.Context) ([]types.Localization, error) {
+	liveRelease, err := store.LiveRelease(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.localizations(ctx, liveRelease.Id)
+}
+
+func (store *appStoreConnectStore) localizations(ctx context.Context, versionID string) ([]types.Localization, error) {
+	resources, _, err := store.getAll(ctx, fmt.Sprintf("/v1/appStoreVersions/%s/appStoreVersionLocalizations", versionID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var localizations []types.Localization
+	for _, resource := range resources {
+		localization, err := toAppStoreConnectLocalization(ctx, resource)
+		if err != nil {
+			return nil, err
+		}
+		localizations = append(localizations, localization)
+	}
+
+	return localizations, nil
+}
+
+// ReviewSubmission is the latest submission of the app to App Review.
+func (store *appStoreConnectStore) ReviewSubmission(ctx context.Context) (types.ReviewSubmission, error) {
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return types.ReviewSubmission{}, err
+	}
+
+	query := url.Values{}
+	query.Set("filter[app]", appInfo.Id)
+	query.Set("filter[platform]", "IOS")
+	query.Set("include", "items,appStoreVersionForReview")
+
+	resources, included, err := store.getAll(ctx, "/v1/reviewSubmissions", query)
+	if err != nil {
+		return types.ReviewSubmission{}, err
+	}
+
+	var submissions []types.ReviewSubmission
+	for _, resource := range resources {
+		submission, err := toAppStoreConnectReviewSubmission(ctx, resource, included)
+		if err != nil {
+			return types.ReviewSubmission{}, err
+		}
+		submissions = append(submissions, submission)
+	}
+
+	if len(submissions) == 0 {
+		return types.ReviewSubmission{}, statusError{service: "appstoreconnect", statusCode: http.StatusNotFound}
+	}
+
+	sort.SliceStable(submissions, func(i, j int) bool {
+		return submissions[i].SubmittedDate.After(submissions[j].SubmittedDate)
+	})
+
+	return submissions[0], nil
+}
+
+// ResolutionCenterMessages are not part of the App Store Connect API, the
+// review status points to the Resolution Center instead.
+func (store *appStoreConnectStore) ResolutionCenterMessages(ctx context.Context) ([]types.ResolutionCenterMessage, error) {
+	return nil, errNotSupported
+}
+
+func (store *appStoreConnectStore) CustomerReviews(ctx context.Context, territory string, limit int) ([]types.CustomerReview, error) {
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("sort", "-createdDate")
+	query.Set("include", "response")
+	query.Set("limit", fmt.Sprint(limit))
+	if territory != "" {
+		query.Set("filter[territory]", territory)
+	}
+
+	resources, included, err := store.getPage(ctx, fmt.Sprintf("/v1/apps/%s/customerReviews", appInfo.Id), query)
+	if err != nil {
+		return nil, err
+	}
+
+	var reviews []types.CustomerReview
+	for _, resource := range resources {
+		var attributes ascCustomerReviewAttributes
+		if err := decodeAttributes(ctx, resource, &attributes); err != nil {
+			return nil, err
+		}
+
+		review := types.CustomerReview{
+			Id:               resource.Id,
+			Rating:           attributes.Rating,
+			Title:            attributes.Title,
+			Body:             attributes.Body,
+			ReviewerNickname: attributes.ReviewerNickname,
+			Territory:        attributes.Territory,
+			CreatedDate:      attributes.CreatedDate,
+		}
+
+		response, ok, err := includedResource(resource, "response", included)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			reviewResponse, err := toAppStoreConnectReviewResponse(ctx, response)
+			if err != nil {
+				return nil, err
+			}
+			review.Response = &reviewResponse
+		}
+
+		reviews = append(reviews, review)
+	}
+
+	return reviews, nil
+}
+
+func (store *appStoreConnectStore) ReplyToReview(ctx context.Context, reviewID string, responseBody string) (types.CustomerReviewResponse, error) {
+	payload := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":       "customerReviewResponses",
+			"attributes": map[string]interface{}{"responseBody": responseBody},
+			"relationships": map[string]interface{}{
+				"review": map[string]interface{}{"data": ascIdentifier{Type: "customerReviews", Id: reviewID}},
+			},
+		},
+	}
+
+	response, _, err := store.send(ctx, http.MethodPost, store.resourceURL("/v1/customerReviewResponses", nil), payload)
+	if err != nil {
+		return types.CustomerReviewResponse{}, err
+	}
+
+	return toAppStoreConnectReviewResponse(ctx, response)
+}
+
+// SalesReport downloads the gzipped daily sales summary of a day.
+func (store *appStoreConnectStore) SalesReport(ctx context.Context, vendorNumber string, reportDate time.Time) ([]byte, error) {
+	query := url.Values{}
+	query.Set("filter[frequency]", "DAILY")
+	query.Set("filter[reportType]", "SALES")
+	query.Set("filter[reportSubType]", "SUMMARY")
+	query.Set("filter[vendorNumber]", vendorNumber)
+	query.Set("filter[reportDate]", reportDate.Format("2006-01-02"))
+
+	return store.request(ctx, http.MethodGet, store.resourceURL("/v1/salesReports", query), nil)
+}
+
+// createPhasedRelease turns on the phased release of a version that has none.
+func (store *appStoreConnectStore) createPhasedRelease(ctx context.Context, release *types.Release) error {
+	payload := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":       "appStoreVersionPhasedReleases",
+			"attributes": map[string]interface{}{"phasedReleaseState": "INACTIVE"},
+			"relationships": map[string]interface{}{
+				"appStoreVersion": map[string]interface{}{"data": ascIdentifier{Type: "appStoreVersions", Id: release.Id}},
+			},
+		},
+	}
+
+	phasedRelease, _, err := store.send(ctx, http.MethodPost, store.resourceURL("/v1/appStoreVersionPhasedReleases", nil), payload)
+	if err != nil {
+		return err
+	}
+
+	return applyPhasedRelease(ctx, release, phasedRelease)
+}
+
+// releases fetches the iOS versions of the app with their build, phased
+// release and localizations, newest first.
+func (store *appStoreConnectStore) releases(ctx context.Context) ([]types.Release, error) {
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("filter[platform]", "IOS")
+	query.Set("include", "build,appStoreVersionPhasedRelease,appStoreVersionLocalizations")
+
+	versions, included, err := store.getAll(ctx, fmt.Sprintf("/v1/apps/%s/appStoreVersions", appInfo.Id), query)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []types.Release
+	for _, version := range versions {
+		release, err := toAppStoreConnectRelease(ctx, version, included)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].CreatedDate.After(releases[j].CreatedDate)
+	})
+
+	return releases, nil
+}
+
+// getAll follows the next links of a paginated list and returns every
+// resource along with everything included on the way.
+func (store *appStoreConnectStore) getAll(ctx context.Context, path string, query url.Values) ([]ascResource, []ascResource, error) {
+	var resources []ascResource
+	var included []ascResource
+
+	if query == nil {
+		query = url.Values{}
+	}
+	if query.Get("limit") == "" {
+		query.Set("limit", fmt.Sprint(appStoreConnectPageLimit))
+	}
+
+	requestURL := store.resourceURL(path, query)
+	for requestURL != "" {
+		document, err := store.document(ctx, http.MethodGet, requestURL, nil)
+		if err != nil {
+			return resources, included, err
+		}
+
+		var page []ascResource
+		if err := json.Unmarshal(document.Data, &page); err != nil {
+			slog.ErrorContext(ctx, "appstoreconnect: could not parse response body", "error", err)
+			return resources, included, err
+		}
+
+		resources = append(resources, page...)
+		included = append(included, document.Included...)
+		requestURL = document.Links.Next
+	}
+
+	return resources, included, nil
+}
+
+// getPage fetches only the first page of a list, for when the caller asked
+// for a limited number of resources.
+func (store *appStoreConnectStore) getPage(ctx context.Context, path string, query url.Values) ([]ascResource, []ascResource, error) {
+	document, err := store.document(ctx, http.MethodGet, store.resourceURL(path, query), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resources []ascResource
+	if err := json.Unmarshal(document.Data, &resources); err != nil {
+		slog.ErrorContext(ctx, "appstoreconnect: could not parse response body", "error", err)
+		return nil, nil, err
+	}
+
+	return resources, document.Included, nil
+}
+
+// send makes a request that returns a single resource, if any.
+func (store *appStoreConnectStore) send(ctx context.Context, httpMethod string, requestURL string, payload interface{}) (ascResource, []ascResource, error) {
+	var resource ascResource
+
+	document, err := store.document(ctx, httpMethod, requestURL, payload)
+	if err != nil || len(document.Data) == 0 {
+		return resource, nil, err
+	}
+
+	if err := json.Unmarshal(document.Data, &resource); err != nil {
+		slog.ErrorContext(ctx, "appstoreconnect: could not parse response body", "error", err)
+		return resource, nil, err
+	}
+
+	return resource, document.Included, nil
+}
+
+func (store *appStoreConnectStore) document(ctx context.Context, httpMethod string, requestURL string, payload interface{}) (ascDocument, error) {
+	var document ascDocument
+
+	body, err := store.request(ctx, httpMethod, requestURL, payload)
+	if err != nil || len(body) == 0 {
+		return document, err
+	}
+
+	if err := json.Unmarshal(body, &document); err != nil {
+		slog.ErrorContext(ctx, "appstoreconnect: could not parse response body", "error", err)
+		return document, err
+	}
+
+	return document, nil
+}
+
+func (store *appStoreConnectStore) resourceURL(path string, query url.Values) string {
+	if len(query) == 0 {
+		return appStoreConnectHost + path
+	}
+
+	return fmt.Sprintf("%s%s?%s", appStoreConnectHost, path, query.Encode())
+}
+
+func (store *appStoreConnectStore) request(ctx context.Context, httpMethod string, requestURL string, payload interface{}) ([]byte, error) {
+	var requestBody io.Reader
+	if payload != nil {
+		encodedPayload, err := json.Marshal(payload)
+		if err != nil {
+			slog.ErrorContext(ctx, "appstoreconnect: could not encode request body", "error", err)
+			return nil, err
+		}
+		requestBody = bytes.NewReader(encodedPayload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, httpMethod, requestURL, requestBody)
+	if err != nil {
+		slog.ErrorContext(ctx, "appstoreconnect: could not create request", "error", err)
+		return nil, err
+	}
+
+	storeToken, err := getAppStoreToken(store.credentials)
+	if err != nil {
+		slog.ErrorContext(ctx, "appstoreconnect: could not create store token", "error", err)
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", storeToken))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "appstoreconnect: request failed", "error", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return nil, statusError{service: "appstoreconnect", statusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		slog.ErrorContext(ctx, "appstoreconnect: could not read response body", "error", err)
+	}
+
+	return body, err
+}
+
+// decodeAttributes parses the attributes of a resource. Resources fetched
+// with sparse fieldsets may have none.
+func decodeAttributes(ctx context.Context, resource ascResource, attributes interface{}) error {
+	if len(resource.Attributes) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(resource.Attributes, attributes); err != nil {
+		slog.ErrorContext(ctx, "appstoreconnect: could not parse response body", "type", resource.Type, "id", resource.Id, "error", err)
+		return fmt.Errorf("appstoreconnect: could not parse %s %s: %w", resource.Type, resource.Id, err)
+	}
+
+	return nil
+}
+
+func toAppStoreConnectRelease(ctx context.Context, version ascResource, included []ascResource) (types.Release, error) {
+	var attributes ascVersionAttributes
+	if err := decodeAttributes(ctx, version, &attributes); err != nil {
+		return types.Release{}, err
+	}
+
+	release := types.Release{
+		Id:                  version.Id,
+		VersionName:         attributes.VersionString,
+		AppStoreState:       attributes.AppStoreState,
+		ReleaseType:         attributes.ReleaseType,
+		EarliestReleaseDate: attributes.EarliestReleaseDate,
+		Downloadable:        attributes.Downloadable,
+		CreatedDate:         attributes.CreatedDate,
+	}
+
+	build, ok, err := includedResource(version, "build", included)
+	if err != nil {
+		return release, err
+	}
+	if ok {
+		var buildAttributes ascBuildAttributes
+		if err := decodeAttributes(ctx, build, &buildAttributes); err != nil {
+			return release, err
+		}
+		release.BuildId = build.Id
+		release.BuildNumber = buildAttributes.Version
+	}
+
+	phasedRelease, ok, err := includedResource(version, "appStoreVersionPhasedRelease", included)
+	if err != nil {
+		return release, err
+	}
+	if ok {
+		if err := applyPhasedRelease(ctx, &release, phasedRelease); err != nil {
+			return release, err
+		}
+	}
+
+	localizations, err := includedResources(version, "appStoreVersionLocalizations", included)
+	if err != nil {
+		return release, err
+	}
+
+	for _, resource := range localizations {
+		localization, err := toAppStoreConnectLocalization(ctx, resource)
+		if err != nil {
+			return release, err
+		}
+
+		// Prefer en-US when the version is localized, otherwise take the first one
+		if release.Details.Id != "" && localization.Locale != "en-US" {
+			continue
+		}
+
+		release.Details = localization
+	}
+
+	return release, nil
+}
+
+func toAppStoreConnectLocalization(ctx context.Context, resource ascResource) (types.Localization, error) {
+	var attributes ascLocalizationAttributes
+	if err := decodeAttributes(ctx, resource, &attributes); err != nil {
+		return types.Localization{}, err
+	}
+
+	return types.Localization{
+		Id:              resource.Id,
+		Description:     attributes.Description,
+		Locale:          attributes.Locale,
+		Keywords:        attributes.Keywords,
+		MarketingUrl:    attributes.MarketingUrl,
+		PromotionalText: attributes.PromotionalText,
+		SupportUrl:      attributes.SupportUrl,
+		WhatsNew:        attributes.WhatsNew,
+	}, nil
+}
+
+func toAppStoreConnectBuild(ctx context.Context, resource ascResource, included []ascResource) (types.Build, error) {
+	var attributes ascBuildAttributes
+	if err := decodeAttributes(ctx, resource, &attributes); err != nil {
+		return types.Build{}, err
+	}
+
+	build := types.Build{
+		Id:              resource.Id,
+		BuildNumber:     attributes.Version,
+		ProcessingState: attributes.ProcessingState,
+		UploadedDate:    attributes.UploadedDate,
+		ExpirationDate:  attributes.ExpirationDate,
+		Expired:         attributes.Expired,
+		MinOsVersion:    attributes.MinOsVersion,
+	}
+
+	preReleaseVersion, ok, err := includedResource(resource, "preReleaseVersion", included)
+	if err != nil {
+		return build, err
+	}
+	if ok {
+		var versionAttributes struct {
+			Version string `json:"version"`
+		}
+		if err := decodeAttributes(ctx, preReleaseVersion, &versionAttributes); err != nil {
+			return build, err
+		}
+		build.VersionString = versionAttributes.Version
+	}
+
+	appStoreVersion, ok, err := includedResource(resource, "appStoreVersion", included)
+	if err != nil {
+		return build, err
+	}
+	if ok {
+		var versionAttributes ascVersionAttributes
+		if err := decodeAttributes(ctx, appStoreVersion, &versionAttributes); err != nil {
+			return build, err
+		}
+		build.AppStoreVersion = versionAttributes.VersionString
+	}
+
+	groups, err := includedResources(resource, "betaGroups", included)
+	if err != nil {
+		return build, err
+	}
+
+	for _, group := range groups {
+		var groupAttributes struct {
+			Name string `json:"name"`
+		}
+		if err := decodeAttributes(ctx, group, &groupAttributes); err != nil {
+			return build, err
+		}
+		build.BetaGroups = append(build.BetaGroups, groupAttributes.Name)
+	}
+
+	return build, nil
+}
+
+// Items of a review submission point at what is being reviewed through one
+// of these relationships.
+var reviewSubmissionItemTypes = []string{"appStoreVersion", "appCustomProductPageVersion", "appStoreVersionExperiment", "appEvent"}
+
+func toAppStoreConnectReviewSubmission(ctx context.Context, resource ascResource, included []ascResource) (types.ReviewSubmission, error) {
+	var attributes struct {
+		State         string     `json:"state"`
+		SubmittedDate *time.Time `json:"submittedDate"`
+	}
+	if err := decodeAttributes(ctx, resource, &attributes); err != nil {
+		return types.ReviewSubmission{}, err
+	}
+
+	submission := types.ReviewSubmission{Id: resource.Id, State: attributes.State}
+	if attributes.SubmittedDate != nil {
+		submission.SubmittedDate = *attributes.SubmittedDate
+	}
+
+	var versionString string
+	version, ok, err := includedResource(resource, "appStoreVersionForReview", included)
+	if err != nil {
+		return submission, err
+	}
+	if ok {
+		var versionAttributes ascVersionAttributes
+		if err := decodeAttributes(ctx, version, &versionAttributes); err != nil {
+			return submission, err
+		}
+		versionString = versionAttributes.VersionString
+	}
+
+	items, err := includedResources(resource, "items", included)
+	if err != nil {
+		return submission, err
+	}
+
+	for _, item := range items {
+		var itemAttributes struct {
+			State string `json:"state"`
+		}
+		if err := decodeAttributes(ctx, item, &itemAttributes); err != nil {
+			return submission, err
+		}
+
+		itemType := item.Type
+		for _, candidate := range reviewSubmissionItemTypes {
+			if data := bytes.TrimSpace(item.Relationships[candidate].Data); len(data) > 0 && !bytes.Equal(data, []byte("null")) {
+				itemType = candidate
+				break
+			}
+		}
+
+		itemVersion := ""
+		if itemType == "appStoreVersion" {
+			itemVersion = versionString
+		}
+
+		submission.Items = append(submission.Items, struct {
+			Id            string `json:"id"`
+			State         string `json:"state"`
+			Type          string `json:"type"`
+			VersionString string `json:"version_string"`
+		}{
+			Id:            item.Id,
+			State:         itemAttributes.State,
+			Type:          itemType,
+			VersionString: itemVersion,
+		})
+	}
+
+	return submission, nil
+}
+
+func toAppStoreConnectReviewResponse(ctx context.Context, resource ascResource) (types.CustomerReviewResponse, error) {
+	var attributes ascReviewResponseAttributes
+	if err := decodeAttributes(ctx, resource, &attributes); err != nil {
+		return types.CustomerReviewResponse{}, err
+	}
+
+	return types.CustomerReviewResponse{
+		Id:               resource.Id,
+		Body:             attributes.ResponseBody,
+		State:            attributes.State,
+		LastModifiedDate: attributes.LastModifiedDate,
+	}, nil
+}
+
+func applyPhasedRelease(ctx context.Context, release *types.Release, phasedRelease ascResource) error {
+	var attributes ascPhasedReleaseAttributes
+	if err := decodeAttributes(ctx, phasedRelease, &attributes); err != nil {
+		return err
+	}
+
+	release.PhasedRelease.Id = phasedRelease.Id
+	release.PhasedRelease.PhasedReleaseState = attributes.PhasedReleaseState
+	release.PhasedRelease.StartDate = attributes.StartDate
+	release.PhasedRelease.TotalPauseDuration = attributes.TotalPauseDuration
+	release.PhasedRelease.CurrentDayNumber = attributes.CurrentDayNumber
+	return nil
+}
+
+// includedResource resolves a to-one relationship of a resource against the included resources.
+func includedResource(resource ascResource, relationship string, included []ascResource) (ascResource, bool, error) {
+	resources, err := includedResources(resource, relationship, included)
+	if err != nil || len(resources) == 0 {
+		return ascResource{}, false, err
+	}
+
+	return resources[0], true, nil
+}
+
+// includedResources resolves a relationship, to-one or to-many, against the included resources.
+func includedResources(resource ascResource, relationship string, included []ascResource) ([]ascResource, error) {
+	data := bytes.TrimSpace(resource.Relationships[relationship].Data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+
+	var identifiers []ascIdentifier
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &identifiers); err != nil {
+			return nil, fmt.Errorf("appstoreconnect: could not parse the %s of %s %s: %w", relationship, resource.Type, resource.Id, err)
+		}
+	} else {
+		var identifier ascIdentifier
+		if err := json.Unmarshal(data, &identifier); err != nil {
+			return nil, fmt.Errorf("appstoreconnect: could not parse the %s of %s %s: %w", relationship, resource.Type, resource.Id, err)
+		}
+		identifiers = append(identifiers, identifier)
+	}
+
+	var resources []ascResource
+	for _, identifier := range identifiers {
+		for _, candidate := range included {
+			if candidate.Type == identifier.Type && candidate.Id == identifier.Id {
+				resources = append(resources, candidate)
+				break
+			}
+		}
+	}
+
+	return resources, nil
+}
+
+func currentStatusBuild(id string, buildNumber string, status string, versionString string, releaseDate time.Time) struct {
+	Id            string    `json:"id"`
+	BuildNumber   string    `json:"build_number"`
+	Status        string    `json:"status"`
+	VersionString string    `json:"version_string"`
+	ReleaseDate   time.Time `json:"release_date"`
+} {
+	return struct {
+		Id            string    `json:"id"`
+		BuildNumber   string    `json:"build_number"`
+		Status        string    `json:"status"`
+		VersionString string    `json:"version_string"`
+		ReleaseDate   time.Time `json:"release_date"`
+	}{
+		Id:            id,
+		BuildNumber:   buildNumber,
+		Status:        status,
+		VersionString: versionString,
+		ReleaseDate:   releaseDate,
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const ascAppFixture = `{
+	"data": [{"type": "apps", "id": "app-1", "attributes": {"name": "Ciderbot Demo", "bundleId": "com.example.ciderbot", "sku": "CIDERBOT"}}],
+	"links": {}
+}`
+
+const ascVersionsFixture = `{
+	"data": [
+		{
+			"type": "appStoreVersions", "id": "version-110",
+			"attributes": {"versionString": "1.1.0", "appStoreState": "READY_FOR_SALE", "releaseType": "MANUAL", "createdDate": "2026-09-01T10:00:00Z"},
+			"relationships": {
+				"build": {"data": {"type": "builds", "id": "build-110"}},
+				"appStoreVersionPhasedRelease": {"data": {"type": "appStoreVersionPhasedReleases", "id": "phased-110"}},
+				"appStoreVersionLocalizations": {"data": [{"type": "appStoreVersionLocalizations", "id": "loc-fr"}, {"type": "appStoreVersionLocalizations", "id": "loc-us"}]}
+			}
+		},
+		{
+			"type": "appStoreVersions", "id": "version-120",
+			"attributes": {"versionString": "1.2.0", "appStoreState": "WAITING_FOR_REVIEW", "releaseType": "AFTER_APPROVAL", "createdDate": "2026-10-01T10:00:00Z"},
+			"relationships": {
+				"build": {"data": null},
+				"appStoreVersionPhasedRelease": {"data": null},
+				"appStoreVersionLocalizations": {"data": []}
+			}
+		}
+	],
+	"included": [
+		{"type": "builds", "id": "build-110", "attributes": {"version": "110", "processingState": "VALID"}},
+		{"type": "appStoreVersionPhasedReleases", "id": "phased-110", "attributes": {"phasedReleaseState": "ACTIVE", "currentDayNumber": 3}},
+		{"type": "appStoreVersionLocalizations", "id": "loc-fr", "attributes": {"locale": "fr-FR", "whatsNew": "Corrections"}},
+		{"type": "appStoreVersionLocalizations", "id": "loc-us", "attributes": {"locale": "en-US", "whatsNew": "Bug fixes"}}
+	],
+	"links": {}
+}`
+
+// ascRequest is a request the fake App Store Connect API received.
+type ascRequest struct {
+	method string
+	path   string
+	query  string
+	body   string
+}
+
+// fakeAppStoreConnect serves the fixtures of each path and records every
+// request it gets. It points appStoreConnectHost at itself until the test ends.
+func fakeAppStoreConnect(t *testing.T, fixtures map[string]string) *[]ascRequest {
+	t.Helper()
+
+	var requests []ascRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, ascRequest{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery, body: string(body)})
+
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fixture, ok := fixtures[r.Method+" "+r.URL.Path+"?"+r.URL.Query().Get("cursor")]
+		if !ok {
+			fixture, ok = fixtures[r.Method+" "+r.URL.Path]
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		io.WriteString(w, strings.ReplaceAll(fixture, "{{host}}", "http://"+r.Host))
+	}))
+	t.Cleanup(server.Close)
+
+	host := appStoreConnectHost
+	appStoreConnectHost = server.URL
+	t.Cleanup(func() { appStoreConnectHost = host })
+
+	return &requests
+}
+
+func testAppStoreConnectStore(t *testing.T) *appStoreConnectStore {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate a key: %s", err)
+	}
+	encodedKey, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("could not encode the key: %s", err)
+	}
+
+	return newAppStoreConnectStore(&types.AppleCredentials{
+		BundleID: "com.example.ciderbot",
+		IssuerID: "issuer",
+		KeyID:    "key",
+		P8File:   pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encodedKey}),
+	})
+}
+
+func TestAppStoreConnectFollowsNextLinks(t *testing.T) {
+	requests := fakeAppStoreConnect(t, map[string]string{
+		"GET /v1/apps": ascAppFixture,
+		"GET /v1/betaGroups": `{
+			"data": [{"type": "betaGroups", "id": "group-1", "attributes": {"name": "Team", "isInternalGroup": true}}],
+			"links": {"next": "{{host}}/v1/betaGroups?cursor=2"}
+		}`,
+		"GET /v1/betaGroups?2": `{
+			"data": [{"type": "betaGroups", "id": "group-2", "attributes": {"name": "Public Beta", "isInternalGroup": false}}],
+			"links": {}
+		}`,
+		"GET /v1/betaGroups/group-1/betaTesters": `{"data": [{"type": "betaTesters", "id": "tester-1", "attributes": {"firstName": "Ada", "lastName": "Lovelace", "email": "ada@example.com"}}]}`,
+		"GET /v1/betaGroups/group-2/betaTesters": `{"data": []}`,
+	})
+
+	groups, err := testAppStoreConnectStore(t).BetaGroups(context.Background())
+	if err != nil {
+		t.Fatalf("could not list the beta groups: %s", err)
+	}
+
+	if len(groups) != 2 || groups[0].Name != "Team" || groups[1].Name != "Public Beta" {
+		t.Fatalf("the groups of both pages were not listed: %+v", groups)
+	}
+	if len(groups[0].Testers) != 1 || groups[0].Testers[0].Name != "Ada Lovelace" {
+		t.Errorf("the testers of the group are %+v", groups[0].Testers)
+	}
+
+	pages := 0
+	for _, request := range *requests {
+		if request.path == "/v1/betaGroups" {
+			pages++
+		}
+	}
+	if pages != 2 {
+		t.Errorf("fetched %d pages of beta groups, want 2", pages)
+	}
+}
+
+func TestAppStoreConnectResolvesIncludedResources(t *testing.T) {
+	fakeAppStoreConnect(t, map[string]string{
+		"GET /v1/apps":                        ascAppFixture,
+		"GET /v1/apps/app-1/appStoreVersions": ascVersionsFixture,
+	})
+	store := testAppStoreConnectStore(t)
+
+	liveRelease, err := store.LiveRelease(context.Background())
+	if err != nil {
+		t.Fatalf("could not get the live release: %s", err)
+	}
+
+	if liveRelease.BuildId != "build-110" || liveRelease.BuildNumber != "110" {
+		t.Errorf("the build of the live release is %s (%s), want build-110 (110)", liveRelease.BuildId, liveRelease.BuildNumber)
+	}
+	if liveRelease.PhasedRelease.Id != "phased-110" || liveRelease.PhasedRelease.CurrentDayNumber != 3 {
+		t.Errorf("the phased release of the live release is %+v", liveRelease.PhasedRelease)
+	}
+	if liveRelease.Details.Locale != "en-US" || liveRelease.Details.WhatsNew != "Bug fixes" {
+		t.Errorf("the live release is described by %+v, want the en-US localization", liveRelease.Details)
+	}
+
+	inflightRelease, err := store.InflightRelease(context.Background())
+	if err != nil {
+		t.Fatalf("could not get the inflight release: %s", err)
+	}
+	if inflightRelease.VersionName != "1.2.0" || inflightRelease.BuildId != "" || inflightRelease.PhasedRelease.Id != "" {
+		t.Errorf("the inflight release is %+v, want 1.2.0 without a build or a phased release", inflightRelease)
+	}
+}
+
+func TestAppStoreConnectUpdatesThePhasedRelease(t *testing.T) {
+	requests := fakeAppStoreConnect(t, map[string]string{
+		"GET /v1/apps":                                       ascAppFixture,
+		"GET /v1/apps/app-1/appStoreVersions":                ascVersionsFixture,
+		"PATCH /v1/appStoreVersionPhasedReleases/phased-110": `{"data": {"type": "appStoreVersionPhasedReleases", "id": "phased-110", "attributes": {"phasedReleaseState": "PAUSED", "currentDayNumber": 3}}}`,
+	})
+
+	release, err := testAppStoreConnectStore(t).PauseRollout(context.Background())
+	if err != nil {
+		t.Fatalf("could not pause the rollout: %s", err)
+	}
+	if release.VersionName != "1.1.0" || release.PhasedRelease.PhasedReleaseState != "PAUSED" {
+		t.Errorf("the paused release is %s %s", release.VersionName, release.PhasedRelease.PhasedReleaseState)
+	}
+
+	patch := (*requests)[len(*requests)-1]
+	if patch.method != http.MethodPatch {
+		t.Fatalf("the last request is %s %s, want the PATCH of the phased release", patch.method, patch.path)
+	}
+
+	var payload struct {
+		Data struct {
+			Type       string `json:"type"`
+			Id         string `json:"id"`
+			Attributes struct {
+				PhasedReleaseState string `json:"phasedReleaseState"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(patch.body), &payload); err != nil {
+		t.Fatalf("could not parse the PATCH body %q: %s", patch.body, err)
+	}
+	if payload.Data.Type != "appStoreVersionPhasedReleases" || payload.Data.Id != "phased-110" || payload.Data.Attributes.PhasedReleaseState != "PAUSED" {
+		t.Errorf("the PATCH body is %s", patch.body)
+	}
+}
+
+func TestAppStoreConnectListsBuildsWithTheirVersion(t *testing.T) {
+	requests := fakeAppStoreConnect(t, map[string]string{
+		"GET /v1/apps": ascAppFixture,
+		"GET /v1/builds": `{
+			"data": [{
+				"type": "builds", "id": "build-121",
+				"attributes": {"version": "121", "processingState": "VALID", "minOsVersion": "16.0"},
+				"relationships": {
+					"preReleaseVersion": {"data": {"type": "preReleaseVersions", "id": "pre-120"}},
+					"betaGroups": {"data": [{"type": "betaGroups", "id": "group-1"}]},
+					"appStoreVersion": {"data": null}
+				}
+			}],
+			"included": [
+				{"type": "preReleaseVersions", "id": "pre-120", "attributes": {"version": "1.2.0"}},
+				{"type": "betaGroups", "id": "group-1", "attributes": {"name": "Team"}}
+			]
+		}`,
+	})
+
+	builds, err := testAppStoreConnectStore(t).Builds(context.Background(), "1.2.0", 5)
+	if err != nil {
+		t.Fatalf("could not list the builds: %s", err)
+	}
+
+	if len(builds) != 1 || builds[0].BuildNumber != "121" || builds[0].VersionString != "1.2.0" || len(builds[0].BetaGroups) != 1 {
+		t.Fatalf("the builds are %+v", builds)
+	}
+
+	query := (*requests)[len(*requests)-1].query
+	if !strings.Contains(query, "filter%5BpreReleaseVersion.version%5D=1.2.0") || !strings.Contains(query, "limit=5") {
+		t.Errorf("the builds were not filtered by version and limit: %s", query)
+	}
+}
+
+func TestAppStoreConnectReturnsAttributeErrors(t *testing.T) {
+	fakeAppStoreConnect(t, map[string]string{
+		"GET /v1/apps": ascAppFixture,
+		"GET /v1/apps/app-1/customerReviews": `{
+			"data": [{"type": "customerReviews", "id": "review-1", "attributes": {"rating": "five"}}]
+		}`,
+		"GET /v1/betaGroups":                     `{"data": [{"type": "betaGroups", "id": "group-1", "attributes": {"name": "Team"}}]}`,
+		"GET /v1/betaGroups/group-1/betaTesters": `{"data": [{"type": "betaTesters", "id": "tester-1", "attributes": {"email": 42}}]}`,
+	})
+	store := testAppStoreConnectStore(t)
+
+	if _, err := store.CustomerReviews(context.Background(), "", 10); err == nil || !strings.Contains(err.Error(), "review-1") {
+		t.Errorf("a review that does not parse did not fail: %v", err)
+	}
+	if _, err := store.BetaGroups(context.Background()); err == nil || !strings.Contains(err.Error(), "tester-1") {
+		t.Errorf("a tester that does not parse did not fail: %v", err)
+	}
+}
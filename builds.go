@@ -0,0 +1,193 @@
+package main
+
+import (
+	slack "ciderbot/slack"
+	"ciderbot/types"
+	"context"
+	"fmt"
+	"strconv"
+)
+
+const (
+	defaultBuildsLimit = 10
+	maxBuildsLimit     = 20
+	validBuildState    = "VALID"
+)
+
+func handleBuildsCommand(ctx context.Context, form types.SlackFormData, store StoreClient) types.SlackResponse {
+	flags, _ := commandFlags(commandArgs(form.Text))
+
+	limit := defaultBuildsLimit
+	if value, ok := flags["limit"]; ok {
+		parsedLimit, err := strconv.Atoi(value)
+		if err != nil || parsedLimit < 1 || parsedLimit > maxBuildsLimit {
+			return slack.EphemeralMessage{Msg: fmt.Sprintf("The limit should be a number between 1 and %d.", maxBuildsLimit)}.Render()
+		}
+		limit = parsedLimit
+	}
+
+	builds, err := store.Builds(ctx, flags["version"], limit)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find builds for your app."}.Render()
+	}
+
+	buildList := slack.Builds{VersionString: flags["version"]}
+	for _, build := range builds {
+		buildList.Builds = append(buildList.Builds, toBuildDetails(build))
+	}
+
+	return buildList.Render()
+}
+
+func handleBuildCommand(ctx context.Context, form types.SlackFormData, store StoreClient) types.SlackResponse {
+	args := commandArgs(form.Text)
+	if len(args) == 0 {
+		return slack.EphemeralMessage{Msg: "Please provide a build number, e.g. `build 42`."}.Render()
+	}
+
+	build, err := store.Build(ctx, args[0])
+	if err != nil {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not find the build `%s`.", args[0])}.Render()
+	}
+
+	return toBuildDetails(build).Render()
+}
+
+func handleAttachBuildCommand(ctx context.Context, form types.SlackFormData, store StoreClient) types.SlackResponse {
+	args := commandArgs(form.Text)
+	if len(args) == 0 {
+		return buildPicker(ctx, store)
+	}
+
+	return attachBuildToInflightRelease(ctx, store, args[0], buildByNumber(args[0]))
+}
+
+func handleAttachBuildAction(ctx context.Context, interaction types.SlackInteraction, action types.SlackAction, user *types.User, repos Repositories, store StoreClient) types.SlackResponse {
+	if action.SelectedOption == nil {
+		return types.SlackResponse{}
+	}
+
+	// The picker offers the builds by id, build numbers can repeat across versions
+	buildID := action.SelectedOption.Value
+	slackResponse := attachBuildToInflightRelease(ctx, store, buildID, buildByID(buildID))
+	if slackResponse.ResponseType == "in_channel" {
+		slackResponse.ReplaceOriginal = true
+	}
+
+	return slackResponse
+}
+
+// buildPicker lists the processed builds of the inflight version to pick one from.
+func buildPicker(ctx context.Context, store StoreClient) types.SlackResponse {
+	inflightRelease, err := store.InflightRelease(ctx)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find an inflight release for your app."}.Render()
+	}
+
+	if !unsubmittedStoreStates[inflightRelease.AppStoreState] {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("The build of *%s* can not be changed while it is `%s`.", inflightRelease.VersionName, inflightRelease.AppStoreState)}.Render()
+	}
+
+	builds, err := store.Builds(ctx, inflightRelease.VersionName, maxBuildsLimit)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not find builds for *%s*.", inflightRelease.VersionName)}.Render()
+	}
+
+	picker := slack.BuildPicker{VersionString: inflightRelease.VersionName, ActionId: "attach_build"}
+	for _, build := range builds {
+		if build.ProcessingState == validBuildState && !build.Expired && build.BuildNumber != inflightRelease.BuildNumber {
+			picker.Builds = append(picker.Builds, toBuildDetails(build))
+		}
+	}
+
+	return picker.Render()
+}
+
+// buildLookup finds the build to attach to the inflight version.
+type buildLookup func(ctx context.Context, store StoreClient, inflightRelease types.Release) (types.Build, error)
+
+// buildByNumber looks among the builds of the inflight version, a build
+// number is only unique within a version.
+func buildByNumber(buildNumber string) buildLookup {
+	return inflightBuild(func(build types.Build) bool { return build.BuildNumber == buildNumber }, buildNumber)
+}
+
+// buildByID looks among the builds the picker offered.
+func buildByID(buildID string) buildLookup {
+	return inflightBuild(func(build types.Build) bool { return build.Id == buildID }, buildID)
+}
+
+func inflightBuild(matches func(types.Build) bool, buildRef string) buildLookup {
+	return func(ctx context.Context, store StoreClient, inflightRelease types.Release) (types.Build, error) {
+		builds, err := store.Builds(ctx, inflightRelease.VersionName, maxBuildsLimit)
+		if err != nil {
+			return types.Build{}, err
+		}
+
+		for _, build := range builds {
+			if matches(build) {
+				return build, nil
+			}
+		}
+
+		return types.Build{}, fmt.Errorf("build %s is not one of the builds of %s", buildRef, inflightRelease.VersionName)
+	}
+}
+
+func attachBuildToInflightRelease(ctx context.Context, store StoreClient, buildRef string, findBuild buildLookup) types.SlackResponse {
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find your app."}.Render()
+	}
+
+	inflightRelease, err := store.InflightRelease(ctx)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find an inflight release for your app."}.Render()
+	}
+
+	if !unsubmittedStoreStates[inflightRelease.AppStoreState] {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("The build of *%s* can not be changed while it is `%s`.", inflightRelease.VersionName, inflightRelease.AppStoreState)}.Render()
+	}
+
+	build, err := findBuild(ctx, store, inflightRelease)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not find the build `%s`.", buildRef)}.Render()
+	}
+	buildNumber := build.BuildNumber
+
+	if build.ProcessingState != validBuildState || build.Expired {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("The build `%s` is `%s` and can not be attached yet.", buildNumber, build.ProcessingState)}.Render()
+	}
+
+	if build.VersionString != inflightRelease.VersionName {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("The build `%s` is for *%s*, not the inflight version *%s*.", buildNumber, build.VersionString, inflightRelease.VersionName)}.Render()
+	}
+
+	updatedRelease, err := store.AttachBuild(ctx, build.Id)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not attach the build `%s` to *%s*.", buildNumber, inflightRelease.VersionName)}.Render()
+	}
+
+	// Fall back to the build we asked for in case the version was read before the change settled
+	if updatedRelease.BuildId != build.Id {
+		updatedRelease.BuildId = build.Id
+		updatedRelease.BuildNumber = build.BuildNumber
+	}
+
+	return toInflightRelease(appInfo.Id, updatedRelease).Render()
+}
+
+func toBuildDetails(build types.Build) slack.BuildDetails {
+	return slack.BuildDetails{
+		Id:              build.Id,
+		BuildNumber:     build.BuildNumber,
+		VersionString:   build.VersionString,
+		ProcessingState: build.ProcessingState,
+		UploadedDate:    build.UploadedDate,
+		ExpirationDate:  build.ExpirationDate,
+		Expired:         build.Expired,
+		MinOsVersion:    build.MinOsVersion,
+		BetaGroups:      build.BetaGroups,
+		AppStoreVersion: build.AppStoreVersion,
+	}
+}
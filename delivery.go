@@ -0,0 +1,226 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	maxSlackAttempts     = 4
+	slackRetryDelay      = time.Second
+	maxSlackRetryDelay   = 30 * time.Second
+	maxResponseURLUses   = 5
+	responseURLRetention = time.Hour
+)
+
+// slackDelivery is where a response goes: the response url Slack gave us
+// while it is usable, otherwise the channel through the bot token.
+type slackDelivery struct {
+	TeamID      string
+	ChannelID   string
+	UserID      string
+	ResponseURL string
+	ExpiresAt   time.Time
+}
+
+// errResponseURLUsedUp is returned once Slack would not take another response
+// on the response url.
+var errResponseURLUsedUp = errors.New("slack: the response url is used up")
+
+// slackStatusError is returned when Slack responds with an unsuccessful status.
+type slackStatusError struct {
+	statusCode int
+	retryAfter time.Duration
+}
+
+func (err slackStatusError) Error() string {
+	return fmt.Sprintf("slack: request failed with status - %d", err.statusCode)
+}
+
+func (err slackStatusError) retryable() bool {
+	return err.statusCode == http.StatusTooManyRequests || err.statusCode >= http.StatusInternalServerError
+}
+
+// deliverSlackResponse posts the response to the response url, and falls back
+// to posting it to the channel when the url is expired, used up or keeps
+// failing. Every post to the url counts against its uses, retries included.
+// Responses that could not be delivered at all are recorded.
+func deliverSlackResponse(ctx context.Context, repos Repositories, delivery slackDelivery, slackResponse types.SlackResponse) (err error) {
+	ctx, span := tracer.Start(ctx, "slack.deliver")
+	defer func() { endSpan(span, err) }()
+
+	method := "response_url"
+
+	if delivery.ResponseURL != "" && time.Now().Before(delivery.ExpiresAt) {
+		claimUse := func() bool {
+			return claimResponseURLUse(ctx, repos.Deliveries, delivery.ResponseURL)
+		}
+
+		err = sendResponseToSlack(ctx, delivery.ResponseURL, slackResponse, claimUse)
+		if err == nil {
+			return nil
+		}
+
+		slog.WarnContext(ctx, "slack: could not use the response url, posting to the channel instead", "error", err)
+	}
+
+	if delivery.ChannelID != "" {
+		span.AddEvent("falling back to the channel")
+		method, err = postResponseToChannel(ctx, repos.Users, delivery, slackResponse)
+		if err == nil {
+			return nil
+		}
+	} else if err == nil {
+		err = errors.New("slack: the response url is not usable and there is no channel to post to")
+	}
+
+	recordDeliveryFailure(ctx, repos.Deliveries, delivery, method, err)
+	return err
+}
+
+// postResponseToChannel posts what would have gone to the response url, an
+// ephemeral response stays visible to the user who asked for it only.
+func postResponseToChannel(ctx context.Context, users UserRepository, delivery slackDelivery, slackResponse types.SlackResponse) (string, error) {
+	user, err := users.FindByTeamID(delivery.TeamID)
+	if err != nil {
+		return "chat.postMessage", err
+	}
+
+	if !user.SlackAccessToken.Valid {
+		return "chat.postMessage", fmt.Errorf("slack: workspace %s has no bot token", delivery.TeamID)
+	}
+
+	if slackResponse.ResponseType != "in_channel" && delivery.UserID != "" {
+		return "chat.postEphemeral", postEphemeralToSlack(ctx, user.SlackAccessToken.String, delivery.ChannelID, delivery.UserID, slackResponse)
+	}
+
+	return "chat.postMessage", postMessageToSlack(ctx, user.SlackAccessToken.String, delivery.ChannelID, slackResponse)
+}
+
+// claimResponseURLUse counts a use of the response url, Slack accepts up to
+// five responses per url. Only a hash of the url is kept.
+func claimResponseURLUse(ctx context.Context, deliveries DeliveryRepository, responseURL string) bool {
+	hash := sha256.Sum256([]byte(responseURL))
+
+	claimed, err := deliveries.ClaimResponseURLUse(hex.EncodeToString(hash[:]))
+	if err != nil {
+		slog.ErrorContext(ctx, "slack: could not count the response url use", "error", err)
+	}
+
+	return claimed
+}
+
+func recordDeliveryFailure(ctx context.Context, deliveries DeliveryRepository, delivery slackDelivery, method string, err error) {
+	slog.ErrorContext(ctx, "slack: could not deliver the response", "channel_id", delivery.ChannelID, "method", method, "error", err)
+
+	failure := types.SlackDeliveryFailure{
+		SlackTeamID:    delivery.TeamID,
+		SlackChannelID: delivery.ChannelID,
+		Method:         method,
+		Error:          err.Error(),
+	}
+
+	var statusErr slackStatusError
+	if errors.As(err, &statusErr) {
+		failure.StatusCode = statusErr.statusCode
+	}
+
+	if err := deliveries.RecordFailure(&failure); err != nil {
+		slog.ErrorContext(ctx, "slack: could not record the delivery failure", "error", err)
+	}
+}
+
+// doSlackRequest sends the request, and sends it again with a backoff when
+// Slack is rate limiting us or failing, waiting as long as Retry-After asks.
+// A longer wait is left to the caller, with the Retry-After in the error.
+// claimAttempt, when given, is asked before every attempt whether there is
+// one left.
+func doSlackRequest(req *http.Request, claimAttempt func() bool) (resp *http.Response, err error) {
+	// The url is left out of the span, a response url is a secret
+	_, span := tracer.Start(req.Context(), "slack "+slackOperation(req.URL), trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { endSpan(span, err) }()
+
+	delay := slackRetryDelay
+
+	for attempt := 1; ; attempt++ {
+		if claimAttempt != nil && !claimAttempt() {
+			return nil, errResponseURLUsedUp
+		}
+
+		span.SetAttributes(attribute.Int("ciderbot.attempts", attempt))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			slog.ErrorContext(req.Context(), "slack: request failed", "error", err)
+			return nil, err
+		}
+
+		span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+		if resp.StatusCode < 300 {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		statusErr := slackStatusError{statusCode: resp.StatusCode, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		if !statusErr.retryable() || attempt == maxSlackAttempts || req.GetBody == nil {
+			return nil, statusErr
+		}
+
+		wait := delay
+		if statusErr.retryAfter > 0 {
+			wait = statusErr.retryAfter
+		}
+		if wait > maxSlackRetryDelay {
+			return nil, statusErr
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		delay *= 2
+
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = body
+	}
+}
+
+// slackOperation names a request to Slack after its API method.
+func slackOperation(requestURL *url.URL) string {
+	switch {
+	case strings.HasPrefix(requestURL.Path, "/api/"):
+		return strings.TrimPrefix(requestURL.Path, "/api/")
+	case strings.HasPrefix(requestURL.Host, "hooks."):
+		return "response_url"
+	default:
+		return "file upload"
+	}
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+
+	return 0
+}
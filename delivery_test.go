@@ -0,0 +1,160 @@
+package main
+
+import (
+	slack "ciderbot/slack"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeResponseURL answers the posts to a response url with the given statuses
+// in turn, then with 200, and counts them.
+func fakeResponseURL(t *testing.T, statuses ...int) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var posts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		post := int(atomic.AddInt32(&posts, 1))
+		if post <= len(statuses) {
+			if statuses[post-1] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "120")
+			}
+			w.WriteHeader(statuses[post-1])
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &posts
+}
+
+func TestDeliverSlackResponseCountsEveryPostToTheResponseURL(t *testing.T) {
+	server, posts := fakeResponseURL(t, http.StatusServiceUnavailable)
+	repos := newMemoryRepositories()
+	delivery := slackDelivery{TeamID: "T0001", ResponseURL: server.URL, ExpiresAt: time.Now().Add(time.Minute)}
+	response := slack.EphemeralMessage{Msg: "Hello"}.Render()
+
+	// The retry of the first response takes a use of its own
+	for i := 0; i < maxResponseURLUses-1; i++ {
+		if err := deliverSlackResponse(context.Background(), repos, delivery, response); err != nil {
+			t.Fatalf("could not deliver response %d: %s", i+1, err)
+		}
+	}
+	if *posts != maxResponseURLUses {
+		t.Fatalf("posted %d times, want %d", *posts, maxResponseURLUses)
+	}
+
+	err := deliverSlackResponse(context.Background(), repos, delivery, response)
+	if err == nil || *posts != maxResponseURLUses {
+		t.Errorf("a used up response url was posted to again (%d posts, %v)", *posts, err)
+	}
+}
+
+func TestLongRetryAfterIsLeftToTheQueue(t *testing.T) {
+	server, posts := fakeResponseURL(t, http.StatusTooManyRequests)
+	repos := newMemoryRepositories()
+	enqueueTestCommand(t, repos, "T0001")
+
+	now := time.Now().UTC()
+	job, err := repos.Commands.ClaimNext(now)
+	if err != nil || job == nil {
+		t.Fatalf("could not claim the command: %v", err)
+	}
+	job.ResponseURL = server.URL
+
+	// Without a channel to fall back to, the response has to wait for Slack
+	delivery := commandDelivery(job)
+	delivery.ChannelID = ""
+
+	startedAt := time.Now()
+	err = deliverSlackResponse(context.Background(), repos, delivery, slack.EphemeralMessage{Msg: "Hello"}.Render())
+
+	var statusErr slackStatusError
+	if !errors.As(err, &statusErr) || statusErr.retryAfter != 2*time.Minute {
+		t.Fatalf("the delivery failed with %v, want the Retry-After of Slack", err)
+	}
+	if *posts != 1 || time.Since(startedAt) > maxSlackRetryDelay {
+		t.Errorf("the worker waited for Slack: %d posts in %s", *posts, time.Since(startedAt))
+	}
+
+	retryOrFailCommand(context.Background(), repos, job, err)
+
+	if job.Status != commandQueued || job.NextAttemptAt.Before(now.Add(2*time.Minute)) {
+		t.Errorf("the command is %s until %s, want queued until Slack is ready", job.Status, job.NextAttemptAt)
+	}
+	if early, _ := repos.Commands.ClaimNext(now.Add(time.Minute)); early != nil {
+		t.Errorf("the command was claimed before the Retry-After")
+	}
+}
+
+// slackAPICall is a request a test made to the Web API of Slack.
+type slackAPICall struct {
+	Method string
+	Body   string
+}
+
+type fakeSlackTransport struct {
+	mu        sync.Mutex
+	calls     []slackAPICall
+	transport http.RoundTripper
+}
+
+func (fake *fakeSlackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "slack.com" {
+		return fake.transport.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+
+	fake.mu.Lock()
+	fake.calls = append(fake.calls, slackAPICall{Method: path.Base(req.URL.Path), Body: string(body)})
+	fake.mu.Unlock()
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"ok": true}`)),
+		Request:    req,
+	}, nil
+}
+
+// made returns the calls to the Slack API method, e.g. chat.postMessage.
+func (fake *fakeSlackTransport) made(method string) []slackAPICall {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	var calls []slackAPICall
+	for _, call := range fake.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// fakeSlackAPI answers every call to the Slack API with ok and records it,
+// other hosts are still reached.
+func fakeSlackAPI(t *testing.T) *fakeSlackTransport {
+	t.Helper()
+
+	transport := http.DefaultClient.Transport
+	fake := &fakeSlackTransport{transport: http.DefaultTransport}
+	if transport != nil {
+		fake.transport = transport
+	}
+
+	http.DefaultClient.Transport = fake
+	t.Cleanup(func() { http.DefaultClient.Transport = transport })
+
+	return fake
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	slack "ciderbot/slack"
+	"ciderbot/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const defaultDigestTimezone = "UTC"
+
+type digestSnapshot struct {
+	LiveVersion        string            `json:"live_version"`
+	LiveBuildNumber    string            `json:"live_build_number"`
+	PhasedReleaseDay   int               `json:"phased_release_day"`
+	PhasedReleaseState string            `json:"phased_release_state"`
+	InflightVersion    string            `json:"inflight_version"`
+	InflightBuild      string            `json:"inflight_build"`
+	InflightState      string            `json:"inflight_state"`
+	ChannelBuilds      map[string]string `json:"channel_builds"`
+}
+
+func handleDigestCommand(ctx context.Context, form types.SlackFormData, repos Repositories, store StoreClient) types.SlackResponse {
+	args := commandArgs(form.Text)
+
+	if len(args) == 0 {
+		digest, _, err := compileDigest(ctx, store, nil)
+		if err != nil {
+			return slack.EphemeralMessage{Msg: "Could not compile a digest for your app."}.Render()
+		}
+
+		return digest.Render()
+	}
+
+	switch args[0] {
+	case "schedule":
+		if len(args) < 2 {
+			return slack.EphemeralMessage{Msg: "Please provide a schedule, e.g. `digest schedule \"0 9 * * 1-5\" Asia/Kolkata`."}.Render()
+		}
+
+		timezone := defaultDigestTimezone
+		if len(args) > 2 {
+			timezone = args[2]
+		}
+
+		job := &types.ScheduledJob{
+			Kind:           "digest",
+			SlackTeamID:    form.TeamId,
+			SlackChannelID: form.ChannelId,
+			Schedule:       args[1],
+			Timezone:       timezone,
+		}
+
+		err := upsertScheduledJob(repos.Jobs, job)
+		if err != nil {
+			return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not schedule the digest: %s.", err)}.Render()
+		}
+
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("The digest will be posted to this channel on `%s` (%s), next on *%s*.", job.Schedule, job.Timezone, formatInTimezone(job.NextRunAt, job.Timezone))}.Render()
+	case "off":
+		deleted, err := repos.Jobs.Delete("digest", form.TeamId, form.ChannelId)
+		if err != nil {
+			return slack.EphemeralMessage{Msg: "Could not turn off the digest."}.Render()
+		}
+
+		if !deleted {
+			return slack.EphemeralMessage{Msg: "There is no digest scheduled for this channel."}.Render()
+		}
+
+		return slack.EphemeralMessage{Msg: "The digest for this channel has been turned off."}.Render()
+	case "status":
+		job, err := repos.Jobs.Find("digest", form.TeamId, form.ChannelId)
+		if err != nil || job == nil {
+			return slack.EphemeralMessage{Msg: "There is no digest scheduled for this channel."}.Render()
+		}
+
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("The digest is posted to this channel on `%s` (%s), next on *%s*.", job.Schedule, job.Timezone, formatInTimezone(job.NextRunAt, job.Timezone))}.Render()
+	default:
+		return slack.EphemeralMessage{Msg: "Please use `digest`, `digest schedule <cron> [timezone]`, `digest status` or `digest off`."}.Render()
+	}
+}
+
+func runDigestJob(ctx context.Context, job *types.ScheduledJob, repos Repositories, stores StoreFactory) error {
+	user, err := repos.Users.FindByTeamID(job.SlackTeamID)
+	if err != nil {
+		return err
+	}
+
+	if !user.AppStoreBundleID.Valid || !user.SlackAccessToken.Valid {
+		return fmt.Errorf("digest: workspace %s is not fully connected", job.SlackTeamID)
+	}
+
+	var previous *digestSnapshot
+	if job.State != "" {
+		previous = &digestSnapshot{}
+		if err := json.Unmarshal([]byte(job.State), previous); err != nil {
+			previous = nil
+		}
+	}
+
+	digest, snapshot, err := compileDigest(ctx, stores.appStore(user), previous)
+	if err != nil {
+		return err
+	}
+
+	err = postMessageToSlack(ctx, user.SlackAccessToken.String, job.SlackChannelID, digest.Render())
+	if err != nil {
+		return err
+	}
+
+	state, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	return repos.Jobs.SaveState(job, string(state))
+}
+
+// compileDigest gathers the release state of the app into a digest, listing
+// whatever changed since the previous snapshot when one is given. What the
+// store could not tell is carried over from the previous snapshot, so that a
+// failing call is not taken for a change.
+func compileDigest(ctx context.Context, store StoreClient, previous *digestSnapshot) (slack.ReleaseDigest, digestSnapshot, error) {
+	snapshot := digestSnapshot{ChannelBuilds: map[string]string{}}
+	if previous != nil {
+		snapshot = *previous
+		snapshot.ChannelBuilds = map[string]string{}
+		for name, build := range previous.ChannelBuilds {
+			snapshot.ChannelBuilds[name] = build
+		}
+	}
+	digest := slack.ReleaseDigest{}
+
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return digest, snapshot, err
+	}
+
+	digest.AppId = appInfo.Id
+	digest.AppName = appInfo.Name
+
+	liveRelease, err := store.LiveRelease(ctx)
+	if err == nil {
+		snapshot.LiveVersion = liveRelease.VersionName
+		snapshot.LiveBuildNumber = liveRelease.BuildNumber
+		snapshot.PhasedReleaseDay = liveRelease.PhasedRelease.CurrentDayNumber
+		snapshot.PhasedReleaseState = liveRelease.PhasedRelease.PhasedReleaseState
+		release := toLiveRelease(appInfo.Id, liveRelease)
+		digest.LiveRelease = &release
+	}
+
+	inflightRelease, err := store.InflightRelease(ctx)
+	if err == nil {
+		snapshot.InflightVersion = inflightRelease.VersionName
+		snapshot.InflightBuild = inflightRelease.BuildNumber
+		snapshot.InflightState = inflightRelease.AppStoreState
+		if inflightRelease.VersionName != "" {
+			release := toInflightRelease(appInfo.Id, inflightRelease)
+			digest.InflightRelease = &release
+		}
+	}
+
+	appCurrentStatuses, err := store.CurrentStatus(ctx)
+	if err == nil {
+		snapshot.ChannelBuilds = map[string]string{}
+		for _, channelStatus := range appCurrentStatuses {
+			if len(channelStatus.Builds) == 0 {
+				continue
+			}
+
+			latest := channelStatus.Builds[0]
+			for _, build := range channelStatus.Builds[1:] {
+				if build.ReleaseDate.After(latest.ReleaseDate) {
+					latest = build
+				}
+			}
+
+			snapshot.ChannelBuilds[channelStatus.Name] = fmt.Sprintf("%s (%s)", latest.VersionString, latest.BuildNumber)
+			digest.Channels = append(digest.Channels, slack.DigestChannel{
+				Name:          channelStatus.Name,
+				VersionString: latest.VersionString,
+				BuildNumber:   latest.BuildNumber,
+				Status:        latest.Status,
+				ReleaseDate:   latest.ReleaseDate,
+			})
+		}
+	}
+
+	if previous != nil {
+		digest.Changes = diffDigestSnapshots(*previous, snapshot)
+		digest.ComparedToPrevious = true
+	}
+
+	return digest, snapshot, nil
+}
+
+func diffDigestSnapshots(previous digestSnapshot, current digestSnapshot) []string {
+	var changes []string
+
+	if previous.LiveVersion != current.LiveVersion || previous.LiveBuildNumber != current.LiveBuildNumber {
+		changes = append(changes, fmt.Sprintf("*%s (%s)* is now live, replacing *%s (%s)*.", current.LiveVersion, current.LiveBuildNumber, previous.LiveVersion, previous.LiveBuildNumber))
+	} else {
+		if previous.PhasedReleaseDay != current.PhasedReleaseDay {
+			changes = append(changes, fmt.Sprintf("Phased release moved from *day %d* to *day %d*.", previous.PhasedReleaseDay, current.PhasedReleaseDay))
+		}
+		if previous.PhasedReleaseState != current.PhasedReleaseState {
+			changes = append(changes, fmt.Sprintf("Phased release status changed from `%s` to `%s`.", previous.PhasedReleaseState, current.PhasedReleaseState))
+		}
+	}
+
+	if previous.InflightVersion != current.InflightVersion || previous.InflightBuild != current.InflightBuild {
+		if current.InflightVersion == "" {
+			changes = append(changes, fmt.Sprintf("*%s (%s)* is no longer inflight.", previous.InflightVersion, previous.InflightBuild))
+		} else {
+			changes = append(changes, fmt.Sprintf("*%s (%s)* is now the inflight release.", current.InflightVersion, current.InflightBuild))
+		}
+	} else if previous.InflightState != current.InflightState {
+		changes = append(changes, fmt.Sprintf("Inflight release status changed from `%s` to `%s`.", previous.InflightState, current.InflightState))
+	}
+
+	var channelNames []string
+	for name := range current.ChannelBuilds {
+		channelNames = append(channelNames, name)
+	}
+	sort.Strings(channelNames)
+
+	for _, name := range channelNames {
+		if previous.ChannelBuilds[name] != current.ChannelBuilds[name] {
+			changes = append(changes, fmt.Sprintf("*%s* now has *%s*.", strings.Title(name), current.ChannelBuilds[name]))
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDigestCarriesOverWhatTheStoreCouldNotTell(t *testing.T) {
+	store := newMemoryStore()
+
+	_, previous, err := compileDigest(context.Background(), store, nil)
+	if err != nil {
+		t.Fatalf("could not compile the first digest: %s", err)
+	}
+	if previous.LiveVersion == "" || previous.InflightVersion == "" {
+		t.Fatalf("the first snapshot is missing the releases: %+v", previous)
+	}
+
+	unavailable := statusError{service: "memory", statusCode: http.StatusServiceUnavailable}
+	store.failing = map[string]error{"LiveRelease": unavailable, "InflightRelease": unavailable, "CurrentStatus": unavailable}
+
+	digest, snapshot, err := compileDigest(context.Background(), store, &previous)
+	if err != nil {
+		t.Fatalf("could not compile the digest: %s", err)
+	}
+
+	if len(digest.Changes) != 0 {
+		t.Errorf("the failing store was reported as changes: %v", digest.Changes)
+	}
+	if !reflect.DeepEqual(snapshot, previous) {
+		t.Errorf("the snapshot became %+v, want the previous %+v", snapshot, previous)
+	}
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bytes"
+	"ciderbot/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	defaultPlayAPIHost    = "https://androidpublisher.googleapis.com"
+	androidPublisherScope = "https://www.googleapis.com/auth/androidpublisher"
+	productionTrack       = "production"
+)
+
+// Release statuses of a track in the Play Developer API.
+const (
+	playReleaseCompleted  = "completed"
+	playReleaseInProgress = "inProgress"
+	playReleaseHalted     = "halted"
+	playReleaseDraft      = "draft"
+)
+
+// Staged rollouts are mapped onto the states of an App Store phased release.
+var playReleaseStates = map[string]string{
+	playReleaseCompleted:  "COMPLETE",
+	playReleaseInProgress: "ACTIVE",
+	playReleaseHalted:     "PAUSED",
+	playReleaseDraft:      "DRAFT",
+}
+
+type playTrack struct {
+	Track    string        `json:"track"`
+	Releases []playRelease `json:"releases"`
+}
+
+// playRelease keeps the fields we do not change as they are, since a track
+// is updated by sending all of its releases back.
+type playRelease struct {
+	Name                string          `json:"name,omitempty"`
+	VersionCodes        []string        `json:"versionCodes,omitempty"`
+	Status              string          `json:"status"`
+	UserFraction        float64         `json:"userFraction,omitempty"`
+	ReleaseNotes        json.RawMessage `json:"releaseNotes,omitempty"`
+	CountryTargeting    json.RawMessage `json:"countryTargeting,omitempty"`
+	InAppUpdatePriority *int            `json:"inAppUpdatePriority,omitempty"`
+}
+
+type playStore struct {
+	credentials *types.PlayCredentials
+	client      *http.Client
+	clientErr   error
+}
+
+// newPlayStore signs in with the service account once, the client then reuses
+// the access token until it expires.
+func newPlayStore(credentials *types.PlayCredentials) playStore {
+	store := playStore{credentials: credentials}
+
+	jwtConfig, err := google.JWTConfigFromJSON(credentials.ServiceAccountJSON, androidPublisherScope)
+	if err != nil {
+		slog.Error("googleplay: could not read the service account", "error", err)
+		store.clientErr = err
+		return store
+	}
+
+	store.client = jwtConfig.Client(context.Background())
+	return store
+}
+
+func (store playStore) Platform() string {
+	return androidPlatform
+}
+
+func (store playStore) AppMetadata(ctx context.Context) (types.AppMetadata, error) {
+	appMetadata := types.AppMetadata{
+		Id:       store.credentials.PackageName,
+		BundleId: store.credentials.PackageName,
+	}
+
+	var title string
+	err := store.withEdit(ctx, false, func(editID string) error {
+		var details struct {
+			DefaultLanguage string `json:"defaultLanguage"`
+		}
+		if err := store.request(ctx, http.MethodGet, store.editURL(editID, "details"), nil, &details); err != nil {
+			return err
+		}
+
+		var listing struct {
+			Title string `json:"title"`
+		}
+		if err := store.request(ctx, http.MethodGet, store.editURL(editID, "listings/"+details.DefaultLanguage), nil, &listing); err != nil {
+			return err
+		}
+
+		title = listing.Title
+		return nil
+	})
+
+	appMetadata.Name = title
+	return appMetadata, err
+}
+
+func (store playStore) CurrentStatus(ctx context.Context) ([]types.AppCurrentStatus, error) {
+	var statuses []types.AppCurrentStatus
+
+	err := store.withEdit(ctx, false, func(editID string) error {
+		tracks, err := store.tracks(ctx, editID)
+		if err != nil {
+			return err
+		}
+
+		for _, track := range tracks {
+			status := types.AppCurrentStatus{Name: track.Track}
+			for _, release := range track.Releases {
+				build := struct {
+					Id            string    `json:"id"`
+					BuildNumber   string    `json:"build_number"`
+					Status        string    `json:"status"`
+					VersionString string    `json:"version_string"`
+					ReleaseDate   time.Time `json:"release_date"`
+				}{
+					Id:            strings.Join(release.VersionCodes, ", "),
+					BuildNumber:   strings.Join(release.VersionCodes, ", "),
+					Status:        release.Status,
+					VersionString: release.Name,
+				}
+				status.Builds = append(status.Builds, build)
+			}
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// BetaGroups has no equivalent on Google Play, testers are managed per track.
+func (store playStore) BetaGroups(ctx context.Context) ([]types.BetaGroup, error) {
+	return nil, errNotSupported
+}
+
+func (store playStore) InflightRelease(ctx context.Context) (types.Release, error) {
+	return types.Release{}, errNotSupported
+}
+
+func (store playStore) LiveRelease(ctx context.Context) (types.Release, error) {
+	var liveRelease types.Release
+
+	err := store.withEdit(ctx, false, func(editID string) error {
+		track, err := store.track(ctx, editID, productionTrack)
+		if err != nil {
+			return err
+		}
+
+		release, ok := liveTrackRelease(track)
+		if !ok {
+			return fmt.Errorf("googleplay: nothing has been released to %s", productionTrack)
+		}
+
+		liveRelease = toPlayRelease(release)
+		return nil
+	})
+
+	return liveRelease, err
+}
+
+func (store playStore) PauseRollout(ctx context.Context) (types.Release, error) {
+	return store.updateRollout(ctx, playReleaseInProgress, playReleaseHalted)
+}
+
+func (store playStore) ResumeRollout(ctx context.Context) (types.Release, error) {
+	return store.updateRollout(ctx, playReleaseHalted, playReleaseInProgress)
+}
+
+func (store playStore) CompleteRollout(ctx context.Context) (types.Release, error) {
+	var liveRelease types.Release
+
+	err := store.withEdit(ctx, true, func(editID string) error {
+		track, err := store.track(ctx, editID, productionTrack)
+		if err != nil {
+			return err
+		}
+
+		release, ok := stagedTrackRelease(track)
+		if !ok {
+			return fmt.Errorf("googleplay: there is no staged rollout to complete")
+		}
+
+		// A completed release replaces the one it was rolled out over
+		release.Status = playReleaseCompleted
+		release.UserFraction = 0
+		track.Releases = []playRelease{release}
+
+		if err := store.request(ctx, http.MethodPut, store.editURL(editID, "tracks/"+productionTrack), track, &track); err != nil {
+			return err
+		}
+
+		liveRelease = toPlayRelease(release)
+		return nil
+	})
+
+	return liveRelease, err
+}
+
+// The rest of the commands are about App Store versions, builds, App Review
+// and reviews, which the Google Play commands do not cover.
+func (store playStore) Builds(ctx context.Context, versionString string, limit int) ([]types.Build, error) {
+	return nil, errNotSupported
+}
+
+func (store playStore) Build(ctx context.Context, buildNumber string) (types.Build, error) {
+	return types.Build{}, errNotSupported
+}
+
+func (store playStore) AttachBuild(ctx context.Context, buildID string) (types.Release, error) {
+	return types.Release{}, errNotSupported
+}
+
+func (store playStore) CreateVersion(ctx context.Context, versionString string, releaseType string, earliestReleaseDate *time.Time, phasedRelease bool) (types.Release, error) {
+	return types.Release{}, errNotSupported
+}
+
+func (store playStore) UpdateInflightRelease(ctx context.Context, changes map[string]interface{}) (types.Release, error) {
+	return types.Release{}, errNotSupported
+}
+
+func (store playStore) ReleaseInflight(ctx context.Context) (types.Release, error) {
+	return types.Release{}, errNotSupported
+}
+
+func (store playStore) InflightLocalizations(ctx context.Context) ([]types.Localization, error) {
+	return nil, errNotSupported
+}
+
+func (store playStore) LiveLocalizations(ctx context.Context) ([]types.Localization, error) {
+	return nil, errNotSupported
+}
+
+func (store playStore) ReviewSubmission(ctx context.Context) (types.ReviewSubmission, error) {
+	return types.ReviewSubmission{}, errNotSupported
+}
+
+func (store playStore) ResolutionCenterMessages(ctx context.Context) ([]types.ResolutionCenterMessage, error) {
+	return nil, errNotSupported
+}
+
+func (store playStore) CustomerReviews(ctx context.Context, territory string, limit int) ([]types.CustomerReview, error) {
+	return nil, errNotSupported
+}
+
+func (store playStore) ReplyToReview(ctx context.Context, reviewID string, responseBody string) (types.CustomerReviewResponse, error) {
+	return types.CustomerReviewResponse{}, errNotSupported
+}
+
+func (store playStore) SalesReport(ctx context.Context, vendorNumber string, reportDate time.Time) ([]byte, error) {
+	return nil, errNotSupported
+}
+
+func (store playStore) updateRollout(ctx context.Context, fromStatus string, toStatus string) (types.Release, error) {
+	var liveRelease types.Release
+
+	err := store.withEdit(ctx, true, func(editID string) error {
+		track, err := store.track(ctx, editID, productionTrack)
+		if err != nil {
+			return err
+		}
+
+		found := false
+		for i, release := range track.Releases {
+			if release.Status == fromStatus {
+				track.Releases[i].Status = toStatus
+				liveRelease = toPlayRelease(track.Releases[i])
+				found = true
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("googleplay: there is no %s release on %s", fromStatus, productionTrack)
+		}
+
+		return store.request(ctx, http.MethodPut, store.editURL(editID, "tracks/"+productionTrack), track, &track)
+	})
+
+	return liveRelease, err
+}
+
+// withEdit runs the given requests inside an edit, which is how the Play
+// Developer API groups changes. Read only edits are thrown away afterwards.
+func (store playStore) withEdit(ctx context.Context, commit bool, fn func(editID string) error) error {
+	var edit struct {
+		Id string `json:"id"`
+	}
+
+	editsURL := fmt.Sprintf("%s/androidpublisher/v3/applications/%s/edits", playAPIHost, store.credentials.PackageName)
+	if err := store.request(ctx, http.MethodPost, editsURL, struct{}{}, &edit); err != nil {
+		return err
+	}
+
+	err := fn(edit.Id)
+	if err != nil || !commit {
+		store.request(ctx, http.MethodDelete, fmt.Sprintf("%s/%s", editsURL, edit.Id), nil, nil)
+		return err
+	}
+
+	return store.request(ctx, http.MethodPost, fmt.Sprintf("%s/%s:commit", editsURL, edit.Id), nil, nil)
+}
+
+func (store playStore) editURL(editID string, path string) string {
+	return fmt.Sprintf("%s/androidpublisher/v3/applications/%s/edits/%s/%s", playAPIHost, store.credentials.PackageName, editID, path)
+}
+
+func (store playStore) tracks(ctx context.Context, editID string) ([]playTrack, error) {
+	var response struct {
+		Tracks []playTrack `json:"tracks"`
+	}
+
+	err := store.request(ctx, http.MethodGet, store.editURL(editID, "tracks"), nil, &response)
+	return response.Tracks, err
+}
+
+func (store playStore) track(ctx context.Context, editID string, name string) (playTrack, error) {
+	var track playTrack
+	err := store.request(ctx, http.MethodGet, store.editURL(editID, "tracks/"+name), nil, &track)
+	return track, err
+}
+
+func (store playStore) request(ctx context.Context, httpMethod string, requestURL string, payload interface{}, response interface{}) error {
+	if store.clientErr != nil {
+		return store.clientErr
+	}
+
+	var requestBody io.Reader
+	if payload != nil {
+		encodedPayload, err := json.Marshal(payload)
+		if err != nil {
+			slog.ErrorContext(ctx, "googleplay: could not encode request body", "error", err)
+			return err
+		}
+		requestBody = bytes.NewReader(encodedPayload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, httpMethod, requestURL, requestBody)
+	if err != nil {
+		slog.ErrorContext(ctx, "googleplay: could not create request", "error", err)
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := store.client.Do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "googleplay: request failed", "error", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return statusError{service: "googleplay", statusCode: resp.StatusCode}
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(response)
+	if err != nil {
+		slog.ErrorContext(ctx, "googleplay: could not parse response body", "error", err)
+	}
+
+	return err
+}
+
+// liveTrackRelease is the release being rolled out, or the last one that was completed.
+func liveTrackRelease(track playTrack) (playRelease, bool) {
+	if release, ok := stagedTrackRelease(track); ok {
+		return release, true
+	}
+
+	for _, release := range track.Releases {
+		if release.Status == playReleaseCompleted {
+			return release, true
+		}
+	}
+
+	return playRelease{}, false
+}
+
+func stagedTrackRelease(track playTrack) (playRelease, bool) {
+	for _, release := range track.Releases {
+		if release.Status == playReleaseInProgress || release.Status == playReleaseHalted {
+			return release, true
+		}
+	}
+
+	return playRelease{}, false
+}
+
+func toPlayRelease(release playRelease) types.Release {
+	liveRelease := types.Release{
+		VersionName: release.Name,
+		BuildNumber: strings.Join(release.VersionCodes, ", "),
+	}
+
+	liveRelease.PhasedRelease.PhasedReleaseState = playReleaseStates[release.Status]
+	liveRelease.PhasedRelease.UserFraction = release.UserFraction
+	if release.Status == playReleaseCompleted {
+		liveRelease.PhasedRelease.UserFraction = 1
+	}
+
+	return liveRelease
+}
+
+func userPlayCredentials(user *types.User) *types.PlayCredentials {
+	return &types.PlayCredentials{
+		PackageName:        user.PlayPackageName.String,
+		ServiceAccountJSON: decrypt(user.PlayServiceAccount, []byte(encryptionKey), user.PlayServiceAccountIV),
+	}
+}
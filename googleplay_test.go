@@ -0,0 +1,157 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakePlay is a local Play Developer API with one production track. It
+// records the requests it gets and the track as it was last sent back.
+type fakePlay struct {
+	track    playTrack
+	requests []string
+	puts     []playTrack
+}
+
+func newFakePlay(t *testing.T, releases ...playRelease) (*fakePlay, playStore) {
+	t.Helper()
+
+	fake := &fakePlay{track: playTrack{Track: productionTrack, Releases: releases}}
+	editsPath := "/androidpublisher/v3/applications/com.example.ciderbot/edits"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.requests = append(fake.requests, r.Method+" "+strings.TrimPrefix(r.URL.Path, editsPath))
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == editsPath:
+			io.WriteString(w, `{"id": "edit-1"}`)
+		case r.Method == http.MethodGet && r.URL.Path == editsPath+"/edit-1/tracks/production":
+			json.NewEncoder(w).Encode(fake.track)
+		case r.Method == http.MethodPut && r.URL.Path == editsPath+"/edit-1/tracks/production":
+			var track playTrack
+			if err := json.NewDecoder(r.Body).Decode(&track); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fake.puts = append(fake.puts, track)
+			json.NewEncoder(w).Encode(track)
+		case r.Method == http.MethodPost && r.URL.Path == editsPath+"/edit-1:commit":
+			fake.track = fake.puts[len(fake.puts)-1]
+			io.WriteString(w, `{"id": "edit-1"}`)
+		case r.Method == http.MethodDelete && r.URL.Path == editsPath+"/edit-1":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	host := playAPIHost
+	playAPIHost = server.URL
+	t.Cleanup(func() { playAPIHost = host })
+
+	store := playStore{
+		credentials: &types.PlayCredentials{PackageName: "com.example.ciderbot"},
+		client:      server.Client(),
+	}
+
+	return fake, store
+}
+
+func TestPlayLiveReleaseReadsTheStagedRollout(t *testing.T) {
+	fake, store := newFakePlay(t,
+		playRelease{Name: "2.1.0", VersionCodes: []string{"210"}, Status: playReleaseInProgress, UserFraction: 0.2},
+		playRelease{Name: "2.0.0", VersionCodes: []string{"200"}, Status: playReleaseCompleted},
+	)
+
+	release, err := store.LiveRelease(context.Background())
+	if err != nil {
+		t.Fatalf("could not get the live release: %s", err)
+	}
+
+	if release.VersionName != "2.1.0" || release.PhasedRelease.PhasedReleaseState != "ACTIVE" || release.PhasedRelease.UserFraction != 0.2 {
+		t.Errorf("the live release is %s %s at %v, want 2.1.0 ACTIVE at 0.2", release.VersionName, release.PhasedRelease.PhasedReleaseState, release.PhasedRelease.UserFraction)
+	}
+
+	want := []string{"POST ", "GET /edit-1/tracks/production", "DELETE /edit-1"}
+	if strings.Join(fake.requests, ",") != strings.Join(want, ",") {
+		t.Errorf("the requests were %v, want the read only edit %v", fake.requests, want)
+	}
+}
+
+func TestPlayPauseRolloutCommitsTheEdit(t *testing.T) {
+	fake, store := newFakePlay(t,
+		playRelease{Name: "2.1.0", VersionCodes: []string{"210"}, Status: playReleaseInProgress, UserFraction: 0.2},
+		playRelease{Name: "2.0.0", VersionCodes: []string{"200"}, Status: playReleaseCompleted},
+	)
+
+	release, err := store.PauseRollout(context.Background())
+	if err != nil {
+		t.Fatalf("could not halt the rollout: %s", err)
+	}
+	if release.PhasedRelease.PhasedReleaseState != "PAUSED" || release.PhasedRelease.UserFraction != 0.2 {
+		t.Errorf("the halted release is %s at %v, want PAUSED at 0.2", release.PhasedRelease.PhasedReleaseState, release.PhasedRelease.UserFraction)
+	}
+
+	want := []string{"POST ", "GET /edit-1/tracks/production", "PUT /edit-1/tracks/production", "POST /edit-1:commit"}
+	if strings.Join(fake.requests, ",") != strings.Join(want, ",") {
+		t.Fatalf("the requests were %v, want %v", fake.requests, want)
+	}
+
+	// The release that was rolled out over has to be sent back untouched
+	if len(fake.track.Releases) != 2 || fake.track.Releases[0].Status != playReleaseHalted || fake.track.Releases[0].UserFraction != 0.2 || fake.track.Releases[1].Status != playReleaseCompleted {
+		t.Errorf("the committed track is %+v", fake.track)
+	}
+
+	release, err = store.ResumeRollout(context.Background())
+	if err != nil {
+		t.Fatalf("could not resume the rollout: %s", err)
+	}
+	if release.PhasedRelease.PhasedReleaseState != "ACTIVE" || fake.track.Releases[0].Status != playReleaseInProgress {
+		t.Errorf("the rollout was not resumed: %+v", fake.track)
+	}
+}
+
+func TestPlayCompleteRolloutReleasesToEveryone(t *testing.T) {
+	fake, store := newFakePlay(t,
+		playRelease{Name: "2.1.0", VersionCodes: []string{"210"}, Status: playReleaseHalted, UserFraction: 0.5},
+		playRelease{Name: "2.0.0", VersionCodes: []string{"200"}, Status: playReleaseCompleted},
+	)
+
+	release, err := store.CompleteRollout(context.Background())
+	if err != nil {
+		t.Fatalf("could not complete the rollout: %s", err)
+	}
+	if release.PhasedRelease.PhasedReleaseState != "COMPLETE" || release.PhasedRelease.UserFraction != 1 {
+		t.Errorf("the completed release is %s at %v, want COMPLETE at 1", release.PhasedRelease.PhasedReleaseState, release.PhasedRelease.UserFraction)
+	}
+
+	if len(fake.track.Releases) != 1 || fake.track.Releases[0].Name != "2.1.0" || fake.track.Releases[0].Status != playReleaseCompleted || fake.track.Releases[0].UserFraction != 0 {
+		t.Errorf("the committed track is %+v, want only 2.1.0 completed without a fraction", fake.track)
+	}
+}
+
+func TestPlayDiscardsTheEditWhenThereIsNothingToChange(t *testing.T) {
+	fake, store := newFakePlay(t,
+		playRelease{Name: "2.0.0", VersionCodes: []string{"200"}, Status: playReleaseCompleted},
+	)
+
+	if _, err := store.PauseRollout(context.Background()); err == nil {
+		t.Fatalf("a completed release was halted")
+	}
+
+	for _, request := range fake.requests {
+		if strings.HasPrefix(request, "PUT") || strings.HasSuffix(request, ":commit") {
+			t.Errorf("the edit was changed: %v", fake.requests)
+		}
+	}
+	if fake.requests[len(fake.requests)-1] != "DELETE /edit-1" {
+		t.Errorf("the edit was not deleted: %v", fake.requests)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	slack "ciderbot/slack"
+	"ciderbot/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Day based guardrails have no schedule of their own, they are checked on this one instead.
+const guardrailPollSchedule = "*/15 * * * *"
+
+type guardrailRule struct {
+	Action       string `json:"action"`
+	MinPhasedDay int    `json:"min_phased_day,omitempty"`
+}
+
+var guardrailUndoActions = map[string]string{
+	"pause":  "resume",
+	"resume": "pause",
+}
+
+func (rule guardrailRule) describe(job types.ScheduledJob) string {
+	if rule.MinPhasedDay > 0 {
+		return fmt.Sprintf("`%s` when the phased release reaches *day %d*", rule.Action, rule.MinPhasedDay)
+	}
+
+	return fmt.Sprintf("`%s` on `%s` (%s)", rule.Action, job.Schedule, job.Timezone)
+}
+
+func handleGuardrailsCommand(ctx context.Context, form types.SlackFormData, user *types.User, repos Repositories) types.SlackResponse {
+	args := commandArgs(form.Text)
+
+	if len(args) == 0 || args[0] == "list" {
+		return listGuardrails(repos.Jobs, form.TeamId)
+	}
+
+	switch args[0] {
+	case "add":
+		return addGuardrail(repos.Jobs, form, args[1:])
+	case "remove":
+		if len(args) < 2 {
+			return slack.EphemeralMessage{Msg: "Please provide the guardrail to remove, e.g. `guardrails remove 3`."}.Render()
+		}
+
+		id, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return slack.EphemeralMessage{Msg: fmt.Sprintf("`%s` is not a valid guardrail.", args[1])}.Render()
+		}
+
+		deleted, err := repos.Jobs.DeleteByID("guardrail", form.TeamId, uint(id))
+		if err != nil || !deleted {
+			return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not find guardrail #%d.", id)}.Render()
+		}
+
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("Guardrail #%d has been removed.", id)}.Render()
+	default:
+		return slack.EphemeralMessage{Msg: "Please use `guardrails list`, `guardrails add <pause|resume> <cron> [timezone]`, `guardrails add release_to_all day <n>` or `guardrails remove <id>`."}.Render()
+	}
+}
+
+func addGuardrail(jobs ScheduledJobRepository, form types.SlackFormData, args []string) types.SlackResponse {
+	if len(args) < 2 {
+		return slack.EphemeralMessage{Msg: "Please provide an action and when to run it, e.g. `guardrails add pause \"0 17 * * 5\" America/New_York`."}.Render()
+	}
+
+	rule := guardrailRule{Action: args[0]}
+	job := &types.ScheduledJob{
+		Kind:           "guardrail",
+		SlackTeamID:    form.TeamId,
+		SlackChannelID: form.ChannelId,
+		Timezone:       defaultDigestTimezone,
+	}
+
+	switch rule.Action {
+	case "pause", "resume":
+		job.Schedule = args[1]
+		if len(args) > 2 {
+			job.Timezone = args[2]
+		}
+	case "release_to_all":
+		if len(args) < 3 || args[1] != "day" {
+			return slack.EphemeralMessage{Msg: "Please provide the day of the phased release, e.g. `guardrails add release_to_all day 5`."}.Render()
+		}
+
+		day, err := strconv.Atoi(args[2])
+		if err != nil || day < 1 || day > 7 {
+			return slack.EphemeralMessage{Msg: "The day of the phased release should be between 1 and 7."}.Render()
+		}
+
+		rule.MinPhasedDay = day
+		job.Schedule = guardrailPollSchedule
+	default:
+		return slack.EphemeralMessage{Msg: "A guardrail can only `pause`, `resume` or `release_to_all`."}.Render()
+	}
+
+	payload, err := json.Marshal(rule)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not add the guardrail."}.Render()
+	}
+	job.Payload = string(payload)
+
+	err = createScheduledJob(jobs, job)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not add the guardrail: %s.", err)}.Render()
+	}
+
+	return slack.EphemeralMessage{Msg: fmt.Sprintf("Added guardrail #%d to %s, announced in this channel.", job.ID, rule.describe(*job))}.Render()
+}
+
+func listGuardrails(scheduledJobs ScheduledJobRepository, teamID string) types.SlackResponse {
+	jobs, err := scheduledJobs.List("guardrail", teamID)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not fetch the guardrails."}.Render()
+	}
+
+	guardrails := slack.Guardrails{}
+	for _, job := range jobs {
+		var rule guardrailRule
+		if err := json.Unmarshal([]byte(job.Payload), &rule); err != nil {
+			continue
+		}
+
+		guardrails.Rules = append(guardrails.Rules, slack.Guardrail{
+			Id:          job.ID,
+			Description: rule.describe(job),
+			ChannelId:   job.SlackChannelID,
+		})
+	}
+
+	return guardrails.Render()
+}
+
+func runGuardrailJob(ctx context.Context, job *types.ScheduledJob, repos Repositories, stores StoreFactory) error {
+	var rule guardrailRule
+	if err := json.Unmarshal([]byte(job.Payload), &rule); err != nil {
+		return err
+	}
+
+	user, err := repos.Users.FindByTeamID(job.SlackTeamID)
+	if err != nil {
+		return err
+	}
+
+	if !user.AppStoreBundleID.Valid || !user.SlackAccessToken.Valid {
+		return fmt.Errorf("guardrail: workspace %s is not fully connected", job.SlackTeamID)
+	}
+
+	store := stores.appStore(user)
+	liveRelease, err := store.LiveRelease(ctx)
+	if err != nil {
+		return err
+	}
+
+	phasedRelease := liveRelease.PhasedRelease
+	switch rule.Action {
+	case "pause":
+		if phasedRelease.PhasedReleaseState != "ACTIVE" {
+			return nil
+		}
+		liveRelease, err = store.PauseRollout(ctx)
+	case "resume":
+		if phasedRelease.PhasedReleaseState != "PAUSED" {
+			return nil
+		}
+		liveRelease, err = store.ResumeRollout(ctx)
+	case "release_to_all":
+		if phasedRelease.PhasedReleaseState != "ACTIVE" || phasedRelease.CurrentDayNumber < rule.MinPhasedDay {
+			return nil
+		}
+		liveRelease, err = store.CompleteRollout(ctx)
+	default:
+		return fmt.Errorf("guardrail: unknown action %s", rule.Action)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	announcement := slack.GuardrailAction{
+		Title:       ":robot_face: Guardrail Triggered",
+		Description: fmt.Sprintf("Guardrail #%d ran %s.", job.ID, rule.describe(*job)),
+		UndoAction:  guardrailUndoActions[rule.Action],
+		Release:     toLiveRelease(appInfo.Id, liveRelease),
+	}
+
+	return postMessageToSlack(ctx, user.SlackAccessToken.String, job.SlackChannelID, announcement.Render())
+}
+
+func handleGuardrailUndoAction(ctx context.Context, interaction types.SlackInteraction, action types.SlackAction, user *types.User, repos Repositories, store StoreClient) types.SlackResponse {
+	var liveRelease types.Release
+	var err error
+	switch action.Value {
+	case "pause":
+		liveRelease, err = store.PauseRollout(ctx)
+	case "resume":
+		liveRelease, err = store.ResumeRollout(ctx)
+	default:
+		return slack.EphemeralMessage{Msg: "This guardrail action cannot be undone."}.Render()
+	}
+
+	if err != nil {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not %s the live release.", action.Value)}.Render()
+	}
+
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find your app."}.Render()
+	}
+
+	response := slack.GuardrailAction{
+		Title:       ":leftwards_arrow_with_hook: Guardrail Undone",
+		Description: fmt.Sprintf("<@%s> undid the guardrail, the phased release was %sd.", interaction.User.Id, action.Value),
+		Release:     toLiveRelease(appInfo.Id, liveRelease),
+	}.Render()
+	response.ReplaceOriginal = true
+
+	return response
+}
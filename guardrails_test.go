@@ -0,0 +1,102 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"strings"
+	"testing"
+)
+
+// addTestGuardrail adds the guardrail of the command text to the channel C0001.
+func addTestGuardrail(t *testing.T, repos Repositories, text string) *types.ScheduledJob {
+	t.Helper()
+
+	response := responseText(t, handleGuardrailsCommand(context.Background(), testForm(text), testUser(), repos))
+	if !strings.Contains(response, "Added guardrail") {
+		t.Fatalf("the guardrail was not added: %s", response)
+	}
+
+	jobs, err := repos.Jobs.List("guardrail", "T0001")
+	if err != nil || len(jobs) == 0 {
+		t.Fatalf("there is no guardrail (%v)", err)
+	}
+	return &jobs[len(jobs)-1]
+}
+
+func TestGuardrailPausesTheRolloutOnce(t *testing.T) {
+	slackAPI := fakeSlackAPI(t)
+	store := newMemoryStore()
+	repos := newMemoryRepositories()
+	connectTestWorkspace(t, repos)
+	stores := func(user *types.User) StoreClient { return store }
+
+	job := addTestGuardrail(t, repos, `guardrails add pause "0 17 * * 5"`)
+	for i := 0; i < 2; i++ {
+		if err := runGuardrailJob(context.Background(), job, repos, stores); err != nil {
+			t.Fatalf("run %d of the guardrail failed: %s", i+1, err)
+		}
+	}
+
+	if store.calledTimes("PauseRollout") != 1 || store.liveRelease.PhasedRelease.PhasedReleaseState != "PAUSED" {
+		t.Errorf("the rollout was paused %d times and is %s", store.calledTimes("PauseRollout"), store.liveRelease.PhasedRelease.PhasedReleaseState)
+	}
+	if posts := slackAPI.made("chat.postMessage"); len(posts) != 1 || !strings.Contains(posts[0].Body, "Guardrail Triggered") {
+		t.Errorf("the guardrail was announced %d times: %v", len(posts), posts)
+	}
+}
+
+func TestGuardrailReleasesToAllFromItsDay(t *testing.T) {
+	fakeSlackAPI(t)
+	store := newMemoryStore()
+	repos := newMemoryRepositories()
+	connectTestWorkspace(t, repos)
+	stores := func(user *types.User) StoreClient { return store }
+
+	// The live release is on day 3
+	job := addTestGuardrail(t, repos, "guardrails add release_to_all day 4")
+	if err := runGuardrailJob(context.Background(), job, repos, stores); err != nil {
+		t.Fatalf("the guardrail failed: %s", err)
+	}
+	if store.called("CompleteRollout") {
+		t.Fatalf("the rollout was completed before day 4")
+	}
+
+	store.liveRelease.PhasedRelease.CurrentDayNumber = 4
+	if err := runGuardrailJob(context.Background(), job, repos, stores); err != nil {
+		t.Fatalf("the guardrail failed: %s", err)
+	}
+	if !store.called("CompleteRollout") {
+		t.Errorf("the rollout was not completed on day 4")
+	}
+}
+
+func TestGuardrailKeepsToTheAppStoreAfterSwitchingToAndroid(t *testing.T) {
+	fakeSlackAPI(t)
+	store := newMemoryStore()
+	playStore := newMemoryStore()
+	playStore.err = errNotSupported
+	repos := newMemoryRepositories()
+	connectTestWorkspace(t, repos)
+	stores := func(user *types.User) StoreClient {
+		if user.Platform == androidPlatform {
+			return playStore
+		}
+		return store
+	}
+
+	job := addTestGuardrail(t, repos, `guardrails add pause "0 17 * * 5"`)
+	user, err := repos.Users.FindByTeamID("T0001")
+	if err != nil {
+		t.Fatalf("could not find the workspace: %s", err)
+	}
+	if err := repos.Users.SetPlatform(user, androidPlatform); err != nil {
+		t.Fatalf("could not switch to android: %s", err)
+	}
+
+	if err := runGuardrailJob(context.Background(), job, repos, stores); err != nil {
+		t.Fatalf("the guardrail failed: %s", err)
+	}
+	if !store.called("PauseRollout") || len(playStore.calls) > 0 {
+		t.Errorf("the guardrail went to Google Play: %v", playStore.calls)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"ciderbot/types"
+	"context"
 	"crypto/aes"
 	"crypto/hmac"
 	"crypto/rand"
@@ -11,6 +12,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
@@ -19,19 +21,19 @@ import (
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/oauth2"
-	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 const LANDING_PAGE_URL = "https://appstoreslackbot.com"
 const APP_STORE_CONNECT_FAILURE = "Failed to connect to the App Store! Please try again."
 const SLACK_CONNECT_FAILURE = "Failed to authorize Slack! Please try again."
+const GOOGLE_PLAY_CONNECT_FAILURE = "Failed to connect to Google Play! Please try again."
 
-func handleAppStoreCreds() gin.HandlerFunc {
+func handleAppStoreCreds(repos Repositories, stores StoreFactory) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		bundleID := c.PostForm("bundle-id")
 		issuerID := c.PostForm("issuer-id")
 		keyID := c.PostForm("key-id")
+		vendorNumber := strings.TrimSpace(c.PostForm("vendor-number"))
 		file, err := c.FormFile("p8-file")
 		if err != nil {
 			redirectWithFlash(c, APP_STORE_CONNECT_FAILURE)
@@ -53,39 +55,116 @@ func handleAppStoreCreds() gin.HandlerFunc {
 			return
 		}
 
-		// Validate app store creds
-		err = validateAppStoreCreds(bundleID, issuerID, keyID, p8FileBytes)
+		// Generate a random IV (Initialization Vector)
+		iv := make([]byte, aes.BlockSize)
+		if _, err := rand.Read(iv); err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		// Encrypt the file data using AES encryption
+		encryptedP8File := encrypt(p8FileBytes, []byte(encryptionKey), iv)
+
+		// Validate app store creds against the store the commands will use
+		candidate := types.User{
+			Platform:         iosPlatform,
+			AppStoreBundleID: sql.NullString{String: bundleID, Valid: true},
+			AppStoreIssuerID: sql.NullString{String: issuerID, Valid: true},
+			AppStoreKeyID:    sql.NullString{String: keyID, Valid: true},
+			AppStoreP8File:   encryptedP8File,
+			AppStoreP8FileIV: iv,
+		}
+		_, err = stores(&candidate).AppMetadata(c.Request.Context())
 		if err != nil {
-			fmt.Printf("Validation of apple credentials failed with : %s\n", err)
+			slog.WarnContext(c.Request.Context(), "auth: could not validate the apple credentials", "error", err)
 			redirectWithFlash(c, APP_STORE_CONNECT_FAILURE)
 			return
 		}
 
-		// Generate a random IV (Initialization Vector)
+		userValue, _ := c.Get("user")
+		user, _ := userValue.(*types.User)
+		app := appStoreApp{
+			BundleID:     bundleID,
+			IssuerID:     issuerID,
+			KeyID:        keyID,
+			VendorNumber: vendorNumber,
+			P8File:       encryptedP8File,
+			P8FileIV:     iv,
+		}
+
+		err = repos.Apps.ConnectAppStore(user, app)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		recordAuditEvent(c.Request.Context(), repos, user, auditAppStoreConnected, bundleID)
+
+		c.Redirect(http.StatusFound, "/")
+	}
+}
+
+func handleGooglePlayCreds(repos Repositories, stores StoreFactory) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		packageName := strings.TrimSpace(c.PostForm("package-name"))
+		file, err := c.FormFile("service-account-file")
+		if err != nil || packageName == "" {
+			redirectWithFlash(c, GOOGLE_PLAY_CONNECT_FAILURE)
+			return
+		}
+
+		fileData, err := file.Open()
+		if err != nil {
+			redirectWithFlash(c, GOOGLE_PLAY_CONNECT_FAILURE)
+			return
+		}
+		defer fileData.Close()
+
+		serviceAccountBytes, err := io.ReadAll(fileData)
+		if err != nil {
+			redirectWithFlash(c, GOOGLE_PLAY_CONNECT_FAILURE)
+			return
+		}
+
 		iv := make([]byte, aes.BlockSize)
 		if _, err := rand.Read(iv); err != nil {
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
 
-		// Encrypt the file data using AES encryption
-		encryptedP8File := encrypt(p8FileBytes, []byte(encryptionKey), iv)
+		encryptedServiceAccount := encrypt(serviceAccountBytes, []byte(encryptionKey), iv)
+
+		// Validate the service account against the Play Developer API
+		candidate := types.User{
+			Platform:             androidPlatform,
+			PlayPackageName:      sql.NullString{String: packageName, Valid: true},
+			PlayConnected:        true,
+			PlayServiceAccount:   encryptedServiceAccount,
+			PlayServiceAccountIV: iv,
+		}
+		_, err = stores(&candidate).AppMetadata(c.Request.Context())
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "auth: could not validate the google play credentials", "error", err)
+			redirectWithFlash(c, GOOGLE_PLAY_CONNECT_FAILURE)
+			return
+		}
 
 		userValue, _ := c.Get("user")
 		user, _ := userValue.(*types.User)
-		user.AppStoreKeyID = sql.NullString{String: keyID, Valid: true}
-		user.AppStoreBundleID = sql.NullString{String: bundleID, Valid: true}
-		user.AppStoreIssuerID = sql.NullString{String: issuerID, Valid: true}
-		user.AppStoreP8File = encryptedP8File
-		user.AppStoreP8FileIV = iv
-		user.AppStoreConnected = true
+		app := googlePlayApp{
+			PackageName:      packageName,
+			ServiceAccount:   encryptedServiceAccount,
+			ServiceAccountIV: iv,
+		}
 
-		result := db.Save(&user)
-		if result.Error != nil {
-			c.AbortWithError(http.StatusInternalServerError, result.Error)
+		err = repos.Apps.ConnectGooglePlay(user, app)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
 
+		recordAuditEvent(c.Request.Context(), repos, user, auditPlayConnected, packageName)
+
 		c.Redirect(http.StatusFound, "/")
 	}
 }
@@ -97,7 +176,7 @@ func handleSlackAuth() gin.HandlerFunc {
 	}
 }
 
-func handleSlackAuthCallback() gin.HandlerFunc {
+func handleSlackAuthCallback(repos Repositories) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		code := c.Query("code")
 		userValue, _ := c.Get("user")
@@ -110,25 +189,30 @@ func handleSlackAuthCallback() gin.HandlerFunc {
 		}
 
 		team := token.Extra("team").(map[string]interface{})
-		slackTeamID := team["id"].(string)
-		slackTeamName := team["name"].(string)
-		user.SlackAccessToken = sql.NullString{String: token.AccessToken, Valid: true}
-		user.SlackRefreshToken = sql.NullString{String: token.RefreshToken, Valid: true}
-		user.SlackTeamID = sql.NullString{String: slackTeamID, Valid: true}
-		user.SlackTeamName = sql.NullString{String: slackTeamName, Valid: true}
-		result := db.Save(&user)
-		if result.Error != nil {
-			c.AbortWithError(http.StatusInternalServerError, result.Error)
+		scopes, _ := token.Extra("scope").(string)
+		workspace := slackWorkspace{
+			TeamID:       team["id"].(string),
+			TeamName:     team["name"].(string),
+			AccessToken:  token.AccessToken,
+			RefreshToken: token.RefreshToken,
+			Scopes:       scopes,
+		}
+
+		err = repos.Users.ConnectWorkspace(user, workspace)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
 
+		recordAuditEvent(c.Request.Context(), repos, user, auditSlackConnected, workspace.TeamName)
+
 		c.Redirect(http.StatusFound, "/")
 	}
 }
 
-func handleHome() gin.HandlerFunc {
+func handleHome(users UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		user, err := getUserFromSession(c)
+		user, err := getUserFromSession(c, users)
 		session := sessions.Default(c)
 		flashMessages := session.Flashes()
 		session.Save()
@@ -136,14 +220,18 @@ func handleHome() gin.HandlerFunc {
 		if err != nil {
 			c.HTML(http.StatusOK, "login.html", gin.H{"user": user})
 		} else {
-			c.HTML(http.StatusOK, "index.html", gin.H{"user": user, "flashErrors": flashMessages})
+			c.HTML(http.StatusOK, "index.html", gin.H{
+				"user":                user,
+				"flashErrors":         flashMessages,
+				"slackNeedsReinstall": user.SlackAccessToken.Valid && !hasSlackScope(user, slackFilesWriteScope),
+			})
 		}
 	}
 }
 
-func handleLogin() gin.HandlerFunc {
+func handleLogin(users UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		_, err := getUserFromSession(c)
+		_, err := getUserFromSession(c, users)
 		if err != nil {
 			authURL := googleOAuthConf.AuthCodeURL("state")
 			c.Redirect(http.StatusFound, authURL)
@@ -154,7 +242,7 @@ func handleLogin() gin.HandlerFunc {
 	}
 }
 
-func handleGoogleCallback(db *gorm.DB) gin.HandlerFunc {
+func handleGoogleCallback(repos Repositories) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get the authorization code from the query parameters
 		code := c.Query("code")
@@ -196,19 +284,14 @@ func handleGoogleCallback(db *gorm.DB) gin.HandlerFunc {
 			AvatarURL:  sql.NullString{String: profile.AvatarURL, Valid: true},
 		}
 
-		result := db.Clauses(clause.OnConflict{
-			Columns: []clause.Column{{Name: "provider_id"}},
-			DoUpdates: clause.AssignmentColumns([]string{
-				"email",
-				"name",
-				"avatar_url",
-			}),
-		}).Create(&user)
-		if result.Error != nil {
-			c.AbortWithError(http.StatusInternalServerError, result.Error)
+		err = repos.Users.SignIn(&user)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
 
+		recordAuditEvent(c.Request.Context(), repos, &user, auditSignedIn, user.Provider)
+
 		// Set the authorized user in the session
 		session := sessions.Default(c)
 		session.Set(authorizedUserKey, profile.Email)
@@ -231,39 +314,27 @@ func handleLogout() gin.HandlerFunc {
 	}
 }
 
-func handleDeleteUser() gin.HandlerFunc {
+func handleDeleteUser(repos Repositories) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userValue, _ := c.Get("user")
 		user, _ := userValue.(*types.User)
-		tx := db.Begin()
-		result := tx.Delete(&user)
-		if result.Error != nil {
-			tx.Rollback()
-			c.AbortWithError(http.StatusInternalServerError, result.Error)
-			return
-		}
+		err := repos.Transaction(func(tx Repositories) error {
+			if err := tx.Users.Delete(user); err != nil {
+				return err
+			}
 
-		var metrics types.Metrics
-		result = tx.First(&metrics)
-		if result.Error != nil {
-			metrics = types.Metrics{
-				ID:           1,
-				DeletedUsers: 1,
+			if err := tx.Metrics.CountDeletedUser(); err != nil {
+				return err
 			}
-			result = tx.Create(&metrics)
-		} else {
-			metrics.DeletedUsers += 1
-			result = tx.Save(metrics)
-		}
 
-		if result.Error != nil {
-			tx.Rollback()
-			c.AbortWithError(http.StatusInternalServerError, result.Error)
+			// The account is gone, so the event does not keep the email
+			return tx.AuditEvents.Record(&types.AuditEvent{SlackTeamID: user.SlackTeamID.String, Action: auditDeleted})
+		})
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
 
-		tx.Commit()
-
 		// Clear the authorized user from the session
 		session := sessions.Default(c)
 		session.Delete(authorizedUserKey)
@@ -273,7 +344,7 @@ func handleDeleteUser() gin.HandlerFunc {
 	}
 }
 
-func handleSlackCommands() gin.HandlerFunc {
+func handleSlackCommands(repos Repositories) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		signature := c.GetHeader("X-Slack-Signature")
 		timestamp := c.GetHeader("X-Slack-Request-Timestamp")
@@ -311,43 +382,92 @@ func handleSlackCommands() gin.HandlerFunc {
 		}
 
 		// Verify valid team
-		user := getUserByTeamID(form.TeamId)
-		if user == nil {
+		user, err := repos.Users.FindByTeamID(form.TeamId)
+		if err != nil {
 			c.JSON(http.StatusOK, gin.H{"message": "who are you?"})
 			return
 		}
 
-		c.JSON(http.StatusOK, handleSlackCommand(form, user))
+		c.JSON(http.StatusOK, handleSlackCommand(c.Request.Context(), form, user, repos))
 	}
 }
 
-func getUserFromSession(c *gin.Context) (*types.User, error) {
-	session := sessions.Default(c)
-	email := session.Get(authorizedUserKey)
+func handleSlackInteractions(repos Repositories, stores StoreFactory) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		signature := c.GetHeader("X-Slack-Signature")
+		timestamp := c.GetHeader("X-Slack-Request-Timestamp")
 
-	if email == nil {
-		return nil, fmt.Errorf("no user found")
-	}
+		defer c.Request.Body.Close()
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(strings.NewReader(string(body)))
 
-	var user types.User
-	result := db.Where("email = ?", email).First(&user)
-	if result.Error != nil {
-		return nil, result.Error
-	}
+		// Verify signature and timestamp drift
+		if !verifyRequestSignature(signature, timestamp, body) || !verifyRequestRecency(timestamp) {
+			c.JSON(http.StatusOK, gin.H{"message": "Could not verify request!"})
+			return
+		}
+
+		var interaction types.SlackInteraction
+		if err := json.Unmarshal([]byte(c.PostForm("payload")), &interaction); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Validate the token
+		if interaction.Token != slackVerificationToken {
+			c.JSON(http.StatusOK, gin.H{"message": "Could not verify request!"})
+			return
+		}
+
+		// Verify valid team
+		user, err := repos.Users.FindByTeamID(interaction.Team.Id)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "who are you?"})
+			return
+		}
 
-	return &user, nil
+		// Slack only keeps the modal open for errors in the acknowledgement
+		if validate, ok := slackViewValidators[interaction.View.CallbackId]; ok && interaction.Type == "view_submission" {
+			if inputErrors := validate(interaction); len(inputErrors) > 0 {
+				c.JSON(http.StatusOK, gin.H{"response_action": "errors", "errors": inputErrors})
+				return
+			}
+		}
+
+		// Answered in the background, after Slack has been acknowledged
+		ctx := context.WithoutCancel(c.Request.Context())
+		done, ok := inflight.begin(ctx, interactionDescription, interactionDelivery(interaction), nil)
+		if !ok {
+			c.Status(http.StatusServiceUnavailable)
+			return
+		}
+		go func() {
+			defer done()
+			// The buttons and modals all act on App Store releases
+			handleSlackInteraction(ctx, interaction, user, repos, stores.appStore(user))
+		}()
+		c.Status(http.StatusOK)
+	}
 }
 
-func getUserByTeamID(teamID string) *types.User {
-	var user types.User
-	db.Where("slack_team_id = ?", teamID).First(&user)
+func getUserFromSession(c *gin.Context, users UserRepository) (*types.User, error) {
+	session := sessions.Default(c)
+	email, ok := session.Get(authorizedUserKey).(string)
 
-	return &user
+	if !ok {
+		return nil, fmt.Errorf("no user found")
+	}
+
+	return users.FindByEmail(email)
 }
 
-func getUserFromSessionMiddleware() gin.HandlerFunc {
+func getUserFromSessionMiddleware(users UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		user, err := getUserFromSession(c)
+		user, err := getUserFromSession(c, users)
 		if err != nil {
 			c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("no user found"))
 			return
@@ -386,16 +506,19 @@ func verifyRequestSignature(signature, timestamp string, body []byte) bool {
 	return hmac.Equal([]byte(expectedSignature), []byte(signature))
 }
 
-func validateAppStoreCreds(bundleID string, issuerID string, keyID string, p8FileBytes []byte) error {
-	appleCredentials := types.AppleCredentials{
-		BundleID: bundleID,
-		IssuerID: issuerID,
-		KeyID:    keyID,
-		P8File:   p8FileBytes,
+// recordAuditEvent keeps a trail of account changes, failing to record one
+// does not fail the request.
+func recordAuditEvent(ctx context.Context, repos Repositories, user *types.User, action string, detail string) {
+	event := types.AuditEvent{
+		ActorEmail:  user.Email,
+		SlackTeamID: user.SlackTeamID.String,
+		Action:      action,
+		Detail:      detail,
+	}
+
+	if err := repos.AuditEvents.Record(&event); err != nil {
+		slog.ErrorContext(ctx, "audit: could not record the event", "action", action, "email", user.Email, "error", err)
 	}
-	appMetadata, err := getAppMetadata(&appleCredentials)
-	fmt.Println(appMetadata)
-	return err
 }
 
 func redirectWithFlash(c *gin.Context, flashMsg string) {
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	viewsGlob           = "views/*"
+	readinessTimeout    = 3 * time.Second
+	applelinkHealthPath = "/ping"
+)
+
+// readinessCheck is a dependency that has to work for the bot to serve.
+type readinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type checkResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+func readinessChecks(db *gorm.DB) []readinessCheck {
+	checks := []readinessCheck{
+		{Name: "database", Check: func(ctx context.Context) error { return checkDatabase(ctx, db) }},
+		{Name: "encryption_key", Check: checkEncryptionKey},
+		{Name: "templates", Check: checkTemplates},
+	}
+
+	if storeBackend == "" || storeBackend == "applelink" {
+		checks = append(checks, readinessCheck{Name: "applelink", Check: checkApplelink})
+	}
+
+	return checks
+}
+
+// handleHealthz tells the orchestrator that the process is up, restarting it
+// will not help with anything else.
+func handleHealthz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// handleReadyz runs the checks together and answers 503 when any of them
+// fails, to take the instance out of rotation until it recovers.
+func handleReadyz(checks []readinessCheck) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isShuttingDown() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+
+		results := make(map[string]checkResult, len(checks))
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, check := range checks {
+			wg.Add(1)
+			go func(check readinessCheck) {
+				defer wg.Done()
+
+				startedAt := time.Now()
+				result := checkResult{Status: "ok"}
+				if err := check.Check(ctx); err != nil {
+					result = checkResult{Status: "failing", Error: err.Error()}
+				}
+				result.DurationMS = time.Since(startedAt).Milliseconds()
+
+				mu.Lock()
+				results[check.Name] = result
+				mu.Unlock()
+			}(check)
+		}
+		wg.Wait()
+
+		status, statusCode := "ok", http.StatusOK
+		for _, result := range results {
+			if result.Status != "ok" {
+				status, statusCode = "unavailable", http.StatusServiceUnavailable
+			}
+		}
+
+		c.JSON(statusCode, gin.H{"status": status, "checks": results})
+	}
+}
+
+func checkDatabase(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.PingContext(ctx)
+}
+
+// checkEncryptionKey makes sure that the stored credentials can be decrypted,
+// which otherwise brings the process down on the first command.
+func checkEncryptionKey(context.Context) error {
+	if _, err := aes.NewCipher([]byte(encryptionKey)); err != nil {
+		return fmt.Errorf("ENCRYPTION_KEY must be 16, 24 or 32 bytes long")
+	}
+
+	return nil
+}
+
+func checkTemplates(context.Context) error {
+	_, err := template.ParseGlob(viewsGlob)
+	return err
+}
+
+// checkApplelink needs the ping of applelink to succeed. Anything else, a 404
+// included, means that APPLELINK_HOST does not point at a working applelink.
+func checkApplelink(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, applelinkHost+applelinkHealthPath, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return statusError{service: "applelink", statusCode: resp.StatusCode}
+	}
+
+	return nil
+}
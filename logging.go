@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	redacted        = "[REDACTED]"
+)
+
+type requestIDKey struct{}
+
+// Attribute keys whose values are never logged, whatever they hold.
+var secretLogKeys = map[string]bool{
+	"authorization":   true,
+	"token":           true,
+	"access_token":    true,
+	"refresh_token":   true,
+	"client_secret":   true,
+	"secret":          true,
+	"password":        true,
+	"p8":              true,
+	"p8_file":         true,
+	"service_account": true,
+}
+
+// secretPatterns catch secrets that end up inside messages and errors: P8
+// keys, service account keys, Slack tokens and bearer tokens.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(-----END [A-Z ]*PRIVATE KEY-----|$)`),
+	regexp.MustCompile(`"private_key"\s*:\s*"[^"]*"`),
+	regexp.MustCompile(`xox[abposr]-[A-Za-z0-9-]+`),
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/=-]+`),
+}
+
+// initLogger sets up the default logger, JSON in production so that the logs
+// can be queried, and text everywhere else.
+func initLogger(env string) {
+	slog.SetDefault(newLogger(os.Stdout, env))
+}
+
+func newLogger(w io.Writer, env string) *slog.Logger {
+	options := &slog.HandlerOptions{ReplaceAttr: redactAttr}
+
+	var handler slog.Handler
+	if env == "production" {
+		handler = slog.NewJSONHandler(w, options)
+	} else {
+		handler = slog.NewTextHandler(w, options)
+	}
+
+	return slog.New(requestIDHandler{handler})
+}
+
+func redactAttr(_ []string, attr slog.Attr) slog.Attr {
+	if secretLogKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch value := attr.Value.Any().(type) {
+	case string:
+		return slog.String(attr.Key, redactSecrets(value))
+	case error:
+		return slog.String(attr.Key, redactSecrets(value.Error()))
+	}
+
+	return attr
+}
+
+func redactSecrets(text string) string {
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllString(text, redacted)
+	}
+
+	return text
+}
+
+// requestIDHandler adds the request id carried by the context to every record
+// logged with it, so that a command can be followed from the Slack request to
+// the queue, the store and the delivery of its response. The trace id, when
+// tracing, leads from a log line to its trace.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (handler requestIDHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := requestIDFrom(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+
+	return handler.Handler.Handle(ctx, record)
+}
+
+func (handler requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{handler.Handler.WithAttrs(attrs)}
+}
+
+func (handler requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{handler.Handler.WithGroup(name)}
+}
+
+func withRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+func requestIDFrom(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+func newRequestID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// validRequestID keeps what a proxy sends as X-Request-ID short and printable.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestIDMiddleware tags the request with the id given by the proxy in front
+// of us, or a new one, and logs the request once it is served.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+
+		c.Header(requestIDHeader, requestID)
+		c.Request = c.Request.WithContext(withRequestID(c.Request.Context(), requestID))
+
+		startedAt := time.Now()
+		c.Next()
+
+		// The query is left out, it carries OAuth codes
+		slog.InfoContext(c.Request.Context(), "http: request served",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"duration_ms", time.Since(startedAt).Milliseconds(),
+		)
+	}
+}
@@ -2,22 +2,32 @@ package main
 
 import (
 	"ciderbot/types"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 const (
+	postgresDialect   = "postgres"
+	sqliteDialect     = "sqlite"
 	authorizedUserKey = "AUTHORIZED_USER_EMAIL"
 	certFilePath      = "./config/certs/localhost.pem"
 	certKeyFilePath   = "./config/certs/localhost-key.pem"
@@ -31,7 +41,6 @@ var (
 	clientSecret           string
 	redirectURL            string
 	sessionSecret          string
-	db                     *gorm.DB
 	googleOAuthConf        *oauth2.Config
 	slackOAuthConf         *oauth2.Config
 	slackClientID          string
@@ -46,6 +55,12 @@ var (
 	applelinkAuthSecret    string
 	applelinkCredentials   *types.ApplelinkCredentials
 	applelinkHost          string
+	playAPIHost            string
+	appStoreConnectHost    string
+	storeBackend           string
+	databaseURL            string
+	metricsToken           string
+	otlpEndpoint           string
 )
 
 func initEnv() {
@@ -58,6 +73,7 @@ func initEnv() {
 	appEnv = os.Getenv("ENV")
 	sessionName = os.Getenv("APP_NAME")
 	dbName = os.Getenv("DB_NAME")
+	databaseURL = os.Getenv("DATABASE_URL")
 	clientID = os.Getenv("GOOGLE_CLIENT_ID")
 	clientSecret = os.Getenv("GOOGLE_CLIENT_SECRET")
 	redirectURL = os.Getenv("GOOGLE_REDIRECT_URL")
@@ -72,21 +88,49 @@ func initEnv() {
 	applelinkAuthIssuer = os.Getenv("APPLELINK_AUTH_ISSUER")
 	applelinkAuthSecret = os.Getenv("APPLELINK_AUTH_SECRET")
 	applelinkHost = os.Getenv("APPLELINK_HOST")
+	playAPIHost = os.Getenv("PLAY_API_HOST")
+	if playAPIHost == "" {
+		playAPIHost = defaultPlayAPIHost
+	}
+	appStoreConnectHost = os.Getenv("APP_STORE_CONNECT_HOST")
+	if appStoreConnectHost == "" {
+		appStoreConnectHost = defaultAppStoreConnectHost
+	}
+	storeBackend = os.Getenv("STORE_BACKEND")
+	metricsToken = os.Getenv("METRICS_TOKEN")
+	otlpEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
 }
 
 // TODO: do we need to close the DB "conn"?
-func initDB(name string) *gorm.DB {
-	var err error
-	db, err = gorm.Open(sqlite.Open(name), &gorm.Config{})
+func initDB() *gorm.DB {
+	dialector, err := databaseDialector(databaseURL, dbName)
+	if err != nil {
+		log.Fatalf("Error opening the database: %s", err)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
 		panic(err)
 	}
-	db.AutoMigrate(&types.User{})
-	db.AutoMigrate(&types.Metrics{})
 
 	return db
 }
 
+// databaseDialector picks the driver from DATABASE_URL, a postgres:// url or a
+// sqlite://path. Without one, the SQLite database named by DB_NAME is used.
+func databaseDialector(databaseURL string, name string) (gorm.Dialector, error) {
+	switch {
+	case databaseURL == "":
+		return sqlite.Open(name), nil
+	case strings.HasPrefix(databaseURL, "postgres://"), strings.HasPrefix(databaseURL, "postgresql://"):
+		return postgres.Open(databaseURL), nil
+	case strings.HasPrefix(databaseURL, "sqlite://"):
+		return sqlite.Open(strings.TrimPrefix(databaseURL, "sqlite://")), nil
+	default:
+		return nil, fmt.Errorf("unsupported DATABASE_URL, use postgres:// or sqlite://")
+	}
+}
+
 func initGoogleOAuthConf() {
 	googleOAuthConf = &oauth2.Config{
 		ClientID:     clientID,
@@ -109,7 +153,7 @@ func initSlackOAuthConf() {
 			TokenURL: "https://slack.com/api/oauth.v2.access",
 		},
 		RedirectURL: slackRedirectURI,
-		Scopes:      []string{"chat:write", "chat:write.customize", "commands"},
+		Scopes:      []string{"chat:write", "chat:write.customize", "commands", "files:write"},
 	}
 }
 
@@ -127,42 +171,99 @@ func handlePing() gin.HandlerFunc {
 	}
 }
 
-func initServer(db *gorm.DB) {
-	r := gin.Default()
+func initStores() StoreFactory {
+	stores, err := newStoreFactory(storeBackend)
+	if err != nil {
+		log.Fatalf("Error setting up the store: %s", err)
+	}
+
+	return stores
+}
+
+// newRouter wires the handlers with what they depend on.
+func newRouter(repos Repositories, stores StoreFactory, readiness []readinessCheck) *gin.Engine {
+	r := gin.New()
+	r.Use(gin.Recovery(), otelgin.Middleware(serviceName), requestIDMiddleware())
 
 	store := cookie.NewStore([]byte(sessionSecret))
 	r.Use(sessions.Sessions(sessionName, store))
 
-	r.GET("/", handleHome())
-	r.GET("/login", handleLogin())
+	r.GET("/", handleHome(repos.Users))
+	r.GET("/login", handleLogin(repos.Users))
 	r.GET("/logout", handleLogout())
-	r.GET("/auth/google/callback", handleGoogleCallback(db))
+	r.GET("/auth/google/callback", handleGoogleCallback(repos))
 	r.GET("/auth/slack/start", handleSlackAuth())
-	r.GET("/auth/slack/callback", getUserFromSessionMiddleware(), handleSlackAuthCallback())
-	r.POST("/auth/apple", getUserFromSessionMiddleware(), handleAppStoreCreds())
-	r.POST("/user/delete", getUserFromSessionMiddleware(), handleDeleteUser())
+	r.GET("/auth/slack/callback", getUserFromSessionMiddleware(repos.Users), handleSlackAuthCallback(repos))
+	r.POST("/auth/apple", getUserFromSessionMiddleware(repos.Users), handleAppStoreCreds(repos, stores))
+	r.POST("/auth/google-play", getUserFromSessionMiddleware(repos.Users), handleGooglePlayCreds(repos, stores))
+	r.POST("/user/delete", getUserFromSessionMiddleware(repos.Users), handleDeleteUser(repos))
 	r.GET("/ping", handlePing())
-	r.POST("/slack/listen", handleSlackCommands())
+	r.GET("/healthz", handleHealthz())
+	r.GET("/readyz", handleReadyz(readiness))
+	r.GET("/metrics", handleMetrics(metricsToken))
+	r.POST("/slack/listen", handleSlackCommands(repos))
+	r.POST("/slack/interactions", handleSlackInteractions(repos, stores))
 
 	r.Static("/assets", "./assets")
-	r.LoadHTMLGlob("views/*")
+	r.LoadHTMLGlob(viewsGlob)
 
-	var err error
-	if appEnv == "production" {
-		err = r.Run(appPort)
-	} else {
-		err = r.RunTLS(appPort, certFilePath, certKeyFilePath)
-	}
+	return r
+}
 
-	if err != nil {
-		fmt.Println(err)
-	}
+// initServer serves until SIGINT or SIGTERM, then shuts down gracefully.
+func initServer(repos Repositories, stores StoreFactory, readiness []readinessCheck) {
+	server := &http.Server{Addr: appPort, Handler: newRouter(repos, stores, readiness)}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		var err error
+		if appEnv == "production" {
+			err = server.ListenAndServe()
+		} else {
+			err = server.ListenAndServeTLS(certFilePath, certKeyFilePath)
+		}
+
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server: stopped", "error", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	shutdown(server, repos)
 }
 
 func main() {
 	initEnv()
+	initLogger(appEnv)
 	initSlackOAuthConf()
 	initGoogleOAuthConf()
 	initApplelinkCreds()
-	initServer(initDB(dbName))
+	db := initDB()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(db, os.Args[2:]); err != nil {
+			log.Fatalf("%s", err)
+		}
+		return
+	}
+
+	if err := migrateUp(db); err != nil {
+		log.Fatalf("Error migrating the database: %s", err)
+	}
+
+	shutdownTracing, err := initTracing(otlpEndpoint)
+	if err != nil {
+		log.Fatalf("Error setting up tracing: %s", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	stores := initStores()
+	repos := newGormRepositories(db)
+	initMetrics(db)
+	initScheduler(repos, stores)
+	initCommandQueue(repos, stores)
+	initServer(repos, stores, readinessChecks(db))
 }
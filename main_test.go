@@ -0,0 +1,208 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testRouter wires the handlers with in-memory repositories and a connected
+// workspace, signing the Slack requests with a test secret.
+func testRouter(t *testing.T) (*gin.Engine, Repositories) {
+	t.Helper()
+
+	return testRouterWithStores(t, func(user *types.User) StoreClient { return newMemoryStore() })
+}
+
+func testRouterWithStores(t *testing.T, stores StoreFactory) (*gin.Engine, Repositories) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	signingSecret, verificationToken := slackSigningSecret, slackVerificationToken
+	slackSigningSecret, slackVerificationToken = "signing-secret", "verification-token"
+	t.Cleanup(func() { slackSigningSecret, slackVerificationToken = signingSecret, verificationToken })
+
+	repos := newMemoryRepositories()
+	connectTestWorkspace(t, repos)
+
+	return newRouter(repos, stores, nil), repos
+}
+
+// connectTestWorkspace signs up the owner of the workspace T0001 and its app.
+func connectTestWorkspace(t *testing.T, repos Repositories) {
+	t.Helper()
+
+	user := &types.User{Email: "owner@example.com", ProviderID: "google-1"}
+	if err := repos.Users.SignIn(user); err != nil {
+		t.Fatalf("could not sign in: %s", err)
+	}
+	if err := repos.Users.ConnectWorkspace(user, slackWorkspace{TeamID: "T0001", TeamName: "Ciderbot", AccessToken: "xoxb-test"}); err != nil {
+		t.Fatalf("could not connect the workspace: %s", err)
+	}
+	if err := repos.Apps.ConnectAppStore(user, appStoreApp{BundleID: "com.example.ciderbot"}); err != nil {
+		t.Fatalf("could not connect the app: %s", err)
+	}
+}
+
+func signedSlackCommand(text string, teamID string) *http.Request {
+	form := url.Values{
+		"token":        {"verification-token"},
+		"team_id":      {teamID},
+		"channel_id":   {"C0001"},
+		"user_id":      {"U0001"},
+		"command":      {"/ciderbot"},
+		"text":         {text},
+		"response_url": {"https://hooks.slack.com/commands/T0001/1/abc"},
+	}
+
+	return signedSlackRequest("/slack/listen", form)
+}
+
+func signedSlackInteraction(t *testing.T, interaction types.SlackInteraction) *http.Request {
+	t.Helper()
+
+	payload, err := json.Marshal(interaction)
+	if err != nil {
+		t.Fatalf("could not encode the interaction: %s", err)
+	}
+
+	return signedSlackRequest("/slack/interactions", url.Values{"payload": {string(payload)}})
+}
+
+func signedSlackRequest(path string, form url.Values) *http.Request {
+	body := form.Encode()
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(slackSigningSecret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func TestRouterQueuesSlackCommands(t *testing.T) {
+	router, repos := testRouter(t)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, signedSlackCommand("app_info", "T0001"))
+
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "Got the `app_info` command") {
+		t.Fatalf("the command was answered with %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	job, err := repos.Commands.ClaimNext(time.Now().UTC())
+	if err != nil || job == nil {
+		t.Fatalf("no command was queued (%v)", err)
+	}
+	if job.Command != "app_info" || job.SlackTeamID != "T0001" || strings.Contains(job.Form, "verification-token") {
+		t.Errorf("the queued command is %+v", job)
+	}
+
+	user, err := repos.Users.FindByTeamID("T0001")
+	if err != nil || user.CommandCount != 1 {
+		t.Errorf("the command was not counted: %+v (%v)", user, err)
+	}
+}
+
+func TestRouterRejectsUnknownWorkspaces(t *testing.T) {
+	router, repos := testRouter(t)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, signedSlackCommand("app_info", "T9999"))
+
+	if !strings.Contains(recorder.Body.String(), "who are you?") {
+		t.Errorf("an unknown workspace was answered with %s", recorder.Body.String())
+	}
+	if job, _ := repos.Commands.ClaimNext(time.Now().UTC()); job != nil {
+		t.Errorf("the command of an unknown workspace was queued")
+	}
+}
+
+func TestRouterRejectsUnsignedRequests(t *testing.T) {
+	router, repos := testRouter(t)
+
+	req := signedSlackCommand("app_info", "T0001")
+	req.Header.Set("X-Slack-Signature", "v0=forged")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if !strings.Contains(recorder.Body.String(), "Could not verify request!") {
+		t.Errorf("a forged request was answered with %s", recorder.Body.String())
+	}
+	if job, _ := repos.Commands.ClaimNext(time.Now().UTC()); job != nil {
+		t.Errorf("the command of a forged request was queued")
+	}
+}
+
+func TestRouterServesPing(t *testing.T) {
+	router, _ := testRouter(t)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "pong") {
+		t.Errorf("/ping answered %d %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestRouterKeepsABlankReviewReplyOpen(t *testing.T) {
+	router, _ := testRouter(t)
+
+	interaction := types.SlackInteraction{Type: "view_submission", Token: "verification-token"}
+	interaction.Team.Id = "T0001"
+	interaction.View = types.SlackView{
+		CallbackId: "review_reply",
+		State: &types.SlackViewState{Values: map[string]map[string]types.SlackAction{
+			reviewReplyBlock: {reviewReplyInput: {Value: "  "}},
+		}},
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, signedSlackInteraction(t, interaction))
+
+	var response struct {
+		ResponseAction string            `json:"response_action"`
+		Errors         map[string]string `json:"errors"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || response.ResponseAction != "errors" || response.Errors[reviewReplyBlock] == "" {
+		t.Errorf("a blank reply was answered with %d %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestApplelinkIsReadyOnlyWhenItsPingSucceeds(t *testing.T) {
+	for status, ready := range map[int]bool{http.StatusOK: true, http.StatusNotFound: false, http.StatusBadGateway: false} {
+		applelink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != applelinkHealthPath {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(status)
+		}))
+
+		host := applelinkHost
+		applelinkHost = applelink.URL
+		err := checkApplelink(context.Background())
+		applelinkHost = host
+		applelink.Close()
+
+		if (err == nil) != ready {
+			t.Errorf("a ping answered with %d made applelink ready=%t (%v)", status, err == nil, err)
+		}
+	}
+}
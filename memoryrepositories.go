@@ -0,0 +1,828 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// memoryRepositories keeps everything in maps and slices, for wiring the
+// server without a database. Transactions are not rolled back.
+type memoryRepositories struct {
+	mu               sync.Mutex
+	users            map[string]*types.User
+	deletedUsers     int64
+	auditEvents      []types.AuditEvent
+	commands         []types.CommandJob
+	scheduledJobs    []types.ScheduledJob
+	responseURLUses  map[string]types.SlackResponseURL
+	deliveryFailures []types.SlackDeliveryFailure
+	postedReviews    []types.PostedReview
+	releaseRequests  []types.ReleaseRequest
+	reviewRatings    []types.ReviewRating
+	ratingSnapshots  []types.RatingSnapshot
+	salesDays        []types.SalesReportDay
+	salesRows        []types.SalesReportRow
+	nextID           uint
+}
+
+func newMemoryRepositories() Repositories {
+	memory := &memoryRepositories{
+		users:           map[string]*types.User{},
+		responseURLUses: map[string]types.SlackResponseURL{},
+	}
+
+	repos := Repositories{
+		Users:       memoryUserRepository{memory},
+		Apps:        memoryAppRepository{memory},
+		Metrics:     memoryMetricsRepository{memory},
+		AuditEvents: memoryAuditEventRepository{memory},
+		Commands:    memoryCommandRepository{memory},
+		Jobs:        memoryScheduledJobRepository{memory},
+		Deliveries:  memoryDeliveryRepository{memory},
+		Reviews:     memoryReviewRepository{memory},
+		Releases:    memoryReleaseRepository{memory},
+		Ratings:     memoryRatingRepository{memory},
+		Sales:       memorySalesRepository{memory},
+	}
+	repos.transaction = func(fn func(repos Repositories) error) error {
+		return fn(repos)
+	}
+
+	return repos
+}
+
+type memoryUserRepository struct {
+	*memoryRepositories
+}
+
+func (repo memoryUserRepository) FindByEmail(email string) (*types.User, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	user, ok := repo.users[email]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	found := *user
+	return &found, nil
+}
+
+func (repo memoryUserRepository) FindByTeamID(teamID string) (*types.User, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, user := range repo.users {
+		if user.SlackTeamID.Valid && user.SlackTeamID.String == teamID {
+			found := *user
+			return &found, nil
+		}
+	}
+
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (repo memoryUserRepository) SignIn(user *types.User) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for email, existing := range repo.users {
+		if existing.ProviderID == user.ProviderID {
+			delete(repo.users, email)
+			existing.Email = user.Email
+			existing.Name = user.Name
+			existing.AvatarURL = user.AvatarURL
+			repo.users[user.Email] = existing
+			return nil
+		}
+	}
+
+	signedIn := *user
+	if signedIn.Platform == "" {
+		signedIn.Platform = iosPlatform
+	}
+	signedIn.CreatedAt = time.Now()
+	repo.users[user.Email] = &signedIn
+	return nil
+}
+
+func (repo memoryUserRepository) ConnectWorkspace(user *types.User, workspace slackWorkspace) error {
+	applyWorkspace(user, workspace)
+	return repo.save(user)
+}
+
+func (repo memoryUserRepository) CountCommand(user *types.User) error {
+	user.CommandCount += 1
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if stored, ok := repo.users[user.Email]; ok {
+		stored.CommandCount += 1
+	}
+	return nil
+}
+
+func (repo memoryUserRepository) SetPlatform(user *types.User, platform string) error {
+	user.Platform = platform
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if stored, ok := repo.users[user.Email]; ok {
+		stored.Platform = platform
+	}
+	return nil
+}
+
+func (repo memoryUserRepository) Delete(user *types.User) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.users, user.Email)
+	return nil
+}
+
+func (memory *memoryRepositories) save(user *types.User) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	saved := *user
+	saved.UpdatedAt = time.Now()
+	memory.users[user.Email] = &saved
+	return nil
+}
+
+type memoryAppRepository struct {
+	*memoryRepositories
+}
+
+func (repo memoryAppRepository) ConnectAppStore(user *types.User, app appStoreApp) error {
+	applyAppStoreApp(user, app)
+	return repo.save(user)
+}
+
+func (repo memoryAppRepository) ConnectGooglePlay(user *types.User, app googlePlayApp) error {
+	applyGooglePlayApp(user, app)
+	return repo.save(user)
+}
+
+type memoryMetricsRepository struct {
+	*memoryRepositories
+}
+
+func (repo memoryMetricsRepository) CountDeletedUser() error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.deletedUsers += 1
+	return nil
+}
+
+type memoryAuditEventRepository struct {
+	*memoryRepositories
+}
+
+func (repo memoryAuditEventRepository) Record(event *types.AuditEvent) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	event.ID = repo.newID()
+	event.CreatedAt = time.Now()
+	repo.auditEvents = append(repo.auditEvents, *event)
+	return nil
+}
+
+type memoryCommandRepository struct {
+	*memoryRepositories
+}
+
+func (repo memoryCommandRepository) Enqueue(ctx context.Context, command string, form types.SlackFormData) error {
+	job, err := newCommandJob(ctx, command, form)
+	if err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	job.ID = repo.newID()
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+	repo.commands = append(repo.commands, job)
+	return nil
+}
+
+func (repo memoryCommandRepository) ClaimNext(now time.Time) (*types.CommandJob, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	running := map[string]int{}
+	for _, job := range repo.commands {
+		if job.Status == commandRunning && !job.LockedUntil.Time.Before(now) {
+			running[job.SlackTeamID] += 1
+		}
+	}
+
+	for i := range repo.commands {
+		job := &repo.commands[i]
+		due := (job.Status == commandQueued && !job.NextAttemptAt.After(now)) || (job.Status == commandRunning && job.LockedUntil.Time.Before(now))
+		if !due || running[job.SlackTeamID] >= commandsPerWorkspace {
+			continue
+		}
+
+		job.Status = commandRunning
+		job.LockedUntil = sql.NullTime{Time: now.Add(commandLease), Valid: true}
+		job.Attempts += 1
+		job.LockVersion += 1
+		job.UpdatedAt = time.Now()
+
+		claimed := *job
+		return &claimed, nil
+	}
+
+	return nil, nil
+}
+
+func (repo memoryCommandRepository) Renew(job *types.CommandJob, lockedUntil time.Time) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.commands {
+		stored := &repo.commands[i]
+		if stored.ID == job.ID && stored.Status == commandRunning && stored.LockVersion == job.LockVersion {
+			stored.LockedUntil = sql.NullTime{Time: lockedUntil, Valid: true}
+			job.LockedUntil = stored.LockedUntil
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (repo memoryCommandRepository) SaveResponse(job *types.CommandJob) error {
+	return repo.update(job.ID, func(stored *types.CommandJob) {
+		stored.Response = job.Response
+	})
+}
+
+func (repo memoryCommandRepository) Retry(job *types.CommandJob, nextAttemptAt time.Time, lastError string) error {
+	err := repo.updateClaimed(job, func(stored *types.CommandJob) {
+		stored.Status = commandQueued
+		stored.NextAttemptAt = nextAttemptAt
+		stored.LastError = lastError
+	})
+	if err != nil {
+		return err
+	}
+
+	job.Status = commandQueued
+	job.NextAttemptAt = nextAttemptAt
+	return nil
+}
+
+func (repo memoryCommandRepository) Finish(job *types.CommandJob, status string, lastError string) error {
+	err := repo.updateClaimed(job, func(stored *types.CommandJob) {
+		stored.Status = status
+		stored.LastError = lastError
+		stored.LockedUntil = sql.NullTime{}
+	})
+	if err != nil {
+		return err
+	}
+
+	job.Status = status
+	return nil
+}
+
+func (repo memoryCommandRepository) Interrupt(job *types.CommandJob) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.commands {
+		stored := &repo.commands[i]
+		if stored.ID == job.ID && stored.Status == commandRunning && stored.LockVersion == job.LockVersion {
+			stored.Status = commandFailed
+			stored.LastError = "interrupted by a restart"
+			stored.LockedUntil = sql.NullTime{}
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (repo memoryCommandRepository) Cleanup(now time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var kept []types.CommandJob
+	for _, job := range repo.commands {
+		finished := job.Status == commandDelivered || job.Status == commandFailed
+		if finished && job.UpdatedAt.Before(now.Add(-commandRetention)) {
+			continue
+		}
+
+		if !finished && job.ExpiresAt.Before(now) {
+			job.Status = commandFailed
+			job.LastError = "response url expired"
+		}
+		kept = append(kept, job)
+	}
+	repo.commands = kept
+
+	return nil
+}
+
+func (repo memoryCommandRepository) update(id uint, change func(job *types.CommandJob)) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.commands {
+		if repo.commands[i].ID == id {
+			change(&repo.commands[i])
+			repo.commands[i].UpdatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+// updateClaimed changes the command unless another worker claimed it since.
+func (repo memoryCommandRepository) updateClaimed(job *types.CommandJob, change func(job *types.CommandJob)) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.commands {
+		if repo.commands[i].ID == job.ID && repo.commands[i].LockVersion == job.LockVersion {
+			change(&repo.commands[i])
+			repo.commands[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return errCommandClaimedElsewhere
+}
+
+type memoryScheduledJobRepository struct {
+	*memoryRepositories
+}
+
+func (repo memoryScheduledJobRepository) Due(now time.Time) ([]types.ScheduledJob, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var jobs []types.ScheduledJob
+	for _, job := range repo.scheduledJobs {
+		if !job.NextRunAt.After(now) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (repo memoryScheduledJobRepository) Claim(job *types.ScheduledJob, nextRunAt time.Time, now time.Time) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.scheduledJobs {
+		stored := &repo.scheduledJobs[i]
+		if stored.ID != job.ID || stored.LockVersion != job.LockVersion {
+			continue
+		}
+
+		stored.NextRunAt = nextRunAt
+		stored.LastRunAt = sql.NullTime{Time: now, Valid: true}
+		stored.LockVersion += 1
+		job.NextRunAt = stored.NextRunAt
+		job.LastRunAt = stored.LastRunAt
+		job.LockVersion = stored.LockVersion
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (repo memoryScheduledJobRepository) SaveState(job *types.ScheduledJob, state string) error {
+	job.State = state
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.scheduledJobs {
+		if repo.scheduledJobs[i].ID == job.ID {
+			repo.scheduledJobs[i].State = state
+		}
+	}
+	return nil
+}
+
+func (repo memoryScheduledJobRepository) Upsert(job *types.ScheduledJob) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i, existing := range repo.scheduledJobs {
+		if existing.Kind == job.Kind && existing.SlackTeamID == job.SlackTeamID && existing.SlackChannelID == job.SlackChannelID {
+			keepScheduledJob(job, existing)
+			job.UpdatedAt = time.Now()
+			repo.scheduledJobs[i] = *job
+			return nil
+		}
+	}
+
+	repo.create(job)
+	return nil
+}
+
+func (repo memoryScheduledJobRepository) Create(job *types.ScheduledJob) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.create(job)
+	return nil
+}
+
+func (repo memoryScheduledJobRepository) create(job *types.ScheduledJob) {
+	job.ID = repo.newID()
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+	repo.scheduledJobs = append(repo.scheduledJobs, *job)
+}
+
+func (repo memoryScheduledJobRepository) Find(kind string, teamID string, channelID string) (*types.ScheduledJob, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, job := range repo.scheduledJobs {
+		if job.Kind == kind && job.SlackTeamID == teamID && job.SlackChannelID == channelID {
+			found := job
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (repo memoryScheduledJobRepository) List(kind string, teamID string) ([]types.ScheduledJob, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var jobs []types.ScheduledJob
+	for _, job := range repo.scheduledJobs {
+		if job.Kind == kind && job.SlackTeamID == teamID {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (repo memoryScheduledJobRepository) Delete(kind string, teamID string, channelID string) (bool, error) {
+	return repo.delete(func(job types.ScheduledJob) bool {
+		return job.Kind == kind && job.SlackTeamID == teamID && job.SlackChannelID == channelID
+	}), nil
+}
+
+func (repo memoryScheduledJobRepository) DeleteByID(kind string, teamID string, id uint) (bool, error) {
+	return repo.delete(func(job types.ScheduledJob) bool {
+		return job.Kind == kind && job.SlackTeamID == teamID && job.ID == id
+	}), nil
+}
+
+func (repo memoryScheduledJobRepository) delete(matches func(job types.ScheduledJob) bool) bool {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var kept []types.ScheduledJob
+	for _, job := range repo.scheduledJobs {
+		if !matches(job) {
+			kept = append(kept, job)
+		}
+	}
+
+	deleted := len(kept) < len(repo.scheduledJobs)
+	repo.scheduledJobs = kept
+	return deleted
+}
+
+type memoryDeliveryRepository struct {
+	*memoryRepositories
+}
+
+func (repo memoryDeliveryRepository) ClaimResponseURLUse(urlHash string) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	responseURL, ok := repo.responseURLUses[urlHash]
+	if !ok {
+		responseURL = types.SlackResponseURL{ID: repo.newID(), URLHash: urlHash, CreatedAt: time.Now()}
+	}
+
+	if responseURL.Uses >= maxResponseURLUses {
+		return false, nil
+	}
+
+	responseURL.Uses += 1
+	repo.responseURLUses[urlHash] = responseURL
+	return true, nil
+}
+
+func (repo memoryDeliveryRepository) RecordFailure(failure *types.SlackDeliveryFailure) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	failure.ID = repo.newID()
+	failure.CreatedAt = time.Now()
+	repo.deliveryFailures = append(repo.deliveryFailures, *failure)
+	return nil
+}
+
+func (repo memoryDeliveryRepository) CleanupResponseURLs(before time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for urlHash, responseURL := range repo.responseURLUses {
+		if responseURL.CreatedAt.Before(before) {
+			delete(repo.responseURLUses, urlHash)
+		}
+	}
+	return nil
+}
+
+type memoryReviewRepository struct {
+	*memoryRepositories
+}
+
+func (repo memoryReviewRepository) MarkPosted(review *types.PostedReview) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if repo.findPosted(review.SlackTeamID, review.SlackChannelID, review.ReviewID) != nil {
+		return false, nil
+	}
+
+	review.ID = repo.newID()
+	review.CreatedAt = time.Now()
+	review.UpdatedAt = review.CreatedAt
+	repo.postedReviews = append(repo.postedReviews, *review)
+	return true, nil
+}
+
+func (repo memoryReviewRepository) UnmarkPosted(review *types.PostedReview) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var kept []types.PostedReview
+	for _, posted := range repo.postedReviews {
+		if posted.ID != review.ID {
+			kept = append(kept, posted)
+		}
+	}
+	repo.postedReviews = kept
+	return nil
+}
+
+func (repo memoryReviewRepository) FindPosted(teamID string, channelID string, reviewID string) (*types.PostedReview, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if posted := repo.findPosted(teamID, channelID, reviewID); posted != nil {
+		found := *posted
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (repo memoryReviewRepository) SaveReply(review *types.PostedReview) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if posted := repo.findPosted(review.SlackTeamID, review.SlackChannelID, review.ReviewID); posted != nil {
+		posted.RepliedBy = review.RepliedBy
+		posted.RepliedAt = review.RepliedAt
+		posted.UpdatedAt = time.Now()
+		return nil
+	}
+
+	review.ID = repo.newID()
+	review.CreatedAt = time.Now()
+	review.UpdatedAt = review.CreatedAt
+	repo.postedReviews = append(repo.postedReviews, *review)
+	return nil
+}
+
+func (repo memoryReviewRepository) findPosted(teamID string, channelID string, reviewID string) *types.PostedReview {
+	for i := range repo.postedReviews {
+		posted := &repo.postedReviews[i]
+		if posted.SlackTeamID == teamID && posted.SlackChannelID == channelID && posted.ReviewID == reviewID {
+			return posted
+		}
+	}
+	return nil
+}
+
+type memoryReleaseRepository struct {
+	*memoryRepositories
+}
+
+func (repo memoryReleaseRepository) Claim(request *types.ReleaseRequest) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, claimed := range repo.releaseRequests {
+		if claimed.SlackTeamID == request.SlackTeamID && claimed.VersionString == request.VersionString {
+			return false, nil
+		}
+	}
+
+	request.ID = repo.newID()
+	request.CreatedAt = time.Now()
+	repo.releaseRequests = append(repo.releaseRequests, *request)
+	return true, nil
+}
+
+func (repo memoryReleaseRepository) Unclaim(request *types.ReleaseRequest) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var kept []types.ReleaseRequest
+	for _, claimed := range repo.releaseRequests {
+		if claimed.ID != request.ID {
+			kept = append(kept, claimed)
+		}
+	}
+	repo.releaseRequests = kept
+	return nil
+}
+
+type memoryRatingRepository struct {
+	*memoryRepositories
+}
+
+func (repo memoryRatingRepository) AddRatings(ratings []types.ReviewRating) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	seen := map[string]bool{}
+	for _, rating := range repo.reviewRatings {
+		seen[rating.SlackTeamID+"/"+rating.ReviewID] = true
+	}
+
+	for _, rating := range ratings {
+		if seen[rating.SlackTeamID+"/"+rating.ReviewID] {
+			continue
+		}
+
+		rating.ID = repo.newID()
+		repo.reviewRatings = append(repo.reviewRatings, rating)
+		seen[rating.SlackTeamID+"/"+rating.ReviewID] = true
+	}
+	return nil
+}
+
+func (repo memoryRatingRepository) CountByTerritory(teamID string) ([]ratingAggregate, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	byTerritory := map[string]*ratingAggregate{}
+	var aggregates []*ratingAggregate
+	for _, rating := range repo.reviewRatings {
+		if rating.SlackTeamID != teamID {
+			continue
+		}
+
+		aggregate, ok := byTerritory[rating.Territory]
+		if !ok {
+			aggregate = &ratingAggregate{Territory: rating.Territory}
+			byTerritory[rating.Territory] = aggregate
+			aggregates = append(aggregates, aggregate)
+		}
+		aggregate.AverageRating = (aggregate.AverageRating*float64(aggregate.ReviewCount) + float64(rating.Rating)) / float64(aggregate.ReviewCount+1)
+		aggregate.ReviewCount += 1
+	}
+
+	counted := make([]ratingAggregate, 0, len(aggregates))
+	for _, aggregate := range aggregates {
+		counted = append(counted, *aggregate)
+	}
+	return counted, nil
+}
+
+func (repo memoryRatingRepository) CountBetween(teamID string, from time.Time, to time.Time) (ratingAggregate, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var aggregate ratingAggregate
+	var ratingSum float64
+	for _, rating := range repo.reviewRatings {
+		if rating.SlackTeamID == teamID && !rating.CreatedDate.Before(from) && rating.CreatedDate.Before(to) {
+			aggregate.ReviewCount += 1
+			ratingSum += float64(rating.Rating)
+		}
+	}
+
+	if aggregate.ReviewCount > 0 {
+		aggregate.AverageRating = ratingSum / float64(aggregate.ReviewCount)
+	}
+	return aggregate, nil
+}
+
+func (repo memoryRatingRepository) SaveSnapshots(snapshots []types.RatingSnapshot) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, snapshot := range snapshots {
+		replaced := false
+		for i, stored := range repo.ratingSnapshots {
+			if stored.SlackTeamID == snapshot.SlackTeamID && stored.Territory == snapshot.Territory && stored.CapturedOn.Equal(snapshot.CapturedOn) {
+				repo.ratingSnapshots[i].ReviewCount = snapshot.ReviewCount
+				repo.ratingSnapshots[i].AverageRating = snapshot.AverageRating
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			snapshot.ID = repo.newID()
+			repo.ratingSnapshots = append(repo.ratingSnapshots, snapshot)
+		}
+	}
+	return nil
+}
+
+func (repo memoryRatingRepository) SnapshotsAt(teamID string, at time.Time) ([]types.RatingSnapshot, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var latest time.Time
+	for _, snapshot := range repo.ratingSnapshots {
+		if snapshot.SlackTeamID == teamID && !snapshot.CapturedOn.After(at) && snapshot.CapturedOn.After(latest) {
+			latest = snapshot.CapturedOn
+		}
+	}
+
+	var snapshots []types.RatingSnapshot
+	for _, snapshot := range repo.ratingSnapshots {
+		if snapshot.SlackTeamID == teamID && !latest.IsZero() && snapshot.CapturedOn.Equal(latest) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots, nil
+}
+
+type memorySalesRepository struct {
+	*memoryRepositories
+}
+
+func (repo memorySalesRepository) SyncedDays(teamID string, from time.Time, to time.Time) ([]types.SalesReportDay, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var days []types.SalesReportDay
+	for _, day := range repo.salesDays {
+		if day.SlackTeamID == teamID && !day.ReportDate.Before(from) && !day.ReportDate.After(to) {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+func (repo memorySalesRepository) SaveReport(teamID string, reportDate time.Time, rows []types.SalesReportRow) error {
+	applySalesReportDay(rows, teamID, reportDate)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, row := range rows {
+		row.ID = repo.newID()
+		repo.salesRows = append(repo.salesRows, row)
+	}
+
+	repo.salesDays = append(repo.salesDays, types.SalesReportDay{
+		ID:          repo.newID(),
+		SlackTeamID: teamID,
+		ReportDate:  reportDate,
+		RowCount:    len(rows),
+		CreatedAt:   time.Now(),
+	})
+	return nil
+}
+
+func (repo memorySalesRepository) Rows(teamID string, from time.Time, to time.Time) ([]types.SalesReportRow, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var rows []types.SalesReportRow
+	for _, row := range repo.salesRows {
+		if row.SlackTeamID == teamID && !row.ReportDate.Before(from) && !row.ReportDate.After(to) {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// newID hands out ids like an autoincrement column, the lock is held by the caller.
+func (memory *memoryRepositories) newID() uint {
+	memory.nextID += 1
+	return memory.nextID
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// memoryStore is a store that keeps a made up app in memory, it stands in for
+// a real store wherever handlers are exercised in isolation. Setting err fails
+// every request with it, failing only the requests it names.
+type memoryStore struct {
+	mu                    sync.Mutex
+	err                   error
+	failing               map[string]error
+	app                   types.AppMetadata
+	statuses              []types.AppCurrentStatus
+	betaGroups            []types.BetaGroup
+	inflightRelease       types.Release
+	liveRelease           types.Release
+	builds                []types.Build
+	inflightLocalizations []types.Localization
+	liveLocalizations     []types.Localization
+	submission            types.ReviewSubmission
+	messages              []types.ResolutionCenterMessage
+	reviews               []types.CustomerReview
+	salesReports          map[string][]byte
+	calls                 []string
+}
+
+func newMemoryStore() *memoryStore {
+	now := time.Now().UTC()
+
+	store := &memoryStore{
+		app: types.AppMetadata{
+			Id:       "1000000000",
+			Name:     "Ciderbot Demo",
+			BundleId: "com.example.ciderbot",
+			Sku:      "CIDERBOT_DEMO",
+		},
+		betaGroups: []types.BetaGroup{
+			{Id: "internal", Name: "Internal Testers", Internal: true},
+			{Id: "beta", Name: "Public Beta"},
+		},
+		salesReports: map[string][]byte{},
+	}
+
+	store.liveRelease = types.Release{
+		Id:            "live",
+		VersionName:   "1.1.0",
+		AppStoreState: "READY_FOR_SALE",
+		ReleaseType:   "AFTER_APPROVAL",
+		Downloadable:  true,
+		CreatedDate:   now.AddDate(0, 0, -5),
+		BuildNumber:   "110",
+		BuildId:       "build-110",
+	}
+	store.liveRelease.PhasedRelease.Id = "phased-live"
+	store.liveRelease.PhasedRelease.PhasedReleaseState = "ACTIVE"
+	store.liveRelease.PhasedRelease.StartDate = now.AddDate(0, 0, -2)
+	store.liveRelease.PhasedRelease.CurrentDayNumber = 3
+
+	store.inflightRelease = types.Release{
+		Id:            "inflight",
+		VersionName:   "1.2.0",
+		AppStoreState: "PREPARE_FOR_SUBMISSION",
+		ReleaseType:   "MANUAL",
+		CreatedDate:   now.AddDate(0, 0, -1),
+		BuildNumber:   "120",
+		BuildId:       "build-120",
+	}
+
+	for _, release := range []types.Release{store.liveRelease, store.inflightRelease} {
+		status := types.AppCurrentStatus{Name: release.VersionName}
+		status.Builds = append(status.Builds, currentStatusBuild(release.BuildId, release.BuildNumber, release.AppStoreState, release.VersionName, release.CreatedDate))
+		store.statuses = append(store.statuses, status)
+	}
+
+	store.builds = []types.Build{
+		{Id: "build-121", BuildNumber: "121", VersionString: "1.2.0", ProcessingState: "VALID", UploadedDate: now.Add(-time.Hour)},
+		{Id: "build-120", BuildNumber: "120", VersionString: "1.2.0", ProcessingState: "VALID", UploadedDate: now.AddDate(0, 0, -1)},
+		{Id: "build-119", BuildNumber: "119", VersionString: "1.2.0", ProcessingState: "PROCESSING", UploadedDate: now.Add(-time.Minute)},
+		{Id: "build-110", BuildNumber: "110", VersionString: "1.1.0", ProcessingState: "VALID", UploadedDate: now.AddDate(0, 0, -6)},
+	}
+
+	store.liveLocalizations = []types.Localization{
+		{Id: "live-en", Locale: "en-US", WhatsNew: "Bug fixes", Keywords: "cider,apples"},
+	}
+	store.inflightLocalizations = []types.Localization{
+		{Id: "inflight-en", Locale: "en-US", WhatsNew: "Bug fixes\nA new press", Keywords: "cider,apples,press"},
+	}
+
+	store.reviews = []types.CustomerReview{
+		{Id: "review-2", Rating: 1, Title: "Crashes", Body: "Crashes on launch", ReviewerNickname: "sour", Territory: "USA", CreatedDate: now.Add(-time.Hour)},
+		{Id: "review-1", Rating: 5, Title: "Lovely", Body: "Best cider app", ReviewerNickname: "sweet", Territory: "GBR", CreatedDate: now.AddDate(0, 0, -1)},
+	}
+
+	return store
+}
+
+func (store *memoryStore) call(name string) error {
+	store.calls = append(store.calls, name)
+	if err, ok := store.failing[name]; ok {
+		return err
+	}
+	return store.err
+}
+
+// calledTimes counts how often the store was asked for name.
+func (store *memoryStore) calledTimes(name string) int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	count := 0
+	for _, call := range store.calls {
+		if call == name {
+			count++
+		}
+	}
+	return count
+}
+
+// called tells whether the store was asked for name.
+func (store *memoryStore) called(name string) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return containsString(store.calls, name)
+}
+
+func (store *memoryStore) Platform() string {
+	return iosPlatform
+}
+
+func (store *memoryStore) AppMetadata(ctx context.Context) (types.AppMetadata, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.app, store.call("AppMetadata")
+}
+
+func (store *memoryStore) CurrentStatus(ctx context.Context) ([]types.AppCurrentStatus, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return append([]types.AppCurrentStatus(nil), store.statuses...), store.call("CurrentStatus")
+}
+
+func (store *memoryStore) BetaGroups(ctx context.Context) ([]types.BetaGroup, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return append([]types.BetaGroup(nil), store.betaGroups...), store.call("BetaGroups")
+}
+
+func (store *memoryStore) InflightRelease(ctx context.Context) (types.Release, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.call("InflightRelease"); err != nil {
+		return types.Release{}, err
+	}
+
+	if store.inflightRelease.Id == "" {
+		return types.Release{}, fmt.Errorf("memory store: there is no inflight release")
+	}
+
+	return store.inflightRelease, nil
+}
+
+func (store *memoryStore) LiveRelease(ctx context.Context) (types.Release, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.liveRelease, store.call("LiveRelease")
+}
+
+func (store *memoryStore) PauseRollout(ctx context.Context) (types.Release, error) {
+	return store.updatePhasedRelease("PauseRollout", "ACTIVE", "PAUSED")
+}
+
+func (store *memoryStore) ResumeRollout(ctx context.Context) (types.Release, error) {
+	return store.updatePhasedRelease("ResumeRollout", "PAUSED", "ACTIVE")
+}
+
+func (store *memoryStore) CompleteRollout(ctx context.Context) (types.Release, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.call("CompleteRollout"); err != nil {
+		return types.Release{}, err
+	}
+
+	state := store.liveRelease.PhasedRelease.PhasedReleaseState
+	if state != "ACTIVE" && state != "PAUSED" {
+		return types.Release{}, fmt.Errorf("memory store: the phased release is %s", state)
+	}
+
+	store.liveRelease.PhasedRelease.PhasedReleaseState = "COMPLETE"
+	return store.liveRelease, nil
+}
+
+func (store *memoryStore) updatePhasedRelease(name string, fromState string, toState string) (types.Release, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.call(name); err != nil {
+		return types.Release{}, err
+	}
+
+	state := store.liveRelease.PhasedRelease.PhasedReleaseState
+	if state != fromState {
+		return types.Release{}, fmt.Errorf("memory store: the phased release is %s, not %s", state, fromState)
+	}
+
+	store.liveRelease.PhasedRelease.PhasedReleaseState = toState
+	return store.liveRelease, nil
+}
+
+func (store *memoryStore) Builds(ctx context.Context, versionString string, limit int) ([]types.Build, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.call("Builds"); err != nil {
+		return nil, err
+	}
+
+	var builds []types.Build
+	for _, build := range store.builds {
+		if versionString != "" && build.VersionString != versionString {
+			continue
+		}
+		if len(builds) == limit {
+			break
+		}
+		builds = append(builds, build)
+	}
+
+	return builds, nil
+}
+
+func (store *memoryStore) Build(ctx context.Context, buildNumber string) (types.Build, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.call("Build"); err != nil {
+		return types.Build{}, err
+	}
+
+	for _, build := range store.builds {
+		if build.BuildNumber == buildNumber {
+			return build, nil
+		}
+	}
+
+	return types.Build{}, statusError{service: "memory", statusCode: http.StatusNotFound}
+}
+
+func (store *memoryStore) AttachBuild(ctx context.Context, buildID string) (types.Release, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.call("AttachBuild"); err != nil {
+		return types.Release{}, err
+	}
+
+	for _, build := range store.builds {
+		if build.Id == buildID {
+			store.inflightRelease.BuildId = build.Id
+			store.inflightRelease.BuildNumber = build.BuildNumber
+			return store.inflightRelease, nil
+		}
+	}
+
+	return types.Release{}, statusError{service: "memory", statusCode: http.StatusNotFound}
+}
+
+func (store *memoryStore) CreateVersion(ctx context.Context, versionString string, releaseType string, earliestReleaseDate *time.Time, phasedRelease bool) (types.Release, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.call("CreateVersion"); err != nil {
+		return types.Release{}, err
+	}
+
+	store.inflightRelease = types.Release{
+		Id:                  "inflight-" + versionString,
+		VersionName:         versionString,
+		AppStoreState:       "PREPARE_FOR_SUBMISSION",
+		ReleaseType:         releaseType,
+		EarliestReleaseDate: earliestReleaseDate,
+		CreatedDate:         time.Now().UTC(),
+	}
+	if phasedRelease {
+		store.inflightRelease.PhasedRelease.Id = "phased-" + versionString
+	}
+
+	return store.inflightRelease, nil
+}
+
+func (store *memoryStore) UpdateInflightRelease(ctx context.Context, changes map[string]interface{}) (types.Release, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.call("UpdateInflightRelease"); err != nil {
+		return types.Release{}, err
+	}
+
+	if releaseType, ok := changes["release_type"].(string); ok {
+		store.inflightRelease.ReleaseType = releaseType
+	}
+
+	if value, ok := changes["earliest_release_date"]; ok {
+		store.inflightRelease.EarliestReleaseDate = nil
+		if text, ok := value.(string); ok {
+			releaseDate, err := time.Parse(time.RFC3339, text)
+			if err != nil {
+				return types.Release{}, err
+			}
+			store.inflightRelease.EarliestReleaseDate = &releaseDate
+		}
+	}
+
+	if phased, ok := changes["is_phased_release"].(bool); ok {
+		store.inflightRelease.PhasedRelease.Id = ""
+		if phased {
+			store.inflightRelease.PhasedRelease.Id = "phased-" + store.inflightRelease.VersionName
+		}
+	}
+
+	return store.inflightRelease, nil
+}
+
+func (store *memoryStore) ReleaseInflight(ctx context.Context) (types.Release, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.call("ReleaseInflight"); err != nil {
+		return types.Release{}, err
+	}
+
+	if store.inflightRelease.AppStoreState != pendingDeveloperReleaseState {
+		return types.Release{}, fmt.Errorf("memory store: %s is %s", store.inflightRelease.VersionName, store.inflightRelease.AppStoreState)
+	}
+
+	store.liveRelease = store.inflightRelease
+	store.liveRelease.AppStoreState = liveAppStoreState
+	if store.liveRelease.PhasedRelease.Id != "" {
+		store.liveRelease.PhasedRelease.PhasedReleaseState = "ACTIVE"
+		store.liveRelease.PhasedRelease.CurrentDayNumber = 1
+		store.liveRelease.PhasedRelease.StartDate = time.Now().UTC()
+	}
+	store.inflightRelease = types.Release{}
+
+	return store.liveRelease, nil
+}
+
+func (store *memoryStore) InflightLocalizations(ctx context.Context) ([]types.Localization, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return append([]types.Localization(nil), store.inflightLocalizations...), store.call("InflightLocalizations")
+}
+
+func (store *memoryStore) LiveLocalizations(ctx context.Context) ([]types.Localization, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return append([]types.Localization(nil), store.liveLocalizations...), store.call("LiveLocalizations")
+}
+
+func (store *memoryStore) ReviewSubmission(ctx context.Context) (types.ReviewSubmission, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.submission, store.call("ReviewSubmission")
+}
+
+func (store *memoryStore) ResolutionCenterMessages(ctx context.Context) ([]types.ResolutionCenterMessage, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return append([]types.ResolutionCenterMessage(nil), store.messages...), store.call("ResolutionCenterMessages")
+}
+
+func (store *memoryStore) CustomerReviews(ctx context.Context, territory string, limit int) ([]types.CustomerReview, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.call("CustomerReviews"); err != nil {
+		return nil, err
+	}
+
+	var reviews []types.CustomerReview
+	for _, review := range store.reviews {
+		if territory != "" && review.Territory != territory {
+			continue
+		}
+		if len(reviews) == limit {
+			break
+		}
+		reviews = append(reviews, review)
+	}
+
+	return reviews, nil
+}
+
+func (store *memoryStore) ReplyToReview(ctx context.Context, reviewID string, responseBody string) (types.CustomerReviewResponse, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.call("ReplyToReview"); err != nil {
+		return types.CustomerReviewResponse{}, err
+	}
+
+	for i, review := range store.reviews {
+		if review.Id == reviewID {
+			response := &types.CustomerReviewResponse{Id: "response-" + reviewID, Body: responseBody, State: "PENDING_PUBLISH"}
+			store.reviews[i].Response = response
+			return *response, nil
+		}
+	}
+
+	return types.CustomerReviewResponse{}, statusError{service: "memory", statusCode: http.StatusNotFound}
+}
+
+func (store *memoryStore) SalesReport(ctx context.Context, vendorNumber string, reportDate time.Time) ([]byte, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.call("SalesReport"); err != nil {
+		return nil, err
+	}
+
+	report, ok := store.salesReports[reportDate.Format("2006-01-02")]
+	if !ok {
+		return nil, statusError{service: "memory", statusCode: http.StatusNotFound}
+	}
+
+	return report, nil
+}
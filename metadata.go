@@ -0,0 +1,220 @@
+package main
+
+import (
+	slack "ciderbot/slack"
+	"ciderbot/types"
+	"context"
+	"sort"
+	"strings"
+)
+
+type localizedField struct {
+	Name  string
+	Value func(types.Localization) string
+	Diff  func(before string, after string) string
+}
+
+var localizedFields = []localizedField{
+	{"What's New", func(l types.Localization) string { return l.WhatsNew }, diffLines},
+	{"Promotional Text", func(l types.Localization) string { return l.PromotionalText }, diffLines},
+	{"Description", func(l types.Localization) string { return l.Description }, diffLines},
+	{"Keywords", func(l types.Localization) string { return l.Keywords }, diffKeywords},
+}
+
+func handleMetadataCommand(ctx context.Context, form types.SlackFormData, store StoreClient) types.SlackResponse {
+	flags, _ := commandFlags(commandArgs(form.Text))
+
+	inflightRelease, err := store.InflightRelease(ctx)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find an inflight release for your app."}.Render()
+	}
+
+	liveRelease, err := store.LiveRelease(ctx)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find a live release for your app."}.Render()
+	}
+
+	inflightLocalizations, err := store.InflightLocalizations(ctx)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find the localizations of the inflight release."}.Render()
+	}
+
+	liveLocalizations, err := store.LiveLocalizations(ctx)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find the localizations of the live release."}.Render()
+	}
+
+	metadataDiff := slack.MetadataDiff{
+		LiveVersion:     liveRelease.VersionName,
+		InflightVersion: inflightRelease.VersionName,
+		Locales:         diffLocalizations(liveLocalizations, inflightLocalizations, flags["locale"]),
+	}
+
+	return metadataDiff.Render()
+}
+
+// diffLocalizations compares the copy of every locale of the live version
+// with the inflight version, leaving out the locales that did not change.
+func diffLocalizations(live []types.Localization, inflight []types.Localization, onlyLocale string) []slack.LocaleDiff {
+	liveByLocale := map[string]types.Localization{}
+	for _, localization := range live {
+		liveByLocale[localization.Locale] = localization
+	}
+
+	inflightByLocale := map[string]types.Localization{}
+	for _, localization := range inflight {
+		inflightByLocale[localization.Locale] = localization
+	}
+
+	var locales []string
+	for locale := range liveByLocale {
+		locales = append(locales, locale)
+	}
+	for locale := range inflightByLocale {
+		if _, ok := liveByLocale[locale]; !ok {
+			locales = append(locales, locale)
+		}
+	}
+	sort.Strings(locales)
+
+	var diffs []slack.LocaleDiff
+	for _, locale := range locales {
+		if onlyLocale != "" && !strings.EqualFold(onlyLocale, locale) {
+			continue
+		}
+
+		before, inLive := liveByLocale[locale]
+		after, inInflight := inflightByLocale[locale]
+
+		diff := slack.LocaleDiff{Locale: locale}
+		switch {
+		case !inLive:
+			diff.Status = "added"
+		case !inInflight:
+			diff.Status = "removed"
+		default:
+			diff.Status = "changed"
+		}
+
+		for _, field := range localizedFields {
+			beforeValue, afterValue := field.Value(before), field.Value(after)
+			if beforeValue == afterValue {
+				continue
+			}
+
+			fieldDiff := field.Diff(beforeValue, afterValue)
+			if fieldDiff == "" {
+				fieldDiff = "(only the spacing or order changed)"
+			}
+
+			diff.Fields = append(diff.Fields, slack.FieldDiff{
+				Name: field.Name,
+				Diff: fieldDiff,
+			})
+		}
+
+		if len(diff.Fields) > 0 || diff.Status != "changed" {
+			diffs = append(diffs, diff)
+		}
+	}
+
+	return diffs
+}
+
+// diffLines marks the lines removed from and added to a text, using the
+// longest common subsequence of lines to keep the unchanged ones out.
+func diffLines(before string, after string) string {
+	beforeLines := splitLines(before)
+	afterLines := splitLines(after)
+
+	lcs := make([][]int, len(beforeLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(afterLines)+1)
+	}
+
+	for i := len(beforeLines) - 1; i >= 0; i-- {
+		for j := len(afterLines) - 1; j >= 0; j-- {
+			if beforeLines[i] == afterLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(beforeLines) && j < len(afterLines) {
+		switch {
+		case beforeLines[i] == afterLines[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "- "+beforeLines[i])
+			i++
+		default:
+			diff = append(diff, "+ "+afterLines[j])
+			j++
+		}
+	}
+
+	for ; i < len(beforeLines); i++ {
+		diff = append(diff, "- "+beforeLines[i])
+	}
+	for ; j < len(afterLines); j++ {
+		diff = append(diff, "+ "+afterLines[j])
+	}
+
+	return strings.Join(diff, "\n")
+}
+
+func diffKeywords(before string, after string) string {
+	beforeKeywords := splitKeywords(before)
+	afterKeywords := splitKeywords(after)
+
+	var diff []string
+	for _, keyword := range beforeKeywords {
+		if !containsString(afterKeywords, keyword) {
+			diff = append(diff, "- "+keyword)
+		}
+	}
+
+	for _, keyword := range afterKeywords {
+		if !containsString(beforeKeywords, keyword) {
+			diff = append(diff, "+ "+keyword)
+		}
+	}
+
+	return strings.Join(diff, "\n")
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+func splitKeywords(keywords string) []string {
+	var split []string
+	for _, keyword := range strings.Split(keywords, ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			split = append(split, keyword)
+		}
+	}
+
+	return split
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	slack "ciderbot/slack"
+	"ciderbot/types"
+	"reflect"
+	"testing"
+)
+
+func TestDiffLinesKeepsTheUnchangedLinesOut(t *testing.T) {
+	tests := []struct {
+		before string
+		after  string
+		want   string
+	}{
+		{"Bug fixes", "Bug fixes", ""},
+		{"", "Bug fixes", "+ Bug fixes"},
+		{"Bug fixes", "", "- Bug fixes"},
+		{"Bug fixes\nA new press", "Bug fixes\nA new cellar\nA new press", "+ A new cellar"},
+		{"One\nTwo\nThree", "One\nThree", "- Two"},
+		{"One\r\nTwo", "One\nTwo", ""},
+		{"Old", "New", "- Old\n+ New"},
+	}
+
+	for _, test := range tests {
+		if got := diffLines(test.before, test.after); got != test.want {
+			t.Errorf("diffLines(%q, %q) = %q, want %q", test.before, test.after, got, test.want)
+		}
+	}
+}
+
+func TestDiffKeywordsIgnoresSpacingAndOrder(t *testing.T) {
+	tests := []struct {
+		before string
+		after  string
+		want   string
+	}{
+		{"cider,apples", "apples, cider", ""},
+		{"cider,apples", "cider,apples,press", "+ press"},
+		{"cider, apples", "cider", "- apples"},
+		{"cider,,apples", "perry", "- cider\n- apples\n+ perry"},
+	}
+
+	for _, test := range tests {
+		if got := diffKeywords(test.before, test.after); got != test.want {
+			t.Errorf("diffKeywords(%q, %q) = %q, want %q", test.before, test.after, got, test.want)
+		}
+	}
+}
+
+func TestDiffLocalizationsListsTheLocalesThatChanged(t *testing.T) {
+	live := []types.Localization{
+		{Locale: "en-US", WhatsNew: "Bug fixes", Keywords: "cider,apples"},
+		{Locale: "fr-FR", WhatsNew: "Corrections"},
+		{Locale: "de-DE", WhatsNew: "Fehlerbehebungen", Keywords: "apfel,wein"},
+	}
+	inflight := []types.Localization{
+		{Locale: "en-US", WhatsNew: "Bug fixes\nA new press", Keywords: "cider,apples"},
+		{Locale: "de-DE", WhatsNew: "Fehlerbehebungen", Keywords: "wein, apfel"},
+		{Locale: "ja", WhatsNew: "バグ修正"},
+	}
+
+	want := []slack.LocaleDiff{
+		{Locale: "de-DE", Status: "changed", Fields: []slack.FieldDiff{{Name: "Keywords", Diff: "(only the spacing or order changed)"}}},
+		{Locale: "en-US", Status: "changed", Fields: []slack.FieldDiff{{Name: "What's New", Diff: "+ A new press"}}},
+		{Locale: "fr-FR", Status: "removed", Fields: []slack.FieldDiff{{Name: "What's New", Diff: "- Corrections"}}},
+		{Locale: "ja", Status: "added", Fields: []slack.FieldDiff{{Name: "What's New", Diff: "+ バグ修正"}}},
+	}
+	if got := diffLocalizations(live, inflight, ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffLocalizations() = %+v, want %+v", got, want)
+	}
+
+	if got := diffLocalizations(live, inflight, "EN-us"); len(got) != 1 || got[0].Locale != "en-US" {
+		t.Errorf("diffLocalizations() of en-US = %+v", got)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"ciderbot/types"
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+const metricsNamespace = "ciderbot"
+
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	commandsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "commands_total",
+		Help:      "Slack commands run from the queue, by command and outcome.",
+	}, []string{"command", "outcome"})
+
+	commandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "command_duration_seconds",
+		Help:      "Time taken to run a Slack command, without delivering its response.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 10),
+	}, []string{"command"})
+
+	applelinkRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "applelink_request_duration_seconds",
+		Help:      "Latency of applelink requests, by endpoint and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "endpoint", "status_code"})
+
+	slackDeliveriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "slack_deliveries_total",
+		Help:      "Messages posted to Slack, by method and outcome.",
+	}, []string{"method", "outcome"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		commandsTotal,
+		commandDuration,
+		applelinkRequestDuration,
+		slackDeliveriesTotal,
+	)
+}
+
+// initMetrics adds the gauges that are read from the database when scraped.
+func initMetrics(db *gorm.DB) {
+	metricsRegistry.MustRegister(databaseCollector{db: db})
+}
+
+// handleMetrics serves the metrics, behind a bearer token when one is configured.
+func handleMetrics(token string) gin.HandlerFunc {
+	metricsHandler := promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+
+	return func(c *gin.Context) {
+		if token != "" {
+			expected := []byte("Bearer " + token)
+			if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), expected) != 1 {
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+		}
+
+		metricsHandler.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+func observeCommand(command string, outcome string) {
+	commandsTotal.WithLabelValues(command, outcome).Inc()
+}
+
+func observeSlackDelivery(method string, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+
+	slackDeliveriesTotal.WithLabelValues(method, outcome).Inc()
+}
+
+// observeApplelinkRequest records the latency of a request, status code 0
+// meaning that applelink could not be reached.
+func observeApplelinkRequest(httpMethod string, requestURL string, statusCode int, startedAt time.Time) {
+	applelinkRequestDuration.
+		WithLabelValues(httpMethod, applelinkEndpoint(requestURL), strconv.Itoa(statusCode)).
+		Observe(time.Since(startedAt).Seconds())
+}
+
+// applelinkEndpoint turns a request url into its route, so that bundle ids,
+// build numbers and review ids do not end up as label values.
+func applelinkEndpoint(requestURL string) string {
+	parsedURL, err := url.Parse(requestURL)
+	if err != nil {
+		return "unknown"
+	}
+
+	segments := strings.Split(strings.Trim(parsedURL.Path, "/"), "/")
+	for i := 1; i < len(segments); i++ {
+		switch segments[i-1] {
+		case "apps":
+			segments[i] = ":app"
+		case "builds", "customer_reviews":
+			segments[i] = ":id"
+		}
+	}
+
+	return "/" + strings.Join(segments, "/")
+}
+
+// databaseCollector reports the size of the install and the backlog of the
+// command queue.
+type databaseCollector struct {
+	db *gorm.DB
+}
+
+var (
+	workspacesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "workspaces"),
+		"Slack workspaces with the bot installed.",
+		nil, nil,
+	)
+	appsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "apps"),
+		"Connected apps, by platform.",
+		[]string{"platform"}, nil,
+	)
+	commandQueueDepthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "command_queue_depth"),
+		"Commands waiting in the queue or running, by status.",
+		[]string{"status"}, nil,
+	)
+)
+
+func (collector databaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- workspacesDesc
+	ch <- appsDesc
+	ch <- commandQueueDepthDesc
+}
+
+func (collector databaseCollector) Collect(ch chan<- prometheus.Metric) {
+	var workspaces, iosApps, androidApps int64
+	collector.db.Model(&types.User{}).Where("slack_team_id IS NOT NULL AND slack_access_token IS NOT NULL").Count(&workspaces)
+	collector.db.Model(&types.User{}).Where("app_store_connected = ?", true).Count(&iosApps)
+	collector.db.Model(&types.User{}).Where("play_connected = ?", true).Count(&androidApps)
+
+	ch <- prometheus.MustNewConstMetric(workspacesDesc, prometheus.GaugeValue, float64(workspaces))
+	ch <- prometheus.MustNewConstMetric(appsDesc, prometheus.GaugeValue, float64(iosApps), iosPlatform)
+	ch <- prometheus.MustNewConstMetric(appsDesc, prometheus.GaugeValue, float64(androidApps), androidPlatform)
+
+	for _, status := range []string{commandQueued, commandRunning} {
+		var depth int64
+		collector.db.Model(&types.CommandJob{}).Where("status = ?", status).Count(&depth)
+		ch <- prometheus.MustNewConstMetric(commandQueueDepthDesc, prometheus.GaugeValue, float64(depth), status)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestApplelinkEndpointLeavesIdsOutOfTheLabels(t *testing.T) {
+	tests := map[string]string{
+		"http://applelink/apple/connect/v1/apps/com.example.ciderbot":                      "/apple/connect/v1/apps/:app",
+		"http://applelink/apple/connect/v1/apps/com.example.ciderbot/builds/121":           "/apple/connect/v1/apps/:app/builds/:id",
+		"http://applelink/apple/connect/v1/apps/com.example.ciderbot/customer_reviews/r-1": "/apple/connect/v1/apps/:app/customer_reviews/:id",
+		"http://applelink/apple/connect/v1/apps/com.example.ciderbot/release/live?limit=1": "/apple/connect/v1/apps/:app/release/live",
+		"http://applelink/ping": "/ping",
+		"://not a url":          "unknown",
+	}
+
+	for requestURL, want := range tests {
+		if got := applelinkEndpoint(requestURL); got != want {
+			t.Errorf("applelinkEndpoint(%q) = %q, want %q", requestURL, got, want)
+		}
+	}
+}
+
+func TestMetricsNeedTheToken(t *testing.T) {
+	router := gin.New()
+	router.GET("/metrics", handleMetrics("secret"))
+
+	for header, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if header != "" {
+			request.Header.Set("Authorization", header)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != want {
+			t.Errorf("the metrics answered %d to %q, want %d", recorder.Code, header, want)
+		}
+		if want == http.StatusOK && !strings.Contains(recorder.Body.String(), "ciderbot_commands_total") {
+			t.Errorf("the metrics do not count the commands")
+		}
+	}
+}
+
+func TestCommandIsCountedWithItsDelivery(t *testing.T) {
+	fakeSlackAPI(t)
+	repos := newMemoryRepositories()
+	connectTestWorkspace(t, repos)
+	stores := func(user *types.User) StoreClient { return newMemoryStore() }
+
+	commands := commandsTotal.WithLabelValues("app_info", commandDelivered)
+	deliveries := slackDeliveriesTotal.WithLabelValues("chat.postMessage", "success")
+	commandsBefore, deliveriesBefore := testutil.ToFloat64(commands), testutil.ToFloat64(deliveries)
+
+	enqueueTestCommand(t, repos, "T0001")
+	job, err := repos.Commands.ClaimNext(time.Now().UTC())
+	if err != nil || job == nil {
+		t.Fatalf("could not claim the command: %v", err)
+	}
+	runCommandJob(context.Background(), repos, stores, job)
+
+	if got := testutil.ToFloat64(commands) - commandsBefore; got != 1 {
+		t.Errorf("counted %f delivered app_info commands, want 1", got)
+	}
+	if got := testutil.ToFloat64(deliveries) - deliveriesBefore; got != 1 {
+		t.Errorf("counted %f messages posted to Slack, want 1", got)
+	}
+}
+
+func TestDatabaseCollectorReportsTheQueue(t *testing.T) {
+	db := testDatabase(t)
+	repos := newGormRepositories(db)
+	connectTestWorkspace(t, repos)
+	for i := 0; i < 3; i++ {
+		enqueueTestCommand(t, repos, "T0001")
+	}
+	if job, err := repos.Commands.ClaimNext(time.Now().UTC()); err != nil || job == nil {
+		t.Fatalf("could not claim a command: %v", err)
+	}
+
+	expected := `
+# HELP ciderbot_apps Connected apps, by platform.
+# TYPE ciderbot_apps gauge
+ciderbot_apps{platform="android"} 0
+ciderbot_apps{platform="ios"} 1
+# HELP ciderbot_command_queue_depth Commands waiting in the queue or running, by status.
+# TYPE ciderbot_command_queue_depth gauge
+ciderbot_command_queue_depth{status="queued"} 2
+ciderbot_command_queue_depth{status="running"} 1
+# HELP ciderbot_workspaces Slack workspaces with the bot installed.
+# TYPE ciderbot_workspaces gauge
+ciderbot_workspaces 1
+`
+	if err := testutil.CollectAndCompare(databaseCollector{db: db}, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,561 @@
+package main
+
+import (
+	"ciderbot/types"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Any number works as long as it is the same for every replica.
+const migrationLockKey = 7238140918
+
+type migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// migrations are applied in order and never edited once released, a change to
+// the schema is a new migration at the end of the list. Their DDL is written
+// out rather than derived from the models, so that changing a model does not
+// change what an old migration does.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "create tables",
+		// Deployments from before versioned migrations already have these
+		// tables and keep them as they are.
+		Up: func(tx *gorm.DB) error {
+			return execSchema(tx,
+				`CREATE TABLE IF NOT EXISTS users (
+					email text PRIMARY KEY,
+					provider_id text,
+					provider text,
+					name text,
+					avatar_url text,
+					slack_access_token text,
+					slack_refresh_token text,
+					slack_team_id text,
+					slack_team_name text,
+					app_store_bundle_id text,
+					app_store_issuer_id text,
+					app_store_key_id text,
+					app_store_vendor_number text,
+					app_store_connected boolean DEFAULT false,
+					app_store_p8_file {{blob}},
+					app_store_p8_file_iv {{blob}},
+					play_package_name text,
+					play_connected boolean DEFAULT false,
+					play_service_account {{blob}},
+					play_service_account_iv {{blob}},
+					platform text DEFAULT 'ios',
+					command_count bigint,
+					created_at {{time}},
+					updated_at {{time}}
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_name ON users (provider_id)`,
+				`CREATE TABLE IF NOT EXISTS metrics (
+					id {{id}},
+					deleted_users bigint
+				)`,
+				`CREATE TABLE IF NOT EXISTS scheduled_jobs (
+					id {{id}},
+					kind text,
+					slack_team_id text,
+					slack_channel_id text,
+					schedule text,
+					timezone text,
+					payload text,
+					state text,
+					next_run_at {{time}},
+					last_run_at {{time}},
+					lock_version bigint,
+					created_at {{time}},
+					updated_at {{time}}
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_scheduled_jobs_next_run_at ON scheduled_jobs (next_run_at)`,
+				`CREATE INDEX IF NOT EXISTS idx_scheduled_job_owner ON scheduled_jobs (kind, slack_team_id, slack_channel_id)`,
+				`CREATE TABLE IF NOT EXISTS command_jobs (
+					id {{id}},
+					slack_team_id text,
+					command text,
+					form text,
+					response_url text,
+					status text,
+					attempts bigint,
+					next_attempt_at {{time}},
+					locked_until {{time}},
+					expires_at {{time}},
+					response text,
+					last_error text,
+					lock_version bigint,
+					created_at {{time}},
+					updated_at {{time}}
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_command_jobs_slack_team_id ON command_jobs (slack_team_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_command_jobs_status ON command_jobs (status)`,
+				`CREATE INDEX IF NOT EXISTS idx_command_jobs_next_attempt_at ON command_jobs (next_attempt_at)`,
+				`CREATE TABLE IF NOT EXISTS slack_response_urls (
+					id {{id}},
+					url_hash text,
+					uses bigint,
+					created_at {{time}}
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_slack_response_urls_url_hash ON slack_response_urls (url_hash)`,
+				`CREATE INDEX IF NOT EXISTS idx_slack_response_urls_created_at ON slack_response_urls (created_at)`,
+				`CREATE TABLE IF NOT EXISTS slack_delivery_failures (
+					id {{id}},
+					slack_team_id text,
+					slack_channel_id text,
+					method text,
+					status_code bigint,
+					error text,
+					created_at {{time}}
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_slack_delivery_failures_slack_team_id ON slack_delivery_failures (slack_team_id)`,
+				`CREATE TABLE IF NOT EXISTS posted_reviews (
+					id {{id}},
+					slack_team_id text,
+					review_id text,
+					slack_channel_id text,
+					replied_by text,
+					replied_at {{time}},
+					created_at {{time}},
+					updated_at {{time}}
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_posted_review ON posted_reviews (slack_team_id, review_id)`,
+				`CREATE TABLE IF NOT EXISTS review_ratings (
+					id {{id}},
+					slack_team_id text,
+					review_id text,
+					territory text,
+					rating bigint,
+					created_date {{time}}
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_review_rating ON review_ratings (slack_team_id, review_id)`,
+				`CREATE TABLE IF NOT EXISTS rating_snapshots (
+					id {{id}},
+					slack_team_id text,
+					territory text,
+					captured_on {{time}},
+					review_count bigint,
+					average_rating double precision
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_rating_snapshot ON rating_snapshots (slack_team_id, territory, captured_on)`,
+				`CREATE TABLE IF NOT EXISTS sales_report_days (
+					id {{id}},
+					slack_team_id text,
+					report_date {{time}},
+					row_count bigint,
+					created_at {{time}}
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_sales_report_day ON sales_report_days (slack_team_id, report_date)`,
+				`CREATE TABLE IF NOT EXISTS sales_report_rows (
+					id {{id}},
+					slack_team_id text,
+					report_date {{time}},
+					sku text,
+					title text,
+					version text,
+					product_type_identifier text,
+					units bigint,
+					developer_proceeds double precision,
+					currency_of_proceeds text,
+					customer_price double precision,
+					customer_currency text,
+					country_code text,
+					apple_identifier text,
+					parent_identifier text,
+					device text
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_sales_report_row ON sales_report_rows (slack_team_id, report_date)`,
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return execSchema(tx,
+				`DROP TABLE IF EXISTS sales_report_rows`,
+				`DROP TABLE IF EXISTS sales_report_days`,
+				`DROP TABLE IF EXISTS rating_snapshots`,
+				`DROP TABLE IF EXISTS review_ratings`,
+				`DROP TABLE IF EXISTS posted_reviews`,
+				`DROP TABLE IF EXISTS slack_delivery_failures`,
+				`DROP TABLE IF EXISTS slack_response_urls`,
+				`DROP TABLE IF EXISTS command_jobs`,
+				`DROP TABLE IF EXISTS scheduled_jobs`,
+				`DROP TABLE IF EXISTS metrics`,
+				`DROP TABLE IF EXISTS users`,
+			)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create audit events",
+		Up: func(tx *gorm.DB) error {
+			return execSchema(tx,
+				`CREATE TABLE audit_events (
+					id {{id}},
+					actor_email text,
+					slack_team_id text,
+					action text,
+					detail text,
+					created_at {{time}}
+				)`,
+				`CREATE INDEX idx_audit_events_slack_team_id ON audit_events (slack_team_id)`,
+				`CREATE INDEX idx_audit_events_created_at ON audit_events (created_at)`,
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return execSchema(tx, `DROP TABLE audit_events`)
+		},
+	},
+	{
+		Version: 3,
+		Name:    "add request id to command jobs",
+		Up: func(tx *gorm.DB) error {
+			return execSchema(tx, `ALTER TABLE command_jobs ADD COLUMN request_id text`)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, "command_jobs", "request_id")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add trace parent to command jobs",
+		Up: func(tx *gorm.DB) error {
+			return execSchema(tx, `ALTER TABLE command_jobs ADD COLUMN trace_parent text`)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, "command_jobs", "trace_parent")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "drop unique scheduled job index",
+		// Scheduled jobs used to be unique per kind and channel, which kept
+		// a second guardrail rule from being added. Tables created before
+		// versioned migrations still have that index.
+		Up: func(tx *gorm.DB) error {
+			return execSchema(tx, `DROP INDEX IF EXISTS idx_scheduled_job`)
+		},
+		// The index is not brought back, the rules it blocks may exist by now.
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	},
+	{
+		Version: 6,
+		Name:    "add slack scopes to users",
+		Up: func(tx *gorm.DB) error {
+			return execSchema(tx, `ALTER TABLE users ADD COLUMN slack_scopes text`)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, "users", "slack_scopes")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "make posted reviews unique per channel",
+		// A review is posted once in every channel that watches the reviews.
+		Up: func(tx *gorm.DB) error {
+			return execSchema(tx,
+				`DROP INDEX IF EXISTS idx_posted_review`,
+				`CREATE UNIQUE INDEX idx_posted_review ON posted_reviews (slack_team_id, slack_channel_id, review_id)`,
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return execSchema(tx,
+				`DROP INDEX IF EXISTS idx_posted_review`,
+				`CREATE UNIQUE INDEX idx_posted_review ON posted_reviews (slack_team_id, review_id)`,
+			)
+		},
+	},
+	{
+		Version: 8,
+		Name:    "create release requests",
+		Up: func(tx *gorm.DB) error {
+			return execSchema(tx,
+				`CREATE TABLE release_requests (
+					id {{id}},
+					slack_team_id text,
+					version_string text,
+					requested_by text,
+					created_at {{time}}
+				)`,
+				`CREATE UNIQUE INDEX idx_release_request ON release_requests (slack_team_id, version_string)`,
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return execSchema(tx, `DROP TABLE release_requests`)
+		},
+	},
+	{
+		Version: 9,
+		Name:    "add store columns to existing users",
+		// The users table of deployments from before versioned migrations was
+		// kept by version 1 without the columns added since, its users were
+		// all on the App Store.
+		Up: func(tx *gorm.DB) error {
+			return addMissingColumns(tx, "users",
+				"app_store_vendor_number text",
+				"play_package_name text",
+				"play_connected boolean DEFAULT false",
+				"play_service_account {{blob}}",
+				"play_service_account_iv {{blob}}",
+				"platform text DEFAULT 'ios'",
+			)
+		},
+		// The columns are part of the users table of version 1 otherwise.
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	},
+}
+
+// Column types that are spelled differently by each database, the DDL of the
+// migrations refers to them as {{id}}, {{blob}} and {{time}}.
+var schemaTypes = map[string]*strings.Replacer{
+	postgresDialect: strings.NewReplacer("{{id}}", "bigserial PRIMARY KEY", "{{blob}}", "bytea", "{{time}}", "timestamptz"),
+	sqliteDialect:   strings.NewReplacer("{{id}}", "integer PRIMARY KEY", "{{blob}}", "blob", "{{time}}", "datetime"),
+}
+
+// execSchema runs DDL statements, filling in the column types of the database.
+func execSchema(tx *gorm.DB, statements ...string) error {
+	replacer, ok := schemaTypes[tx.Dialector.Name()]
+	if !ok {
+		return fmt.Errorf("migrate: unsupported database %s", tx.Dialector.Name())
+	}
+
+	for _, statement := range statements {
+		if err := tx.Exec(replacer.Replace(statement)).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addMissingColumns adds the columns, given by their definition, that the
+// table does not have yet.
+func addMissingColumns(tx *gorm.DB, table string, definitions ...string) error {
+	for _, definition := range definitions {
+		column := strings.Fields(definition)[0]
+		if tx.Migrator().HasColumn(table, column) {
+			continue
+		}
+
+		if err := execSchema(tx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, definition)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dropColumn removes a column added by ALTER TABLE. The SQLite we ship with
+// predates DROP COLUMN, there the table is copied without it.
+func dropColumn(tx *gorm.DB, table string, column string) error {
+	if tx.Dialector.Name() != sqliteDialect {
+		return execSchema(tx, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column))
+	}
+
+	var createSQL string
+	if err := tx.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&createSQL).Error; err != nil {
+		return err
+	}
+
+	var indexSQL []string
+	if err := tx.Raw("SELECT sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", table).Scan(&indexSQL).Error; err != nil {
+		return err
+	}
+
+	var columns []struct{ Name string }
+	if err := tx.Raw(fmt.Sprintf("PRAGMA table_info(%s)", table)).Scan(&columns).Error; err != nil {
+		return err
+	}
+
+	var kept []string
+	for _, c := range columns {
+		if c.Name != column {
+			kept = append(kept, c.Name)
+		}
+	}
+
+	definition := regexp.MustCompile(",\\s*[\"`]?" + regexp.QuoteMeta(column) + "[\"`]?\\s+[^,()]*")
+	if !definition.MatchString(createSQL) {
+		return fmt.Errorf("migrate: could not find the column %s of %s", column, table)
+	}
+
+	statements := []string{
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s__old", table, table),
+		definition.ReplaceAllString(createSQL, ""),
+		fmt.Sprintf("INSERT INTO %s SELECT %s FROM %s__old", table, strings.Join(kept, ", "), table),
+		fmt.Sprintf("DROP TABLE %s__old", table),
+	}
+	for _, index := range indexSQL {
+		if !strings.Contains(index, column) {
+			statements = append(statements, index)
+		}
+	}
+
+	return execSchema(tx, statements...)
+}
+
+// runMigrateCommand handles `ciderbot migrate [up|down [steps]|status]`.
+func runMigrateCommand(db *gorm.DB, args []string) error {
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		return migrateUp(db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			parsedSteps, err := strconv.Atoi(args[1])
+			if err != nil || parsedSteps < 1 {
+				return fmt.Errorf("migrate: invalid number of steps %s", args[1])
+			}
+			steps = parsedSteps
+		}
+		return migrateDown(db, steps)
+	case "status":
+		return printMigrationStatus(db)
+	default:
+		return fmt.Errorf("migrate: unknown action %s, use up, down or status", action)
+	}
+}
+
+func migrateUp(db *gorm.DB) error {
+	return withMigrationLock(db, func(conn *gorm.DB) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if applied[m.Version] {
+				continue
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.Up(tx); err != nil {
+					return err
+				}
+
+				return tx.Create(&types.SchemaMigration{Version: m.Version, Name: m.Name}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migrate: %d %s failed: %w", m.Version, m.Name, err)
+			}
+
+			slog.Info("migrate: applied", "version", m.Version, "name", m.Name)
+		}
+
+		return nil
+	})
+}
+
+func migrateDown(db *gorm.DB, steps int) error {
+	return withMigrationLock(db, func(conn *gorm.DB) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if !applied[m.Version] {
+				continue
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.Down(tx); err != nil {
+					return err
+				}
+
+				return tx.Where("version = ?", m.Version).Delete(&types.SchemaMigration{}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migrate: rolling back %d %s failed: %w", m.Version, m.Name, err)
+			}
+
+			slog.Info("migrate: rolled back", "version", m.Version, "name", m.Name)
+			steps--
+		}
+
+		return nil
+	})
+}
+
+func printMigrationStatus(db *gorm.DB) error {
+	if err := db.AutoMigrate(&types.SchemaMigration{}); err != nil {
+		return err
+	}
+
+	var records []types.SchemaMigration
+	if err := db.Order("version").Find(&records).Error; err != nil {
+		return err
+	}
+
+	appliedAt := map[int]time.Time{}
+	for _, record := range records {
+		appliedAt[record.Version] = record.AppliedAt
+	}
+
+	for _, m := range migrations {
+		status := "pending"
+		if at, ok := appliedAt[m.Version]; ok {
+			status = "applied " + at.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(os.Stdout, "%4d  %-30s %s\n", m.Version, m.Name, status)
+	}
+
+	return nil
+}
+
+func appliedMigrations(db *gorm.DB) (map[int]bool, error) {
+	if err := db.AutoMigrate(&types.SchemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	var records []types.SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	applied := map[int]bool{}
+	for _, record := range records {
+		applied[record.Version] = true
+	}
+
+	return applied, nil
+}
+
+// withMigrationLock makes replicas starting together take turns, so that each
+// migration runs once. Postgres advisory locks belong to a session, so the
+// lock and the migrations share a single connection. SQLite has a single
+// writer anyway and runs without it.
+func withMigrationLock(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	if db.Dialector.Name() != postgresDialect {
+		return fn(db)
+	}
+
+	return db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockKey).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockKey)
+
+		return fn(conn)
+	})
+}
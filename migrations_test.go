@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"ciderbot/types"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// schemaModels are every model stored in the database, the migrations have to
+// create what each of them needs.
+var schemaModels = []interface{}{
+	&types.User{},
+	&types.Metrics{},
+	&types.ScheduledJob{},
+	&types.CommandJob{},
+	&types.SlackResponseURL{},
+	&types.SlackDeliveryFailure{},
+	&types.PostedReview{},
+	&types.ReleaseRequest{},
+	&types.ReviewRating{},
+	&types.RatingSnapshot{},
+	&types.SalesReportDay{},
+	&types.SalesReportRow{},
+	&types.AuditEvent{},
+}
+
+func assertSchemaMatchesModels(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	for _, model := range schemaModels {
+		statement := &gorm.Statement{DB: db}
+		if err := statement.Parse(model); err != nil {
+			t.Fatalf("could not parse %T: %s", model, err)
+		}
+
+		if !db.Migrator().HasTable(model) {
+			t.Errorf("there is no table %s", statement.Schema.Table)
+			continue
+		}
+
+		for _, field := range statement.Schema.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("%s has no column %s", statement.Schema.Table, field.DBName)
+			}
+		}
+
+		for name := range statement.Schema.ParseIndexes() {
+			if !db.Migrator().HasIndex(model, name) {
+				t.Errorf("%s has no index %s", statement.Schema.Table, name)
+			}
+		}
+	}
+}
+
+// assertColumnTypesRoundTrip stores a user, whose columns cover every type the
+// migrations spell per database, and reads it back.
+func assertColumnTypesRoundTrip(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	user := types.User{
+		Email:             "owner@example.com",
+		AppStoreConnected: true,
+		AppStoreP8File:    []byte{0x00, 0xff, 0x10},
+		CommandCount:      3,
+		Platform:          androidPlatform,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("could not store a user: %s", err)
+	}
+
+	var stored types.User
+	if err := db.First(&stored, "email = ?", user.Email).Error; err != nil {
+		t.Fatalf("could not read the user back: %s", err)
+	}
+
+	if !stored.AppStoreConnected || !bytes.Equal(stored.AppStoreP8File, user.AppStoreP8File) || stored.CommandCount != 3 || stored.Platform != androidPlatform {
+		t.Errorf("the user was stored as %+v", stored)
+	}
+	if stored.CreatedAt.IsZero() || time.Since(stored.CreatedAt) > time.Minute {
+		t.Errorf("the user was created at %s", stored.CreatedAt)
+	}
+}
+
+func TestMigrationsMatchTheModels(t *testing.T) {
+	db := testDatabase(t)
+
+	assertSchemaMatchesModels(t, db)
+	assertColumnTypesRoundTrip(t, db)
+}
+
+func TestMigrationsRollBack(t *testing.T) {
+	db := testDatabase(t)
+
+	if err := migrateDown(db, len(migrations)); err != nil {
+		t.Fatalf("could not roll back: %s", err)
+	}
+	for _, model := range schemaModels {
+		if db.Migrator().HasTable(model) {
+			t.Errorf("%T is still there after rolling back", model)
+		}
+	}
+
+	if err := migrateUp(db); err != nil {
+		t.Fatalf("could not migrate again: %s", err)
+	}
+	assertSchemaMatchesModels(t, db)
+}
+
+// TestPostgresMigrations runs against the database of TEST_DATABASE_URL, which
+// it empties at the end.
+func TestPostgresMigrations(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("set TEST_DATABASE_URL to a postgres:// url to run the migrations against Postgres")
+	}
+
+	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to Postgres: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := migrateDown(db, len(migrations)); err != nil {
+			t.Errorf("could not roll back: %s", err)
+		}
+	})
+
+	if err := migrateUp(db); err != nil {
+		t.Fatalf("could not migrate: %s", err)
+	}
+	assertSchemaMatchesModels(t, db)
+	assertColumnTypesRoundTrip(t, db)
+
+	if err := migrateDown(db, len(migrations)); err != nil {
+		t.Fatalf("could not roll back: %s", err)
+	}
+	if err := migrateUp(db); err != nil {
+		t.Fatalf("could not migrate again: %s", err)
+	}
+	assertSchemaMatchesModels(t, db)
+}
+
+// baselineUser is the user as the tables were created before versioned
+// migrations, by AutoMigrate.
+type baselineUser struct {
+	Email             string `gorm:"primary_key"`
+	ProviderID        string `gorm:"index:idx_name,unique"`
+	Provider          string
+	Name              sql.NullString
+	AvatarURL         sql.NullString
+	SlackAccessToken  sql.NullString
+	SlackRefreshToken sql.NullString
+	SlackTeamID       sql.NullString
+	SlackTeamName     sql.NullString
+	AppStoreBundleID  sql.NullString
+	AppStoreIssuerID  sql.NullString
+	AppStoreKeyID     sql.NullString
+	AppStoreConnected bool `gorm:"default:false"`
+	AppStoreP8File    []byte
+	AppStoreP8FileIV  []byte
+	CommandCount      int64
+	CreatedAt         time.Time `gorm:"autoCreateTime"`
+	UpdatedAt         time.Time `gorm:"autoUpdateTime"`
+}
+
+func (baselineUser) TableName() string {
+	return "users"
+}
+
+type baselineMetrics struct {
+	ID           int8 `gorm:"primary_key"`
+	DeletedUsers int64
+}
+
+func (baselineMetrics) TableName() string {
+	return "metrics"
+}
+
+func TestMigrationsUpgradeTheBaselineSchema(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "ciderbot.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open the database: %s", err)
+	}
+	if err := db.AutoMigrate(&baselineUser{}, &baselineMetrics{}); err != nil {
+		t.Fatalf("could not create the baseline schema: %s", err)
+	}
+	if err := db.Create(&baselineUser{Email: "early@example.com", ProviderID: "google-0"}).Error; err != nil {
+		t.Fatalf("could not store a baseline user: %s", err)
+	}
+
+	if err := migrateUp(db); err != nil {
+		t.Fatalf("could not migrate: %s", err)
+	}
+	assertSchemaMatchesModels(t, db)
+
+	var existing types.User
+	if err := db.First(&existing, "email = ?", "early@example.com").Error; err != nil {
+		t.Fatalf("could not read the baseline user: %s", err)
+	}
+	if existing.Platform != iosPlatform || existing.PlayConnected {
+		t.Errorf("the baseline user is on %q, play connected %t", existing.Platform, existing.PlayConnected)
+	}
+	assertColumnTypesRoundTrip(t, db)
+}
+
+func TestMigrationsDropTheUniqueScheduledJobIndex(t *testing.T) {
+	db := testDatabase(t)
+
+	// Back to before version 5, which drops the index
+	if err := migrateDown(db, len(migrations)-4); err != nil {
+		t.Fatalf("could not roll back: %s", err)
+	}
+	if err := db.Exec("CREATE UNIQUE INDEX idx_scheduled_job ON scheduled_jobs (kind, slack_team_id, slack_channel_id)").Error; err != nil {
+		t.Fatalf("could not create the old index: %s", err)
+	}
+	if err := migrateUp(db); err != nil {
+		t.Fatalf("could not migrate: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		job := types.ScheduledJob{Kind: "guardrail", SlackTeamID: "T0001", SlackChannelID: "C0001"}
+		if err := db.Create(&job).Error; err != nil {
+			t.Fatalf("could not add guardrail %d: %s", i+1, err)
+		}
+	}
+}
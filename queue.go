@@ -0,0 +1,501 @@
+package main
+
+import (
+	slack "ciderbot/slack"
+	"ciderbot/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	commandWorkers          = 8
+	commandsPerWorkspace    = 2
+	maxCommandAttempts      = 3
+	commandRetryDelay       = 10 * time.Second
+	commandLease            = 5 * time.Minute
+	commandHeartbeat        = time.Minute
+	commandPollInterval     = 2 * time.Second
+	commandCleanupInterval  = time.Hour
+	commandRetention        = 24 * time.Hour
+	responseURLValidity     = 30 * time.Minute
+	responseURLSafetyMargin = 2 * time.Minute
+)
+
+const (
+	commandQueued    = "queued"
+	commandRunning   = "running"
+	commandDelivered = "delivered"
+	commandFailed    = "failed"
+)
+
+// errCommandClaimedElsewhere is returned when the command was claimed again
+// by another worker, after our lease ran out.
+var errCommandClaimedElsewhere = errors.New("queue: the command was claimed by another worker")
+
+// commandQueueWake nudges an idle worker as soon as a command is queued,
+// instead of waiting for the next poll.
+var commandQueueWake = make(chan struct{}, 1)
+
+// newCommandJob is the command as it is persisted, so that it survives a
+// restart and is picked up by the workers of any running instance. The request
+// id and the trace go along, for the run to be tied to the Slack request.
+func newCommandJob(ctx context.Context, command string, form types.SlackFormData) (types.CommandJob, error) {
+	// The verification token is checked already and need not be stored
+	form.Token = ""
+	encodedForm, err := json.Marshal(form)
+	if err != nil {
+		return types.CommandJob{}, err
+	}
+
+	now := time.Now().UTC()
+	return types.CommandJob{
+		SlackTeamID:   form.TeamId,
+		Command:       command,
+		Form:          string(encodedForm),
+		ResponseURL:   form.ResponseUrl,
+		RequestID:     requestIDFrom(ctx),
+		TraceParent:   traceParent(ctx),
+		Status:        commandQueued,
+		NextAttemptAt: now,
+		ExpiresAt:     now.Add(responseURLValidity),
+	}, nil
+}
+
+// wakeCommandWorker nudges an idle worker after a command is queued.
+func wakeCommandWorker() {
+	select {
+	case commandQueueWake <- struct{}{}:
+	default:
+	}
+}
+
+func initCommandQueue(repos Repositories, stores StoreFactory) {
+	for i := 0; i < commandWorkers; i++ {
+		go runCommandWorker(repos, stores)
+	}
+
+	go func() {
+		ticker := time.NewTicker(commandCleanupInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			now := time.Now().UTC()
+			if err := repos.Commands.Cleanup(now); err != nil {
+				slog.Error("queue: could not clean up the commands", "error", err)
+			}
+			if err := repos.Deliveries.CleanupResponseURLs(now.Add(-responseURLRetention)); err != nil {
+				slog.Error("queue: could not clean up the response urls", "error", err)
+			}
+		}
+	}()
+}
+
+// runCommandWorker runs commands until shutdown, the commands still queued by
+// then are left to the next instance.
+func runCommandWorker(repos Repositories, stores StoreFactory) {
+	for !isShuttingDown() {
+		job, err := repos.Commands.ClaimNext(time.Now().UTC())
+		if err != nil {
+			slog.Error("queue: could not fetch due commands", "error", err)
+		}
+		if job == nil {
+			select {
+			case <-commandQueueWake:
+			case <-time.After(commandPollInterval):
+			case <-shuttingDown:
+			}
+			continue
+		}
+
+		ctx := withTraceParent(withRequestID(context.Background(), job.RequestID), job.TraceParent)
+		done, ok := inflight.begin(ctx, fmt.Sprintf("the `%s` command", job.Command), commandDelivery(job), func() bool {
+			return interruptCommand(repos, job)
+		})
+		if !ok {
+			// Claimed as the shutdown began, left to the next instance
+			if err := repos.Commands.Retry(job, time.Now().UTC(), ""); err != nil {
+				slog.Error("queue: could not hand the command back", "command", job.Command, "job_id", job.ID, "error", err)
+			}
+			return
+		}
+		stopHeartbeat := keepCommandLease(ctx, repos.Commands, job)
+		runCommandJob(ctx, repos, stores, job)
+		stopHeartbeat()
+		done()
+	}
+}
+
+// keepCommandLease renews the lease of the command while it runs, so that a
+// command running longer than the lease is not picked up by another instance.
+// It gives up once the command is no longer ours. The returned func stops it.
+func keepCommandLease(ctx context.Context, commands CommandRepository, job *types.CommandJob) func() {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(commandHeartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				renewed, err := commands.Renew(job, time.Now().UTC().Add(commandLease))
+				if err != nil {
+					slog.ErrorContext(ctx, "queue: could not renew the lease", "command", job.Command, "job_id", job.ID, "error", err)
+					continue
+				}
+				if !renewed {
+					slog.WarnContext(ctx, "queue: lost the lease", "command", job.Command, "job_id", job.ID)
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// runCommandJob runs the command once and delivers its response. A command is
+// run again if the store could not be reached, and a response that could not
+// be delivered is retried as is. Once out of attempts, or out of time to use
+// the response url, the user is told that the command failed.
+func runCommandJob(ctx context.Context, repos Repositories, stores StoreFactory, job *types.CommandJob) {
+	ctx, span := tracer.Start(ctx, "command "+job.Command, commandAttributes(job.Command, job.ID, job.Attempts))
+	defer func() {
+		span.SetAttributes(attribute.String("ciderbot.status", job.Status))
+		span.End()
+	}()
+	ctx, followUps := withFollowUps(ctx)
+
+	if job.Response == "" {
+		startedAt := time.Now()
+		response, err := executeCommandJob(ctx, repos, stores, job)
+		commandDuration.WithLabelValues(job.Command).Observe(time.Since(startedAt).Seconds())
+		if err != nil {
+			slog.WarnContext(ctx, "queue: command failed", "command", job.Command, "job_id", job.ID, "attempt", job.Attempts, "error", err)
+			retryOrFailCommand(ctx, repos, job, err)
+			return
+		}
+
+		encodedResponse, err := json.Marshal(response)
+		if err != nil {
+			failCommand(ctx, repos, job, err)
+			return
+		}
+
+		job.Response = string(encodedResponse)
+		if err := repos.Commands.SaveResponse(job); err != nil {
+			slog.ErrorContext(ctx, "queue: could not save the response", "command", job.Command, "job_id", job.ID, "error", err)
+		}
+	}
+
+	var response types.SlackResponse
+	if err := json.Unmarshal([]byte(job.Response), &response); err != nil {
+		failCommand(ctx, repos, job, err)
+		return
+	}
+
+	if err := deliverSlackResponse(ctx, repos, commandDelivery(job), response); err != nil {
+		retryOrFailCommand(ctx, repos, job, err)
+		return
+	}
+
+	finishCommand(ctx, repos, job, commandDelivered, "")
+	followUps.run(ctx)
+}
+
+type followUpsKey struct{}
+
+// commandFollowUps are run once the response of the command is delivered, so
+// that what they post comes below it. They are lost when the response is
+// only delivered by a later attempt.
+type commandFollowUps struct {
+	mu    sync.Mutex
+	funcs []func(context.Context)
+}
+
+func withFollowUps(ctx context.Context) (context.Context, *commandFollowUps) {
+	followUps := &commandFollowUps{}
+	return context.WithValue(ctx, followUpsKey{}, followUps), followUps
+}
+
+// afterResponse runs fn once the response of the command is delivered, or
+// right away outside of a command.
+func afterResponse(ctx context.Context, fn func(context.Context)) {
+	followUps, ok := ctx.Value(followUpsKey{}).(*commandFollowUps)
+	if !ok {
+		fn(ctx)
+		return
+	}
+
+	followUps.mu.Lock()
+	followUps.funcs = append(followUps.funcs, fn)
+	followUps.mu.Unlock()
+}
+
+func (followUps *commandFollowUps) run(ctx context.Context) {
+	followUps.mu.Lock()
+	funcs := followUps.funcs
+	followUps.funcs = nil
+	followUps.mu.Unlock()
+
+	for _, fn := range funcs {
+		fn(ctx)
+	}
+}
+
+func executeCommandJob(ctx context.Context, repos Repositories, stores StoreFactory, job *types.CommandJob) (response types.SlackResponse, err error) {
+	ctx, span := tracer.Start(ctx, "command.execute")
+	defer func() { endSpan(span, err) }()
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			slog.ErrorContext(ctx, "queue: command panicked", "command", job.Command, "job_id", job.ID, "panic", recovered, "stack", string(debug.Stack()))
+			err = fmt.Errorf("command panicked: %v", recovered)
+		}
+	}()
+
+	var form types.SlackFormData
+	if err := json.Unmarshal([]byte(job.Form), &form); err != nil {
+		return response, err
+	}
+
+	user, err := repos.Users.FindByTeamID(job.SlackTeamID)
+	if err != nil {
+		return response, err
+	}
+
+	store := &transientFailureStore{StoreClient: stores(user)}
+	response = processValidSlackCommand(ctx, job.Command, form, user, repos, store)
+
+	// The handler turned the failure into a message, try again while we can
+	if store.err != nil && job.Attempts < maxCommandAttempts {
+		return response, store.err
+	}
+
+	return response, nil
+}
+
+func retryOrFailCommand(ctx context.Context, repos Repositories, job *types.CommandJob, err error) {
+	trace.SpanFromContext(ctx).RecordError(err)
+
+	nextAttemptAt := time.Now().UTC().Add(commandRetryDelay * time.Duration(job.Attempts))
+
+	// Slack asked us to wait longer than a worker should, the queue waits instead
+	var statusErr slackStatusError
+	if errors.As(err, &statusErr) && statusErr.retryAfter > 0 {
+		if retryAt := time.Now().UTC().Add(statusErr.retryAfter); retryAt.After(nextAttemptAt) {
+			nextAttemptAt = retryAt
+		}
+	}
+
+	if job.Attempts >= maxCommandAttempts || nextAttemptAt.After(job.ExpiresAt.Add(-responseURLSafetyMargin)) {
+		failCommand(ctx, repos, job, err)
+		return
+	}
+
+	observeCommand(job.Command, "retried")
+	if err := repos.Commands.Retry(job, nextAttemptAt, err.Error()); err != nil {
+		slog.ErrorContext(ctx, "queue: could not queue the command again", "command", job.Command, "job_id", job.ID, "error", err)
+	}
+}
+
+func failCommand(ctx context.Context, repos Repositories, job *types.CommandJob, err error) {
+	failure := slack.EphemeralMessage{Msg: fmt.Sprintf("Sorry, the `%s` command could not be completed. Please try again in a bit.", job.Command)}.Render()
+	if deliveryErr := deliverSlackResponse(ctx, repos, commandDelivery(job), failure); deliveryErr != nil {
+		slog.ErrorContext(ctx, "queue: could not tell about the failed command", "command", job.Command, "job_id", job.ID, "error", deliveryErr)
+	}
+
+	finishCommand(ctx, repos, job, commandFailed, err.Error())
+}
+
+// interruptCommand fails a command still running at shutdown, unless it got
+// done or went back to the queue meanwhile, so that no other instance runs it
+// again once the lease is over.
+func interruptCommand(repos Repositories, job *types.CommandJob) bool {
+	interrupted, err := repos.Commands.Interrupt(job)
+	if err != nil {
+		slog.Error("queue: could not interrupt the command", "command", job.Command, "job_id", job.ID, "error", err)
+	}
+
+	if interrupted {
+		observeCommand(job.Command, commandFailed)
+	}
+
+	return interrupted
+}
+
+func commandDelivery(job *types.CommandJob) slackDelivery {
+	var form types.SlackFormData
+	json.Unmarshal([]byte(job.Form), &form)
+
+	return slackDelivery{
+		TeamID:      job.SlackTeamID,
+		ChannelID:   form.ChannelId,
+		UserID:      form.UserId,
+		ResponseURL: job.ResponseURL,
+		ExpiresAt:   job.ExpiresAt,
+	}
+}
+
+func finishCommand(ctx context.Context, repos Repositories, job *types.CommandJob, status string, lastError string) {
+	observeCommand(job.Command, status)
+	if err := repos.Commands.Finish(job, status, lastError); err != nil {
+		slog.ErrorContext(ctx, "queue: could not finish the command", "command", job.Command, "job_id", job.ID, "error", err)
+	}
+}
+
+// transientFailureStore remembers the last store request that failed in a way
+// worth retrying, since handlers only report failures as messages.
+type transientFailureStore struct {
+	StoreClient
+	err error
+}
+
+func (store *transientFailureStore) record(err error) {
+	if isTransientError(err) {
+		store.err = err
+	}
+}
+
+func (store *transientFailureStore) AppMetadata(ctx context.Context) (types.AppMetadata, error) {
+	appMetadata, err := store.StoreClient.AppMetadata(ctx)
+	store.record(err)
+	return appMetadata, err
+}
+
+func (store *transientFailureStore) CurrentStatus(ctx context.Context) ([]types.AppCurrentStatus, error) {
+	statuses, err := store.StoreClient.CurrentStatus(ctx)
+	store.record(err)
+	return statuses, err
+}
+
+func (store *transientFailureStore) BetaGroups(ctx context.Context) ([]types.BetaGroup, error) {
+	betaGroups, err := store.StoreClient.BetaGroups(ctx)
+	store.record(err)
+	return betaGroups, err
+}
+
+func (store *transientFailureStore) InflightRelease(ctx context.Context) (types.Release, error) {
+	release, err := store.StoreClient.InflightRelease(ctx)
+	store.record(err)
+	return release, err
+}
+
+func (store *transientFailureStore) LiveRelease(ctx context.Context) (types.Release, error) {
+	release, err := store.StoreClient.LiveRelease(ctx)
+	store.record(err)
+	return release, err
+}
+
+func (store *transientFailureStore) PauseRollout(ctx context.Context) (types.Release, error) {
+	release, err := store.StoreClient.PauseRollout(ctx)
+	store.record(err)
+	return release, err
+}
+
+func (store *transientFailureStore) ResumeRollout(ctx context.Context) (types.Release, error) {
+	release, err := store.StoreClient.ResumeRollout(ctx)
+	store.record(err)
+	return release, err
+}
+
+func (store *transientFailureStore) CompleteRollout(ctx context.Context) (types.Release, error) {
+	release, err := store.StoreClient.CompleteRollout(ctx)
+	store.record(err)
+	return release, err
+}
+
+func (store *transientFailureStore) Builds(ctx context.Context, versionString string, limit int) ([]types.Build, error) {
+	builds, err := store.StoreClient.Builds(ctx, versionString, limit)
+	store.record(err)
+	return builds, err
+}
+
+func (store *transientFailureStore) Build(ctx context.Context, buildNumber string) (types.Build, error) {
+	build, err := store.StoreClient.Build(ctx, buildNumber)
+	store.record(err)
+	return build, err
+}
+
+func (store *transientFailureStore) AttachBuild(ctx context.Context, buildID string) (types.Release, error) {
+	release, err := store.StoreClient.AttachBuild(ctx, buildID)
+	store.record(err)
+	return release, err
+}
+
+func (store *transientFailureStore) CreateVersion(ctx context.Context, versionString string, releaseType string, earliestReleaseDate *time.Time, phasedRelease bool) (types.Release, error) {
+	release, err := store.StoreClient.CreateVersion(ctx, versionString, releaseType, earliestReleaseDate, phasedRelease)
+	store.record(err)
+	return release, err
+}
+
+func (store *transientFailureStore) UpdateInflightRelease(ctx context.Context, changes map[string]interface{}) (types.Release, error) {
+	release, err := store.StoreClient.UpdateInflightRelease(ctx, changes)
+	store.record(err)
+	return release, err
+}
+
+func (store *transientFailureStore) ReleaseInflight(ctx context.Context) (types.Release, error) {
+	release, err := store.StoreClient.ReleaseInflight(ctx)
+	store.record(err)
+	return release, err
+}
+
+func (store *transientFailureStore) InflightLocalizations(ctx context.Context) ([]types.Localization, error) {
+	localizations, err := store.StoreClient.InflightLocalizations(ctx)
+	store.record(err)
+	return localizations, err
+}
+
+func (store *transientFailureStore) LiveLocalizations(ctx context.Context) ([]types.Localization, error) {
+	localizations, err := store.StoreClient.LiveLocalizations(ctx)
+	store.record(err)
+	return localizations, err
+}
+
+func (store *transientFailureStore) ReviewSubmission(ctx context.Context) (types.ReviewSubmission, error) {
+	submission, err := store.StoreClient.ReviewSubmission(ctx)
+	store.record(err)
+	return submission, err
+}
+
+func (store *transientFailureStore) ResolutionCenterMessages(ctx context.Context) ([]types.ResolutionCenterMessage, error) {
+	messages, err := store.StoreClient.ResolutionCenterMessages(ctx)
+	store.record(err)
+	return messages, err
+}
+
+func (store *transientFailureStore) CustomerReviews(ctx context.Context, territory string, limit int) ([]types.CustomerReview, error) {
+	customerReviews, err := store.StoreClient.CustomerReviews(ctx, territory, limit)
+	store.record(err)
+	return customerReviews, err
+}
+
+func (store *transientFailureStore) ReplyToReview(ctx context.Context, reviewID string, responseBody string) (types.CustomerReviewResponse, error) {
+	reviewResponse, err := store.StoreClient.ReplyToReview(ctx, reviewID, responseBody)
+	store.record(err)
+	return reviewResponse, err
+}
+
+func (store *transientFailureStore) SalesReport(ctx context.Context, vendorNumber string, reportDate time.Time) ([]byte, error) {
+	report, err := store.StoreClient.SalesReport(ctx, vendorNumber, reportDate)
+	store.record(err)
+	return report, err
+}
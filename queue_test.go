@@ -0,0 +1,188 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func enqueueTestCommand(t *testing.T, repos Repositories, teamID string) {
+	t.Helper()
+
+	form := testForm("app_info")
+	form.TeamId = teamID
+	if err := repos.Commands.Enqueue(context.Background(), "app_info", form); err != nil {
+		t.Fatalf("could not queue the command: %s", err)
+	}
+}
+
+func TestClaimNextCommandKeepsTheShareOfAWorkspace(t *testing.T) {
+	repos := newGormRepositories(testDatabase(t))
+	for i := 0; i < commandsPerWorkspace+1; i++ {
+		enqueueTestCommand(t, repos, "T0001")
+	}
+	enqueueTestCommand(t, repos, "T0002")
+
+	now := time.Now().UTC()
+	claimed := map[string]int{}
+	for {
+		job, err := repos.Commands.ClaimNext(now)
+		if err != nil {
+			t.Fatalf("could not claim a command: %s", err)
+		}
+		if job == nil {
+			break
+		}
+		if job.Status != commandRunning || job.Attempts != 1 || job.LockVersion != 1 {
+			t.Errorf("the claimed command is %+v", job)
+		}
+		claimed[job.SlackTeamID] += 1
+	}
+
+	if claimed["T0001"] != commandsPerWorkspace || claimed["T0002"] != 1 {
+		t.Errorf("claimed %v, want %d commands of T0001 and 1 of T0002", claimed, commandsPerWorkspace)
+	}
+
+	// Once a lease is over, the command can be claimed again
+	job, err := repos.Commands.ClaimNext(now.Add(commandLease + time.Second))
+	if err != nil || job == nil || job.Attempts != 2 {
+		t.Errorf("the command of an expired lease was not claimed again: %+v (%v)", job, err)
+	}
+}
+
+func TestRenewCommandLeaseNeedsTheLease(t *testing.T) {
+	repos := newGormRepositories(testDatabase(t))
+	enqueueTestCommand(t, repos, "T0001")
+
+	now := time.Now().UTC()
+	job, err := repos.Commands.ClaimNext(now)
+	if err != nil || job == nil {
+		t.Fatalf("could not claim the command: %v", err)
+	}
+
+	renewed, err := repos.Commands.Renew(job, now.Add(2*commandLease))
+	if err != nil || !renewed {
+		t.Fatalf("the lease was not renewed (%v)", err)
+	}
+	if next, _ := repos.Commands.ClaimNext(now.Add(commandLease + time.Second)); next != nil {
+		t.Errorf("a command with a renewed lease was claimed again")
+	}
+
+	// Another instance took the command over once the lease was over
+	takenOver, err := repos.Commands.ClaimNext(now.Add(2*commandLease + time.Second))
+	if err != nil || takenOver == nil {
+		t.Fatalf("the command was not taken over (%v)", err)
+	}
+	if renewed, _ := repos.Commands.Renew(job, now.Add(3*commandLease)); renewed {
+		t.Errorf("the lease was renewed by the worker that lost it")
+	}
+}
+
+func TestCommandIsOnlyFinishedByItsWorker(t *testing.T) {
+	for name, repos := range map[string]Repositories{
+		"gorm":   newGormRepositories(testDatabase(t)),
+		"memory": newMemoryRepositories(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			enqueueTestCommand(t, repos, "T0001")
+
+			now := time.Now().UTC()
+			lost, err := repos.Commands.ClaimNext(now)
+			if err != nil || lost == nil {
+				t.Fatalf("could not claim the command: %v", err)
+			}
+			takenOver, err := repos.Commands.ClaimNext(now.Add(commandLease + time.Second))
+			if err != nil || takenOver == nil {
+				t.Fatalf("the command was not taken over (%v)", err)
+			}
+
+			if err := repos.Commands.Finish(lost, commandDelivered, ""); !errors.Is(err, errCommandClaimedElsewhere) {
+				t.Errorf("the worker that lost the command finished it (%v)", err)
+			}
+			if err := repos.Commands.Retry(lost, now, "lost"); !errors.Is(err, errCommandClaimedElsewhere) {
+				t.Errorf("the worker that lost the command queued it again (%v)", err)
+			}
+			if err := repos.Commands.Finish(takenOver, commandDelivered, ""); err != nil {
+				t.Errorf("the worker that took the command over could not finish it: %s", err)
+			}
+		})
+	}
+}
+
+// Every store call goes through the wrapper, so that a failing call of any
+// command is retried.
+func TestTransientFailureStoreRecordsEveryMethod(t *testing.T) {
+	storeClient := reflect.TypeOf((*StoreClient)(nil)).Elem()
+	contextType := reflect.TypeOf((*context.Context)(nil)).Elem()
+
+	for i := 0; i < storeClient.NumMethod(); i++ {
+		method := storeClient.Method(i)
+		if method.Type.NumIn() == 0 || method.Type.In(0) != contextType {
+			continue
+		}
+
+		memory := newMemoryStore()
+		memory.err = statusError{service: "memory", statusCode: http.StatusServiceUnavailable}
+		store := &transientFailureStore{StoreClient: memory}
+
+		args := []reflect.Value{reflect.ValueOf(context.Background())}
+		for arg := 1; arg < method.Type.NumIn(); arg++ {
+			args = append(args, reflect.Zero(method.Type.In(arg)))
+		}
+		reflect.ValueOf(store).MethodByName(method.Name).Call(args)
+
+		if store.err == nil {
+			t.Errorf("a failing %s was not recorded", method.Name)
+		}
+	}
+}
+
+func TestNoWorkBeginsOnceShuttingDown(t *testing.T) {
+	stopped := make(chan struct{})
+	close(stopped)
+	running := shuttingDown
+	shuttingDown = stopped
+	t.Cleanup(func() { shuttingDown = running })
+
+	work := &inflightWork{entries: map[int]inflightEntry{}}
+	if done, ok := work.begin(context.Background(), "the `app_info` command", slackDelivery{}, nil); ok || done != nil {
+		t.Fatalf("work began while shutting down")
+	}
+}
+
+func TestPhasedReleaseChartNoticeFollowsTheResponse(t *testing.T) {
+	slackAPI := fakeSlackAPI(t)
+	repos := newMemoryRepositories()
+	connectTestWorkspace(t, repos)
+	stores := func(user *types.User) StoreClient { return newMemoryStore() }
+
+	if err := repos.Commands.Enqueue(context.Background(), "live_release", testForm("")); err != nil {
+		t.Fatalf("could not queue the command: %s", err)
+	}
+	job, err := repos.Commands.ClaimNext(time.Now().UTC())
+	if err != nil || job == nil {
+		t.Fatalf("could not claim the command: %v", err)
+	}
+
+	// The workspace was installed without files:write, only the user who asked is told
+	runCommandJob(context.Background(), repos, stores, job)
+
+	var methods []string
+	for _, call := range slackAPI.calls {
+		if strings.Contains(call.Body, "reinstall") && call.Method != "chat.postEphemeral" {
+			t.Errorf("the notice was posted with %s", call.Method)
+		}
+		methods = append(methods, call.Method)
+	}
+	if !reflect.DeepEqual(methods, []string{"chat.postMessage", "chat.postEphemeral"}) {
+		t.Fatalf("made %v, want the release and then the notice", methods)
+	}
+	if notice := slackAPI.calls[1].Body; !strings.Contains(notice, "reinstall") || !strings.Contains(notice, `"user":"U0001"`) {
+		t.Errorf("the notice was %s", notice)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	slack "ciderbot/slack"
+	"ciderbot/types"
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+const (
+	ratingsSnapshotSchedule = "0 3 * * *"
+	ratingsFetchLimit       = 200
+	ratingsTerritoryLimit   = 15
+	ratingsHistoryWeeks     = 4
+	overallTerritory        = "ALL"
+)
+
+type ratingAggregate struct {
+	Territory     string
+	ReviewCount   int64
+	AverageRating float64
+}
+
+func handleRatingsCommand(ctx context.Context, form types.SlackFormData, repos Repositories, store StoreClient) types.SlackResponse {
+	err := ensureRatingsSnapshotJob(repos.Jobs, form.TeamId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ratings: could not schedule snapshots", "error", err)
+	}
+
+	today := truncateToDay(time.Now())
+	_, capturedOn, err := ratingSnapshotsAt(repos.Ratings, form.TeamId, time.Now().UTC())
+	if err != nil || capturedOn.Before(today) {
+		if err := takeRatingsSnapshot(ctx, repos.Ratings, form.TeamId, store); err != nil {
+			return slack.EphemeralMessage{Msg: "Could not find ratings for your app."}.Render()
+		}
+	}
+
+	ratings, err := compileRatings(ctx, repos.Ratings, form.TeamId, store)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not compile the ratings for your app."}.Render()
+	}
+
+	return ratings.Render()
+}
+
+func ensureRatingsSnapshotJob(jobs ScheduledJobRepository, teamID string) error {
+	job, err := jobs.Find("ratings_snapshot", teamID, "")
+	if err != nil || job != nil {
+		return err
+	}
+
+	return createScheduledJob(jobs, &types.ScheduledJob{
+		Kind:        "ratings_snapshot",
+		SlackTeamID: teamID,
+		Schedule:    ratingsSnapshotSchedule,
+		Timezone:    defaultDigestTimezone,
+	})
+}
+
+func runRatingsSnapshotJob(ctx context.Context, job *types.ScheduledJob, repos Repositories, stores StoreFactory) error {
+	user, err := repos.Users.FindByTeamID(job.SlackTeamID)
+	if err != nil {
+		return err
+	}
+
+	if !user.AppStoreBundleID.Valid {
+		return fmt.Errorf("ratings: workspace %s has no app", job.SlackTeamID)
+	}
+
+	return takeRatingsSnapshot(ctx, repos.Ratings, job.SlackTeamID, stores.appStore(user))
+}
+
+// takeRatingsSnapshot adds the recent reviews to the ones seen before and
+// records today's count and average rating of all of them per territory.
+func takeRatingsSnapshot(ctx context.Context, ratingRepo RatingRepository, teamID string, store StoreClient) error {
+	customerReviews, err := store.CustomerReviews(ctx, "", ratingsFetchLimit)
+	if err != nil {
+		return err
+	}
+
+	var ratings []types.ReviewRating
+	for _, review := range customerReviews {
+		ratings = append(ratings, types.ReviewRating{
+			SlackTeamID: teamID,
+			ReviewID:    review.Id,
+			Territory:   review.Territory,
+			Rating:      review.Rating,
+			CreatedDate: review.CreatedDate.UTC(),
+		})
+	}
+
+	if err := ratingRepo.AddRatings(ratings); err != nil {
+		return err
+	}
+
+	aggregates, err := ratingRepo.CountByTerritory(teamID)
+	if err != nil {
+		return err
+	}
+
+	capturedOn := truncateToDay(time.Now())
+	snapshots := []types.RatingSnapshot{toRatingSnapshot(teamID, capturedOn, overallAggregate(aggregates))}
+	for _, aggregate := range aggregates {
+		snapshots = append(snapshots, toRatingSnapshot(teamID, capturedOn, aggregate))
+	}
+
+	return ratingRepo.SaveSnapshots(snapshots)
+}
+
+func compileRatings(ctx context.Context, ratingRepo RatingRepository, teamID string, store StoreClient) (slack.Ratings, error) {
+	ratings := slack.Ratings{}
+
+	current, capturedOn, err := ratingSnapshotsAt(ratingRepo, teamID, time.Now().UTC())
+	if err != nil {
+		return ratings, err
+	}
+	ratings.CapturedOn = capturedOn
+
+	previous, _, err := ratingSnapshotsAt(ratingRepo, teamID, capturedOn.AddDate(0, 0, -7))
+	if err != nil {
+		return ratings, err
+	}
+
+	var territories []types.RatingSnapshot
+	for territory, snapshot := range current {
+		if territory == overallTerritory {
+			ratings.Overall = toRatingRow(snapshot, previous)
+			continue
+		}
+		territories = append(territories, snapshot)
+	}
+
+	sort.Slice(territories, func(i, j int) bool {
+		return territories[i].ReviewCount > territories[j].ReviewCount
+	})
+	if len(territories) > ratingsTerritoryLimit {
+		territories = territories[:ratingsTerritoryLimit]
+	}
+
+	for _, snapshot := range territories {
+		ratings.Territories = append(ratings.Territories, toRatingRow(snapshot, previous))
+	}
+
+	for week := 1; week < ratingsHistoryWeeks; week++ {
+		snapshots, weekCapturedOn, err := ratingSnapshotsAt(ratingRepo, teamID, capturedOn.AddDate(0, 0, -7*week))
+		if err != nil || len(snapshots) == 0 {
+			break
+		}
+
+		ratings.History = append(ratings.History, slack.RatingPoint{
+			Date:          weekCapturedOn,
+			AverageRating: snapshots[overallTerritory].AverageRating,
+			ReviewCount:   snapshots[overallTerritory].ReviewCount,
+		})
+	}
+
+	liveRelease, err := store.LiveRelease(ctx)
+	if err == nil {
+		liveSince := liveRelease.PhasedRelease.StartDate
+		if liveSince.IsZero() {
+			liveSince = liveRelease.CreatedDate
+		}
+
+		// Dates are compared as text by SQLite, so they all need to be in UTC
+		liveSince = liveSince.UTC()
+		ratings.LiveVersion = liveRelease.VersionName
+		ratings.LiveSince = liveSince
+		ratings.Before = ratingsBetween(ratingRepo, teamID, time.Time{}, liveSince)
+		ratings.Since = ratingsBetween(ratingRepo, teamID, liveSince, time.Now().UTC())
+	}
+
+	return ratings, nil
+}
+
+// ratingSnapshotsAt finds the latest snapshot taken on or before the given time, by territory.
+func ratingSnapshotsAt(ratingRepo RatingRepository, teamID string, at time.Time) (map[string]types.RatingSnapshot, time.Time, error) {
+	snapshots := map[string]types.RatingSnapshot{}
+
+	rows, err := ratingRepo.SnapshotsAt(teamID, at)
+	if err != nil || len(rows) == 0 {
+		return snapshots, time.Time{}, err
+	}
+
+	for _, row := range rows {
+		snapshots[row.Territory] = row
+	}
+
+	return snapshots, rows[0].CapturedOn, nil
+}
+
+func ratingsBetween(ratingRepo RatingRepository, teamID string, from time.Time, to time.Time) slack.RatingPoint {
+	aggregate, _ := ratingRepo.CountBetween(teamID, from, to)
+
+	return slack.RatingPoint{
+		Date:          from,
+		AverageRating: aggregate.AverageRating,
+		ReviewCount:   aggregate.ReviewCount,
+	}
+}
+
+func overallAggregate(aggregates []ratingAggregate) ratingAggregate {
+	overall := ratingAggregate{Territory: overallTerritory}
+
+	var ratingSum float64
+	for _, aggregate := range aggregates {
+		overall.ReviewCount += aggregate.ReviewCount
+		ratingSum += aggregate.AverageRating * float64(aggregate.ReviewCount)
+	}
+
+	if overall.ReviewCount > 0 {
+		overall.AverageRating = ratingSum / float64(overall.ReviewCount)
+	}
+
+	return overall
+}
+
+func toRatingSnapshot(teamID string, capturedOn time.Time, aggregate ratingAggregate) types.RatingSnapshot {
+	return types.RatingSnapshot{
+		SlackTeamID:   teamID,
+		Territory:     aggregate.Territory,
+		CapturedOn:    capturedOn,
+		ReviewCount:   aggregate.ReviewCount,
+		AverageRating: aggregate.AverageRating,
+	}
+}
+
+func toRatingRow(snapshot types.RatingSnapshot, previous map[string]types.RatingSnapshot) slack.RatingRow {
+	row := slack.RatingRow{
+		Territory:     snapshot.Territory,
+		AverageRating: snapshot.AverageRating,
+		ReviewCount:   snapshot.ReviewCount,
+	}
+
+	if previousSnapshot, ok := previous[snapshot.Territory]; ok {
+		row.HasPrevious = true
+		row.AverageDelta = snapshot.AverageRating - previousSnapshot.AverageRating
+		row.CountDelta = snapshot.ReviewCount - previousSnapshot.ReviewCount
+	}
+
+	return row
+}
+
+func truncateToDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
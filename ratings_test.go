@@ -0,0 +1,103 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// ratingRepositories are the repositories the ratings are counted with, by name.
+func ratingRepositories(t *testing.T) map[string]Repositories {
+	t.Helper()
+
+	return map[string]Repositories{
+		"gorm":   newGormRepositories(testDatabase(t)),
+		"memory": newMemoryRepositories(),
+	}
+}
+
+func TestRatingsSnapshotCountsEveryReviewSeen(t *testing.T) {
+	for name, repos := range ratingRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newMemoryStore()
+			if err := takeRatingsSnapshot(context.Background(), repos.Ratings, "T0001", store); err != nil {
+				t.Fatalf("could not take the snapshot: %s", err)
+			}
+
+			// The store only lists the recent reviews, the older ones are still counted
+			store.reviews = []types.CustomerReview{
+				{Id: "review-3", Rating: 4, Territory: "USA", CreatedDate: time.Now().UTC()},
+				store.reviews[1],
+			}
+			if err := takeRatingsSnapshot(context.Background(), repos.Ratings, "T0001", store); err != nil {
+				t.Fatalf("could not take the snapshot again: %s", err)
+			}
+
+			snapshots, capturedOn, err := ratingSnapshotsAt(repos.Ratings, "T0001", time.Now().UTC())
+			if err != nil || !capturedOn.Equal(truncateToDay(time.Now())) {
+				t.Fatalf("the snapshot was captured on %s (%v)", capturedOn, err)
+			}
+
+			want := map[string]ratingAggregate{
+				overallTerritory: {ReviewCount: 3, AverageRating: 10.0 / 3},
+				"USA":            {ReviewCount: 2, AverageRating: 2.5},
+				"GBR":            {ReviewCount: 1, AverageRating: 5},
+			}
+			if len(snapshots) != len(want) {
+				t.Fatalf("got snapshots of %d territories, want %d", len(snapshots), len(want))
+			}
+			for territory, aggregate := range want {
+				snapshot := snapshots[territory]
+				if snapshot.ReviewCount != aggregate.ReviewCount || math.Abs(snapshot.AverageRating-aggregate.AverageRating) > 1e-9 {
+					t.Errorf("%s has %d reviews averaging %f, want %d averaging %f", territory, snapshot.ReviewCount, snapshot.AverageRating, aggregate.ReviewCount, aggregate.AverageRating)
+				}
+			}
+		})
+	}
+}
+
+func TestRatingsCompareToTheSnapshotOfAWeekAgo(t *testing.T) {
+	for name, repos := range ratingRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newMemoryStore()
+			weekAgo := truncateToDay(time.Now()).AddDate(0, 0, -7)
+			err := repos.Ratings.SaveSnapshots([]types.RatingSnapshot{
+				toRatingSnapshot("T0001", weekAgo, ratingAggregate{Territory: overallTerritory, ReviewCount: 1, AverageRating: 1}),
+				toRatingSnapshot("T0001", weekAgo, ratingAggregate{Territory: "USA", ReviewCount: 1, AverageRating: 1}),
+			})
+			if err != nil {
+				t.Fatalf("could not save the snapshot of a week ago: %s", err)
+			}
+
+			if err := takeRatingsSnapshot(context.Background(), repos.Ratings, "T0001", store); err != nil {
+				t.Fatalf("could not take the snapshot: %s", err)
+			}
+			ratings, err := compileRatings(context.Background(), repos.Ratings, "T0001", store)
+			if err != nil {
+				t.Fatalf("could not compile the ratings: %s", err)
+			}
+
+			overall := ratings.Overall
+			if !overall.HasPrevious || overall.CountDelta != 1 || overall.AverageDelta != 2 {
+				t.Errorf("the overall ratings moved by %d reviews and %f stars", overall.CountDelta, overall.AverageDelta)
+			}
+			for _, row := range ratings.Territories {
+				switch row.Territory {
+				case "USA":
+					if !row.HasPrevious || row.CountDelta != 0 || row.AverageDelta != 0 {
+						t.Errorf("USA moved by %d reviews and %f stars", row.CountDelta, row.AverageDelta)
+					}
+				case "GBR":
+					if row.HasPrevious {
+						t.Errorf("GBR has no snapshot of a week ago, yet it is compared to one")
+					}
+				}
+			}
+			if len(ratings.History) != 1 || !ratings.History[0].Date.Equal(weekAgo) || ratings.History[0].ReviewCount != 1 {
+				t.Errorf("the history is %+v", ratings.History)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Common timezone abbreviations mapped to a location, so daylight saving is
+// applied for the date being parsed rather than the abbreviation's fixed offset.
+var timezoneAbbreviations = map[string]string{
+	"PT":   "America/Los_Angeles",
+	"PST":  "America/Los_Angeles",
+	"PDT":  "America/Los_Angeles",
+	"MT":   "America/Denver",
+	"MST":  "America/Denver",
+	"MDT":  "America/Denver",
+	"CT":   "America/Chicago",
+	"CST":  "America/Chicago",
+	"CDT":  "America/Chicago",
+	"ET":   "America/New_York",
+	"EST":  "America/New_York",
+	"EDT":  "America/New_York",
+	"UTC":  "UTC",
+	"GMT":  "UTC",
+	"Z":    "UTC",
+	"BST":  "Europe/London",
+	"CET":  "Europe/Berlin",
+	"CEST": "Europe/Berlin",
+	"IST":  "Asia/Kolkata",
+	"JST":  "Asia/Tokyo",
+	"AEST": "Australia/Sydney",
+	"AEDT": "Australia/Sydney",
+}
+
+var clockPattern = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+
+// parseReleaseDate reads dates like `tomorrow 9am PT`, `friday 17:00 Europe/Berlin`
+// or `2023-06-01 10am`, relative to now. The day defaults to today, the time to
+// midnight and the timezone to UTC.
+func parseReleaseDate(text string, now time.Time) (time.Time, error) {
+	location := time.UTC
+	var day func(time.Time) time.Time
+	hour, minute := 0, 0
+	clockSet := false
+
+	for _, word := range strings.Fields(text) {
+		lowerWord := strings.ToLower(word)
+
+		switch {
+		case lowerWord == "at" || lowerWord == "on":
+			continue
+		case lowerWord == "today":
+			day = func(t time.Time) time.Time { return t }
+		case lowerWord == "tomorrow":
+			day = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+		case lowerWord == "noon" || lowerWord == "midnight":
+			if clockSet {
+				return time.Time{}, fmt.Errorf("the time is given twice")
+			}
+			hour, minute, clockSet = 0, 0, true
+			if lowerWord == "noon" {
+				hour = 12
+			}
+		case clockPattern.MatchString(lowerWord):
+			if clockSet {
+				return time.Time{}, fmt.Errorf("the time is given twice")
+			}
+
+			var err error
+			hour, minute, err = parseClock(lowerWord)
+			if err != nil {
+				return time.Time{}, err
+			}
+			clockSet = true
+		default:
+			if date, err := time.Parse("2006-01-02", word); err == nil {
+				day = func(t time.Time) time.Time {
+					return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, t.Location())
+				}
+				continue
+			}
+
+			if weekday, ok := parseWeekday(lowerWord); ok {
+				day = func(t time.Time) time.Time {
+					days := (int(weekday) - int(t.Weekday()) + 7) % 7
+					if days == 0 {
+						days = 7
+					}
+					return t.AddDate(0, 0, days)
+				}
+				continue
+			}
+
+			timezone := word
+			if name, ok := timezoneAbbreviations[strings.ToUpper(word)]; ok {
+				timezone = name
+			}
+
+			loadedLocation, err := time.LoadLocation(timezone)
+			if err != nil || timezone == "" || timezone == "Local" {
+				return time.Time{}, fmt.Errorf("could not understand `%s`", word)
+			}
+			location = loadedLocation
+		}
+	}
+
+	if day == nil {
+		day = func(t time.Time) time.Time { return t }
+	}
+
+	localNow := now.In(location)
+	date := day(localNow)
+
+	return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, location), nil
+}
+
+func parseClock(clock string) (int, int, error) {
+	match := clockPattern.FindStringSubmatch(clock)
+
+	hour, _ := strconv.Atoi(match[1])
+	minute := 0
+	if match[2] != "" {
+		minute, _ = strconv.Atoi(match[2])
+	}
+
+	switch match[3] {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, fmt.Errorf("`%s` is not a valid time", clock)
+		}
+		hour = hour % 12
+		if match[3] == "pm" {
+			hour += 12
+		}
+	default:
+		if match[2] == "" {
+			return 0, 0, fmt.Errorf("`%s` is not a valid time, use `9am` or `09:00`", clock)
+		}
+	}
+
+	if hour > 23 || minute > 59 {
+		return 0, 0, fmt.Errorf("`%s` is not a valid time", clock)
+	}
+
+	return hour, minute, nil
+}
+
+// parseWeekday accepts the name of a weekday or any abbreviation of at least three letters.
+func parseWeekday(word string) (time.Weekday, bool) {
+	if len(word) < 3 {
+		return 0, false
+	}
+
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.HasPrefix(strings.ToLower(weekday.String()), word) {
+			return weekday, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseReleaseDate(t *testing.T) {
+	// Saturday evening in UTC, already Sunday in Berlin and Kolkata, and the
+	// night before daylight saving time starts in the US
+	now := time.Date(2026, time.March, 7, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		text string
+		want time.Time
+	}{
+		{"", time.Date(2026, time.March, 7, 0, 0, 0, 0, time.UTC)},
+		{"today 10:00", time.Date(2026, time.March, 7, 10, 0, 0, 0, time.UTC)},
+		{"tomorrow at 10:00", time.Date(2026, time.March, 8, 10, 0, 0, 0, time.UTC)},
+		{"tomorrow 12am", time.Date(2026, time.March, 8, 0, 0, 0, 0, time.UTC)},
+		{"tomorrow 12pm", time.Date(2026, time.March, 8, 12, 0, 0, 0, time.UTC)},
+		{"tomorrow 12:30am", time.Date(2026, time.March, 8, 0, 30, 0, 0, time.UTC)},
+		{"tomorrow noon", time.Date(2026, time.March, 8, 12, 0, 0, 0, time.UTC)},
+		{"tomorrow midnight", time.Date(2026, time.March, 8, 0, 0, 0, 0, time.UTC)},
+		// The day is counted in the timezone, where it may already be tomorrow
+		{"tomorrow 9am IST", time.Date(2026, time.March, 9, 3, 30, 0, 0, time.UTC)},
+		{"today 9am Asia/Kolkata", time.Date(2026, time.March, 8, 3, 30, 0, 0, time.UTC)},
+		{"today 10am PT", time.Date(2026, time.March, 7, 18, 0, 0, 0, time.UTC)},
+		// Daylight saving time starts overnight, whatever the abbreviation says
+		{"tomorrow 9am PT", time.Date(2026, time.March, 8, 16, 0, 0, 0, time.UTC)},
+		{"tomorrow 9am PST", time.Date(2026, time.March, 8, 16, 0, 0, 0, time.UTC)},
+		{"2026-03-30 10am Europe/London", time.Date(2026, time.March, 30, 9, 0, 0, 0, time.UTC)},
+		{"2026-03-27 10am GMT", time.Date(2026, time.March, 27, 10, 0, 0, 0, time.UTC)},
+		// A weekday is the next one, a week away when it is today
+		{"saturday 17:00", time.Date(2026, time.March, 14, 17, 0, 0, 0, time.UTC)},
+		{"sun 9am", time.Date(2026, time.March, 8, 9, 0, 0, 0, time.UTC)},
+		{"sunday 9am Europe/Berlin", time.Date(2026, time.March, 15, 8, 0, 0, 0, time.UTC)},
+		{"on Friday at 5pm ET", time.Date(2026, time.March, 13, 21, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		got, err := parseReleaseDate(test.text, now)
+		if err != nil {
+			t.Errorf("parseReleaseDate(%q) failed: %s", test.text, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("parseReleaseDate(%q) = %s, want %s", test.text, got.UTC(), test.want)
+		}
+	}
+}
+
+func TestParseReleaseDateRejectsInvalidDates(t *testing.T) {
+	now := time.Date(2026, time.March, 7, 23, 30, 0, 0, time.UTC)
+
+	for _, text := range []string{
+		"tomorrow 9",
+		"tomorrow 24:00",
+		"tomorrow 9:60",
+		"tomorrow 0am",
+		"tomorrow 13pm",
+		"tomorrow 9am 10am",
+		"tomorrow noon 9am",
+		"tomorrow 9am Mars/Olympus",
+		"tomorrow 9am Local",
+		"2026-02-30",
+		"someday",
+	} {
+		if got, err := parseReleaseDate(text, now); err == nil {
+			t.Errorf("parseReleaseDate(%q) = %s, want an error", text, got)
+		}
+	}
+}
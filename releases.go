@@ -0,0 +1,231 @@
+package main
+
+import (
+	slack "ciderbot/slack"
+	"ciderbot/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+const (
+	pendingDeveloperReleaseState = "PENDING_DEVELOPER_RELEASE"
+	releaseTrackerSchedule       = "*/30 * * * *"
+)
+
+type releaseTracker struct {
+	Version string `json:"version"`
+}
+
+// releaseTrackerState remembers the last phased release update that was
+// posted. SeenLive tells that the tracked version went live, until then the
+// previous version may still be the live one.
+type releaseTrackerState struct {
+	Day      int    `json:"day"`
+	Status   string `json:"status"`
+	SeenLive bool   `json:"seen_live"`
+}
+
+func handleReleaseNowCommand(ctx context.Context, store StoreClient) types.SlackResponse {
+	inflightRelease, err := store.InflightRelease(ctx)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find an inflight release for your app."}.Render()
+	}
+
+	if inflightRelease.AppStoreState != pendingDeveloperReleaseState {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("*%s* can only be released once it is approved, it is `%s`.", inflightRelease.VersionName, inflightRelease.AppStoreState)}.Render()
+	}
+
+	return slack.ReleaseConfirmation{
+		VersionString: inflightRelease.VersionName,
+		BuildNumber:   inflightRelease.BuildNumber,
+		PhasedRelease: inflightRelease.PhasedRelease.Id != "",
+		ActionId:      "release_now",
+		CancelId:      "release_now_cancel",
+	}.Render()
+}
+
+func handleReleaseNowAction(ctx context.Context, interaction types.SlackInteraction, action types.SlackAction, user *types.User, repos Repositories, store StoreClient) types.SlackResponse {
+	// The confirmation may be stale, so check the version is still waiting for us
+	inflightRelease, err := store.InflightRelease(ctx)
+	if err != nil || inflightRelease.VersionName != action.Value || inflightRelease.AppStoreState != pendingDeveloperReleaseState {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("*%s* is no longer waiting to be released.", action.Value)}.Render()
+	}
+
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find your app."}.Render()
+	}
+
+	// Only the first click of the confirmation releases the version
+	request := types.ReleaseRequest{
+		SlackTeamID:   interaction.Team.Id,
+		VersionString: action.Value,
+		RequestedBy:   interaction.User.Id,
+	}
+	claimed, err := repos.Releases.Claim(&request)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not release *%s*, please try again.", action.Value)}.Render()
+	}
+	if !claimed {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("*%s* is already being released.", action.Value)}.Render()
+	}
+
+	liveRelease, err := store.ReleaseInflight(ctx)
+	if err != nil {
+		if err := repos.Releases.Unclaim(&request); err != nil {
+			slog.ErrorContext(ctx, "release: could not unclaim the release", "version", action.Value, "error", err)
+		}
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not release *%s*, please try again.", action.Value)}.Render()
+	}
+
+	description := fmt.Sprintf("<@%s> released *%s* to all users of the App Store.", interaction.User.Id, action.Value)
+	if liveRelease.PhasedRelease.Id != "" {
+		description = fmt.Sprintf("<@%s> released *%s* to the App Store, the phased release updates will be posted to this channel.", interaction.User.Id, action.Value)
+
+		err = trackRelease(repos.Jobs, interaction.Team.Id, interaction.Channel.Id, liveRelease)
+		if err != nil {
+			slog.ErrorContext(ctx, "release: could not track the release", "version", liveRelease.VersionName, "error", err)
+		}
+	}
+
+	response := slack.ReleaseUpdate{
+		Title:       ":rocket: Released",
+		Description: description,
+		Release:     toLiveRelease(appInfo.Id, liveRelease),
+	}.Render()
+	response.ReplaceOriginal = true
+
+	return response
+}
+
+func handleReleaseNowCancelAction(ctx context.Context, interaction types.SlackInteraction, action types.SlackAction, user *types.User, repos Repositories, store StoreClient) types.SlackResponse {
+	response := slack.EphemeralMessage{Msg: fmt.Sprintf("<@%s> decided not to release *%s* yet.", interaction.User.Id, action.Value)}.Render()
+	response.ResponseType = "in_channel"
+	response.ReplaceOriginal = true
+
+	return response
+}
+
+// trackRelease posts the progress of a freshly released version until its phased release is complete.
+func trackRelease(jobs ScheduledJobRepository, teamID string, channelID string, liveRelease types.Release) error {
+	payload, err := json.Marshal(releaseTracker{Version: liveRelease.VersionName})
+	if err != nil {
+		return err
+	}
+
+	job := &types.ScheduledJob{
+		Kind:           "release_tracker",
+		SlackTeamID:    teamID,
+		SlackChannelID: channelID,
+		Schedule:       releaseTrackerSchedule,
+		Timezone:       defaultDigestTimezone,
+		Payload:        string(payload),
+	}
+
+	err = upsertScheduledJob(jobs, job)
+	if err != nil {
+		return err
+	}
+
+	return saveReleaseTrackerState(jobs, job, releaseTrackerState{
+		Day:    liveRelease.PhasedRelease.CurrentDayNumber,
+		Status: liveRelease.PhasedRelease.PhasedReleaseState,
+	})
+}
+
+func runReleaseTrackerJob(ctx context.Context, job *types.ScheduledJob, repos Repositories, stores StoreFactory) error {
+	var tracker releaseTracker
+	if err := json.Unmarshal([]byte(job.Payload), &tracker); err != nil {
+		return err
+	}
+
+	var state releaseTrackerState
+	if job.State != "" {
+		if err := json.Unmarshal([]byte(job.State), &state); err != nil {
+			return err
+		}
+	}
+
+	user, err := repos.Users.FindByTeamID(job.SlackTeamID)
+	if err != nil {
+		return err
+	}
+
+	if !user.AppStoreBundleID.Valid || !user.SlackAccessToken.Valid {
+		return fmt.Errorf("release tracker: workspace %s is not fully connected", job.SlackTeamID)
+	}
+
+	store := stores.appStore(user)
+	liveRelease, err := store.LiveRelease(ctx)
+	if err != nil {
+		return err
+	}
+
+	if liveRelease.VersionName != tracker.Version {
+		// Right after the release the previous version is still live
+		if !state.SeenLive {
+			return nil
+		}
+
+		// A newer version went live, there is nothing left to track
+		_, err = repos.Jobs.DeleteByID(job.Kind, job.SlackTeamID, job.ID)
+		return err
+	}
+
+	phasedRelease := liveRelease.PhasedRelease
+	seenState := releaseTrackerState{
+		Day:      phasedRelease.CurrentDayNumber,
+		Status:   phasedRelease.PhasedReleaseState,
+		SeenLive: true,
+	}
+	if seenState.Day == state.Day && seenState.Status == state.Status {
+		if state.SeenLive {
+			return nil
+		}
+		return saveReleaseTrackerState(repos.Jobs, job, seenState)
+	}
+
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	release := toLiveRelease(appInfo.Id, liveRelease)
+	update := slack.ReleaseUpdate{
+		Title:       ":satellite_antenna: Phased Release Update",
+		Description: fmt.Sprintf("The release of *%s* moved to *day %d* with status `%s`.", tracker.Version, phasedRelease.CurrentDayNumber, phasedRelease.PhasedReleaseState),
+		Release:     release,
+	}
+
+	finished := phasedRelease.PhasedReleaseState == "COMPLETE" || phasedRelease.PhasedReleaseState == ""
+	if finished {
+		update.Title = ":tada: Release Complete"
+		update.Description = fmt.Sprintf("*%s* is now available to all users.", tracker.Version)
+	}
+
+	err = postMessageToSlack(ctx, user.SlackAccessToken.String, job.SlackChannelID, update.Render())
+	if err != nil {
+		return err
+	}
+
+	if finished {
+		_, err = repos.Jobs.DeleteByID(job.Kind, job.SlackTeamID, job.ID)
+		return err
+	}
+
+	// Nobody asked for the update, so nobody is told when the chart is missing
+	uploadPhasedReleaseChart(ctx, user, job.SlackChannelID, "", release)
+
+	return saveReleaseTrackerState(repos.Jobs, job, seenState)
+}
+
+func saveReleaseTrackerState(jobs ScheduledJobRepository, job *types.ScheduledJob, trackerState releaseTrackerState) error {
+	state, err := json.Marshal(trackerState)
+	if err != nil {
+		return err
+	}
+
+	return jobs.SaveState(job, string(state))
+}
@@ -0,0 +1,719 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Audit event actions.
+const (
+	auditSignedIn          = "user.signed_in"
+	auditDeleted           = "user.deleted"
+	auditSlackConnected    = "slack.connected"
+	auditAppStoreConnected = "app_store.connected"
+	auditPlayConnected     = "google_play.connected"
+)
+
+type UserRepository interface {
+	FindByEmail(email string) (*types.User, error)
+	FindByTeamID(teamID string) (*types.User, error)
+	// SignIn creates the user, or refreshes the profile of a returning one.
+	SignIn(user *types.User) error
+	ConnectWorkspace(user *types.User, workspace slackWorkspace) error
+	CountCommand(user *types.User) error
+	SetPlatform(user *types.User, platform string) error
+	Delete(user *types.User) error
+}
+
+type AppRepository interface {
+	ConnectAppStore(user *types.User, app appStoreApp) error
+	ConnectGooglePlay(user *types.User, app googlePlayApp) error
+}
+
+type MetricsRepository interface {
+	CountDeletedUser() error
+}
+
+type AuditEventRepository interface {
+	Record(event *types.AuditEvent) error
+}
+
+type CommandRepository interface {
+	Enqueue(ctx context.Context, command string, form types.SlackFormData) error
+	// ClaimNext leases the oldest due command, including the ones whose worker
+	// went away mid-run, of a workspace below its share of workers.
+	ClaimNext(now time.Time) (*types.CommandJob, error)
+	// Renew extends the lease of a running command, it returns false when the
+	// command is no longer ours.
+	Renew(job *types.CommandJob, lockedUntil time.Time) (bool, error)
+	SaveResponse(job *types.CommandJob) error
+	// Retry and Finish fail with errCommandClaimedElsewhere when the command
+	// is no longer ours.
+	Retry(job *types.CommandJob, nextAttemptAt time.Time, lastError string) error
+	Finish(job *types.CommandJob, status string, lastError string) error
+	// Interrupt fails the command unless it got done or went back to the queue
+	// meanwhile, it returns false then.
+	Interrupt(job *types.CommandJob) (bool, error)
+	// Cleanup fails the commands that can no longer be answered and forgets
+	// the finished ones after a while.
+	Cleanup(now time.Time) error
+}
+
+type ScheduledJobRepository interface {
+	Due(now time.Time) ([]types.ScheduledJob, error)
+	// Claim moves the job to its next run, it returns false when another
+	// instance got to it first.
+	Claim(job *types.ScheduledJob, nextRunAt time.Time, now time.Time) (bool, error)
+	SaveState(job *types.ScheduledJob, state string) error
+	// Upsert replaces the job of the same kind in the channel, keeping its state.
+	Upsert(job *types.ScheduledJob) error
+	Create(job *types.ScheduledJob) error
+	// Find returns nil when there is no such job.
+	Find(kind string, teamID string, channelID string) (*types.ScheduledJob, error)
+	List(kind string, teamID string) ([]types.ScheduledJob, error)
+	Delete(kind string, teamID string, channelID string) (bool, error)
+	DeleteByID(kind string, teamID string, id uint) (bool, error)
+}
+
+type DeliveryRepository interface {
+	// ClaimResponseURLUse counts a use of the response url by its hash, it
+	// returns false once the url is used up.
+	ClaimResponseURLUse(urlHash string) (bool, error)
+	RecordFailure(failure *types.SlackDeliveryFailure) error
+	CleanupResponseURLs(before time.Time) error
+}
+
+type ReviewRepository interface {
+	// MarkPosted returns false when the review was posted before.
+	MarkPosted(review *types.PostedReview) (bool, error)
+	UnmarkPosted(review *types.PostedReview) error
+	// FindPosted returns nil when the review was not posted.
+	FindPosted(teamID string, channelID string, reviewID string) (*types.PostedReview, error)
+	SaveReply(review *types.PostedReview) error
+}
+
+type ReleaseRepository interface {
+	// Claim returns false when the release of the version was requested before.
+	Claim(request *types.ReleaseRequest) (bool, error)
+	Unclaim(request *types.ReleaseRequest) error
+}
+
+type RatingRepository interface {
+	// AddRatings keeps the ratings not seen before.
+	AddRatings(ratings []types.ReviewRating) error
+	CountByTerritory(teamID string) ([]ratingAggregate, error)
+	CountBetween(teamID string, from time.Time, to time.Time) (ratingAggregate, error)
+	SaveSnapshots(snapshots []types.RatingSnapshot) error
+	// SnapshotsAt returns the latest snapshots taken on or before the given time.
+	SnapshotsAt(teamID string, at time.Time) ([]types.RatingSnapshot, error)
+}
+
+type SalesRepository interface {
+	SyncedDays(teamID string, from time.Time, to time.Time) ([]types.SalesReportDay, error)
+	// SaveReport stores the rows of the day and marks it as synced.
+	SaveReport(teamID string, reportDate time.Time, rows []types.SalesReportRow) error
+	Rows(teamID string, from time.Time, to time.Time) ([]types.SalesReportRow, error)
+}
+
+// Repositories is everything the handlers read and write, so that they can
+// run against the database or entirely in memory.
+type Repositories struct {
+	Users       UserRepository
+	Apps        AppRepository
+	Metrics     MetricsRepository
+	AuditEvents AuditEventRepository
+	Commands    CommandRepository
+	Jobs        ScheduledJobRepository
+	Deliveries  DeliveryRepository
+	Reviews     ReviewRepository
+	Releases    ReleaseRepository
+	Ratings     RatingRepository
+	Sales       SalesRepository
+
+	transaction func(fn func(repos Repositories) error) error
+}
+
+// Transaction runs fn with repositories whose changes are kept only if fn succeeds.
+func (repos Repositories) Transaction(fn func(repos Repositories) error) error {
+	return repos.transaction(fn)
+}
+
+type slackWorkspace struct {
+	TeamID       string
+	TeamName     string
+	AccessToken  string
+	RefreshToken string
+	// Scopes are the comma separated scopes Slack granted to the bot.
+	Scopes string
+}
+
+// appStoreApp carries the P8 key already encrypted.
+type appStoreApp struct {
+	BundleID     string
+	IssuerID     string
+	KeyID        string
+	VendorNumber string
+	P8File       []byte
+	P8FileIV     []byte
+}
+
+// googlePlayApp carries the service account already encrypted.
+type googlePlayApp struct {
+	PackageName      string
+	ServiceAccount   []byte
+	ServiceAccountIV []byte
+}
+
+func newGormRepositories(db *gorm.DB) Repositories {
+	return Repositories{
+		Users:       gormUserRepository{db: db},
+		Apps:        gormAppRepository{db: db},
+		Metrics:     gormMetricsRepository{db: db},
+		AuditEvents: gormAuditEventRepository{db: db},
+		Commands:    gormCommandRepository{db: db},
+		Jobs:        gormScheduledJobRepository{db: db},
+		Deliveries:  gormDeliveryRepository{db: db},
+		Reviews:     gormReviewRepository{db: db},
+		Releases:    gormReleaseRepository{db: db},
+		Ratings:     gormRatingRepository{db: db},
+		Sales:       gormSalesRepository{db: db},
+		transaction: func(fn func(repos Repositories) error) error {
+			return db.Transaction(func(tx *gorm.DB) error {
+				return fn(newGormRepositories(tx))
+			})
+		},
+	}
+}
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func (repo gormUserRepository) FindByEmail(email string) (*types.User, error) {
+	var user types.User
+	result := repo.db.Where("email = ?", email).First(&user)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &user, nil
+}
+
+func (repo gormUserRepository) FindByTeamID(teamID string) (*types.User, error) {
+	var user types.User
+	result := repo.db.Where("slack_team_id = ?", teamID).First(&user)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &user, nil
+}
+
+func (repo gormUserRepository) SignIn(user *types.User) error {
+	return repo.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "provider_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"email",
+			"name",
+			"avatar_url",
+		}),
+	}).Create(user).Error
+}
+
+func (repo gormUserRepository) ConnectWorkspace(user *types.User, workspace slackWorkspace) error {
+	applyWorkspace(user, workspace)
+	return repo.db.Save(user).Error
+}
+
+func (repo gormUserRepository) CountCommand(user *types.User) error {
+	user.CommandCount += 1
+	return repo.db.Model(user).Update("command_count", gorm.Expr("command_count + 1")).Error
+}
+
+func (repo gormUserRepository) SetPlatform(user *types.User, platform string) error {
+	user.Platform = platform
+	return repo.db.Model(user).Update("platform", platform).Error
+}
+
+func (repo gormUserRepository) Delete(user *types.User) error {
+	return repo.db.Delete(user).Error
+}
+
+type gormAppRepository struct {
+	db *gorm.DB
+}
+
+func (repo gormAppRepository) ConnectAppStore(user *types.User, app appStoreApp) error {
+	applyAppStoreApp(user, app)
+	return repo.db.Save(user).Error
+}
+
+func (repo gormAppRepository) ConnectGooglePlay(user *types.User, app googlePlayApp) error {
+	applyGooglePlayApp(user, app)
+	return repo.db.Save(user).Error
+}
+
+type gormMetricsRepository struct {
+	db *gorm.DB
+}
+
+func (repo gormMetricsRepository) CountDeletedUser() error {
+	var metrics types.Metrics
+	result := repo.db.First(&metrics)
+	if result.Error != nil {
+		metrics = types.Metrics{
+			ID:           1,
+			DeletedUsers: 1,
+		}
+		return repo.db.Create(&metrics).Error
+	}
+
+	metrics.DeletedUsers += 1
+	return repo.db.Save(metrics).Error
+}
+
+type gormAuditEventRepository struct {
+	db *gorm.DB
+}
+
+func (repo gormAuditEventRepository) Record(event *types.AuditEvent) error {
+	return repo.db.Create(event).Error
+}
+
+type gormCommandRepository struct {
+	db *gorm.DB
+}
+
+func (repo gormCommandRepository) Enqueue(ctx context.Context, command string, form types.SlackFormData) error {
+	job, err := newCommandJob(ctx, command, form)
+	if err != nil {
+		return err
+	}
+
+	return repo.db.Create(&job).Error
+}
+
+func (repo gormCommandRepository) ClaimNext(now time.Time) (*types.CommandJob, error) {
+	var jobs []types.CommandJob
+	result := repo.db.
+		Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)", commandQueued, now, commandRunning, now).
+		Order("id").
+		Limit(commandWorkers * commandsPerWorkspace).
+		Find(&jobs)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+
+		claimed, err := repo.claim(job, now)
+		if err != nil {
+			return nil, err
+		}
+		if !claimed {
+			// The workspace is busy or another worker got the command first
+			continue
+		}
+
+		return job, nil
+	}
+
+	return nil, nil
+}
+
+// claim takes the command unless the workspace already runs its share. The
+// running commands of the workspace are counted under a lock of the
+// workspace, as READ COMMITTED on Postgres lets instances claiming at once
+// both count a free slot. SQLite runs one write at a time already.
+func (repo gormCommandRepository) claim(job *types.CommandJob, now time.Time) (bool, error) {
+	lockedUntil := sql.NullTime{Time: now.Add(commandLease), Valid: true}
+
+	var claimed bool
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == postgresDialect {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", job.SlackTeamID).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&types.CommandJob{}).
+			Where("id = ? AND lock_version = ?", job.ID, job.LockVersion).
+			Where("(SELECT count(*) FROM command_jobs AS running WHERE running.slack_team_id = ? AND running.status = ? AND running.locked_until >= ?) < ?",
+				job.SlackTeamID, commandRunning, now, commandsPerWorkspace).
+			Updates(map[string]interface{}{
+				"status":       commandRunning,
+				"locked_until": lockedUntil,
+				"attempts":     gorm.Expr("attempts + 1"),
+				"lock_version": gorm.Expr("lock_version + 1"),
+			})
+		claimed = result.RowsAffected == 1
+		return result.Error
+	})
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	job.Status = commandRunning
+	job.LockedUntil = lockedUntil
+	job.Attempts += 1
+	job.LockVersion += 1
+	return true, nil
+}
+
+func (repo gormCommandRepository) Renew(job *types.CommandJob, lockedUntil time.Time) (bool, error) {
+	result := repo.db.Model(&types.CommandJob{}).
+		Where("id = ? AND status = ? AND lock_version = ?", job.ID, commandRunning, job.LockVersion).
+		Update("locked_until", sql.NullTime{Time: lockedUntil, Valid: true})
+	if result.Error != nil || result.RowsAffected != 1 {
+		return false, result.Error
+	}
+
+	job.LockedUntil = sql.NullTime{Time: lockedUntil, Valid: true}
+	return true, nil
+}
+
+func (repo gormCommandRepository) SaveResponse(job *types.CommandJob) error {
+	return repo.db.Model(&types.CommandJob{}).Where("id = ?", job.ID).Update("response", job.Response).Error
+}
+
+func (repo gormCommandRepository) Retry(job *types.CommandJob, nextAttemptAt time.Time, lastError string) error {
+	result := repo.db.Model(&types.CommandJob{}).
+		Where("id = ? AND lock_version = ?", job.ID, job.LockVersion).
+		Updates(map[string]interface{}{
+			"status":          commandQueued,
+			"next_attempt_at": nextAttemptAt,
+			"last_error":      lastError,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return errCommandClaimedElsewhere
+	}
+
+	job.Status = commandQueued
+	job.NextAttemptAt = nextAttemptAt
+	return nil
+}
+
+func (repo gormCommandRepository) Finish(job *types.CommandJob, status string, lastError string) error {
+	result := repo.db.Model(&types.CommandJob{}).
+		Where("id = ? AND lock_version = ?", job.ID, job.LockVersion).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"last_error":   lastError,
+			"locked_until": sql.NullTime{},
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return errCommandClaimedElsewhere
+	}
+
+	job.Status = status
+	return nil
+}
+
+func (repo gormCommandRepository) Interrupt(job *types.CommandJob) (bool, error) {
+	result := repo.db.Model(&types.CommandJob{}).
+		Where("id = ? AND status = ? AND lock_version = ?", job.ID, commandRunning, job.LockVersion).
+		Updates(map[string]interface{}{
+			"status":       commandFailed,
+			"last_error":   "interrupted by a restart",
+			"locked_until": sql.NullTime{},
+		})
+
+	return result.RowsAffected == 1, result.Error
+}
+
+func (repo gormCommandRepository) Cleanup(now time.Time) error {
+	result := repo.db.Model(&types.CommandJob{}).
+		Where("status IN ? AND expires_at < ?", []string{commandQueued, commandRunning}, now).
+		Updates(map[string]interface{}{"status": commandFailed, "last_error": "response url expired"})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return repo.db.Where("status IN ? AND updated_at < ?", []string{commandDelivered, commandFailed}, now.Add(-commandRetention)).
+		Delete(&types.CommandJob{}).Error
+}
+
+type gormScheduledJobRepository struct {
+	db *gorm.DB
+}
+
+func (repo gormScheduledJobRepository) Due(now time.Time) ([]types.ScheduledJob, error) {
+	var jobs []types.ScheduledJob
+	result := repo.db.Where("next_run_at <= ?", now).Find(&jobs)
+	return jobs, result.Error
+}
+
+func (repo gormScheduledJobRepository) Claim(job *types.ScheduledJob, nextRunAt time.Time, now time.Time) (bool, error) {
+	result := repo.db.Model(&types.ScheduledJob{}).
+		Where("id = ? AND lock_version = ?", job.ID, job.LockVersion).
+		Updates(map[string]interface{}{
+			"next_run_at":  nextRunAt,
+			"last_run_at":  now,
+			"lock_version": job.LockVersion + 1,
+		})
+	if result.Error != nil || result.RowsAffected != 1 {
+		return false, result.Error
+	}
+
+	job.NextRunAt = nextRunAt
+	job.LastRunAt = sql.NullTime{Time: now, Valid: true}
+	job.LockVersion += 1
+	return true, nil
+}
+
+func (repo gormScheduledJobRepository) SaveState(job *types.ScheduledJob, state string) error {
+	job.State = state
+	return repo.db.Model(&types.ScheduledJob{}).Where("id = ?", job.ID).Update("state", state).Error
+}
+
+func (repo gormScheduledJobRepository) Upsert(job *types.ScheduledJob) error {
+	existing, err := repo.Find(job.Kind, job.SlackTeamID, job.SlackChannelID)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		keepScheduledJob(job, *existing)
+	}
+
+	return repo.db.Save(job).Error
+}
+
+func (repo gormScheduledJobRepository) Create(job *types.ScheduledJob) error {
+	return repo.db.Create(job).Error
+}
+
+func (repo gormScheduledJobRepository) Find(kind string, teamID string, channelID string) (*types.ScheduledJob, error) {
+	var job types.ScheduledJob
+	result := repo.db.Where("kind = ? AND slack_team_id = ? AND slack_channel_id = ?", kind, teamID, channelID).Limit(1).Find(&job)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &job, nil
+}
+
+func (repo gormScheduledJobRepository) List(kind string, teamID string) ([]types.ScheduledJob, error) {
+	var jobs []types.ScheduledJob
+	result := repo.db.Where("kind = ? AND slack_team_id = ?", kind, teamID).Order("id").Find(&jobs)
+	return jobs, result.Error
+}
+
+func (repo gormScheduledJobRepository) Delete(kind string, teamID string, channelID string) (bool, error) {
+	result := repo.db.Where("kind = ? AND slack_team_id = ? AND slack_channel_id = ?", kind, teamID, channelID).Delete(&types.ScheduledJob{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (repo gormScheduledJobRepository) DeleteByID(kind string, teamID string, id uint) (bool, error) {
+	result := repo.db.Where("kind = ? AND slack_team_id = ? AND id = ?", kind, teamID, id).Delete(&types.ScheduledJob{})
+	return result.RowsAffected > 0, result.Error
+}
+
+type gormDeliveryRepository struct {
+	db *gorm.DB
+}
+
+func (repo gormDeliveryRepository) ClaimResponseURLUse(urlHash string) (bool, error) {
+	result := repo.db.Where(types.SlackResponseURL{URLHash: urlHash}).FirstOrCreate(&types.SlackResponseURL{URLHash: urlHash})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	result = repo.db.Model(&types.SlackResponseURL{}).
+		Where("url_hash = ? AND uses < ?", urlHash, maxResponseURLUses).
+		Update("uses", gorm.Expr("uses + 1"))
+
+	return result.RowsAffected == 1, result.Error
+}
+
+func (repo gormDeliveryRepository) RecordFailure(failure *types.SlackDeliveryFailure) error {
+	return repo.db.Create(failure).Error
+}
+
+func (repo gormDeliveryRepository) CleanupResponseURLs(before time.Time) error {
+	return repo.db.Where("created_at < ?", before).Delete(&types.SlackResponseURL{}).Error
+}
+
+type gormReviewRepository struct {
+	db *gorm.DB
+}
+
+func (repo gormReviewRepository) MarkPosted(review *types.PostedReview) (bool, error) {
+	result := repo.db.Clauses(clause.OnConflict{DoNothing: true}).Create(review)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (repo gormReviewRepository) UnmarkPosted(review *types.PostedReview) error {
+	return repo.db.Delete(review).Error
+}
+
+func (repo gormReviewRepository) FindPosted(teamID string, channelID string, reviewID string) (*types.PostedReview, error) {
+	var review types.PostedReview
+	result := repo.db.Where("slack_team_id = ? AND slack_channel_id = ? AND review_id = ?", teamID, channelID, reviewID).Limit(1).Find(&review)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+
+	return &review, nil
+}
+
+func (repo gormReviewRepository) SaveReply(review *types.PostedReview) error {
+	return repo.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "slack_team_id"}, {Name: "slack_channel_id"}, {Name: "review_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"replied_by", "replied_at", "updated_at"}),
+	}).Create(review).Error
+}
+
+type gormReleaseRepository struct {
+	db *gorm.DB
+}
+
+func (repo gormReleaseRepository) Claim(request *types.ReleaseRequest) (bool, error) {
+	result := repo.db.Clauses(clause.OnConflict{DoNothing: true}).Create(request)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (repo gormReleaseRepository) Unclaim(request *types.ReleaseRequest) error {
+	return repo.db.Delete(request).Error
+}
+
+type gormRatingRepository struct {
+	db *gorm.DB
+}
+
+func (repo gormRatingRepository) AddRatings(ratings []types.ReviewRating) error {
+	if len(ratings) == 0 {
+		return nil
+	}
+
+	return repo.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&ratings).Error
+}
+
+func (repo gormRatingRepository) CountByTerritory(teamID string) ([]ratingAggregate, error) {
+	var aggregates []ratingAggregate
+	result := repo.db.Model(&types.ReviewRating{}).
+		Select("territory, count(*) as review_count, avg(rating) as average_rating").
+		Where("slack_team_id = ?", teamID).
+		Group("territory").
+		Scan(&aggregates)
+	return aggregates, result.Error
+}
+
+func (repo gormRatingRepository) CountBetween(teamID string, from time.Time, to time.Time) (ratingAggregate, error) {
+	var aggregate ratingAggregate
+	result := repo.db.Model(&types.ReviewRating{}).
+		Select("count(*) as review_count, coalesce(avg(rating), 0) as average_rating").
+		Where("slack_team_id = ? AND created_date >= ? AND created_date < ?", teamID, from, to).
+		Scan(&aggregate)
+	return aggregate, result.Error
+}
+
+func (repo gormRatingRepository) SaveSnapshots(snapshots []types.RatingSnapshot) error {
+	return repo.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "slack_team_id"}, {Name: "territory"}, {Name: "captured_on"}},
+		DoUpdates: clause.AssignmentColumns([]string{"review_count", "average_rating"}),
+	}).Create(&snapshots).Error
+}
+
+func (repo gormRatingRepository) SnapshotsAt(teamID string, at time.Time) ([]types.RatingSnapshot, error) {
+	var latest types.RatingSnapshot
+	result := repo.db.Where("slack_team_id = ? AND captured_on <= ?", teamID, at).Order("captured_on desc").Limit(1).Find(&latest)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+
+	var snapshots []types.RatingSnapshot
+	result = repo.db.Where("slack_team_id = ? AND captured_on = ?", teamID, latest.CapturedOn).Find(&snapshots)
+	return snapshots, result.Error
+}
+
+type gormSalesRepository struct {
+	db *gorm.DB
+}
+
+func (repo gormSalesRepository) SyncedDays(teamID string, from time.Time, to time.Time) ([]types.SalesReportDay, error) {
+	var days []types.SalesReportDay
+	result := repo.db.Where("slack_team_id = ? AND report_date >= ? AND report_date <= ?", teamID, from, to).Find(&days)
+	return days, result.Error
+}
+
+func (repo gormSalesRepository) SaveReport(teamID string, reportDate time.Time, rows []types.SalesReportRow) error {
+	applySalesReportDay(rows, teamID, reportDate)
+
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if len(rows) > 0 {
+			if err := tx.Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+
+		day := types.SalesReportDay{SlackTeamID: teamID, ReportDate: reportDate, RowCount: len(rows)}
+		return tx.Create(&day).Error
+	})
+}
+
+func (repo gormSalesRepository) Rows(teamID string, from time.Time, to time.Time) ([]types.SalesReportRow, error) {
+	var rows []types.SalesReportRow
+	result := repo.db.Where("slack_team_id = ? AND report_date >= ? AND report_date <= ?", teamID, from, to).Find(&rows)
+	return rows, result.Error
+}
+
+// keepScheduledJob carries over what a rescheduled job keeps from the one it replaces.
+func keepScheduledJob(job *types.ScheduledJob, existing types.ScheduledJob) {
+	job.ID = existing.ID
+	job.State = existing.State
+	job.LockVersion = existing.LockVersion + 1
+	job.LastRunAt = existing.LastRunAt
+	job.CreatedAt = existing.CreatedAt
+}
+
+func applySalesReportDay(rows []types.SalesReportRow, teamID string, reportDate time.Time) {
+	for i := range rows {
+		rows[i].SlackTeamID = teamID
+		rows[i].ReportDate = reportDate
+	}
+}
+
+func applyWorkspace(user *types.User, workspace slackWorkspace) {
+	user.SlackAccessToken = sql.NullString{String: workspace.AccessToken, Valid: true}
+	user.SlackRefreshToken = sql.NullString{String: workspace.RefreshToken, Valid: true}
+	user.SlackTeamID = sql.NullString{String: workspace.TeamID, Valid: true}
+	user.SlackTeamName = sql.NullString{String: workspace.TeamName, Valid: true}
+	user.SlackScopes = sql.NullString{String: workspace.Scopes, Valid: workspace.Scopes != ""}
+}
+
+func applyAppStoreApp(user *types.User, app appStoreApp) {
+	user.AppStoreKeyID = sql.NullString{String: app.KeyID, Valid: true}
+	user.AppStoreBundleID = sql.NullString{String: app.BundleID, Valid: true}
+	user.AppStoreIssuerID = sql.NullString{String: app.IssuerID, Valid: true}
+	user.AppStoreVendorNumber = sql.NullString{String: app.VendorNumber, Valid: app.VendorNumber != ""}
+	user.AppStoreP8File = app.P8File
+	user.AppStoreP8FileIV = app.P8FileIV
+	user.AppStoreConnected = true
+}
+
+func applyGooglePlayApp(user *types.User, app googlePlayApp) {
+	user.PlayPackageName = sql.NullString{String: app.PackageName, Valid: true}
+	user.PlayServiceAccount = app.ServiceAccount
+	user.PlayServiceAccountIV = app.ServiceAccountIV
+	user.PlayConnected = true
+	if !user.AppStoreConnected {
+		user.Platform = androidPlatform
+	}
+}
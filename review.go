@@ -0,0 +1,200 @@
+package main
+
+import (
+	slack "ciderbot/slack"
+	"ciderbot/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+const (
+	reviewWatchSchedule = "*/10 * * * *"
+	rejectedState       = "REJECTED"
+)
+
+var userGroupPattern = regexp.MustCompile(`^(?:<!subteam\^)?(S[A-Z0-9]+)(?:\|[^>]*)?>?$`)
+
+type reviewWatch struct {
+	UserGroupId string `json:"user_group_id"`
+}
+
+type reviewWatchState struct {
+	NotifiedRejection string `json:"notified_rejection"`
+}
+
+func handleReviewStatusCommand(ctx context.Context, form types.SlackFormData, repos Repositories, store StoreClient) types.SlackResponse {
+	args := commandArgs(form.Text)
+
+	if len(args) == 0 {
+		reviewStatus, _, err := compileReviewStatus(ctx, store)
+		if err != nil {
+			return slack.EphemeralMessage{Msg: "Could not find an inflight release for your app."}.Render()
+		}
+
+		return reviewStatus.Render()
+	}
+
+	switch args[0] {
+	case "watch":
+		watch := reviewWatch{}
+		if len(args) > 1 {
+			match := userGroupPattern.FindStringSubmatch(args[1])
+			if match == nil {
+				return slack.EphemeralMessage{Msg: fmt.Sprintf("`%s` is not a user group, please mention one, e.g. `review_status watch @ios-team`.", args[1])}.Render()
+			}
+			watch.UserGroupId = match[1]
+		}
+
+		payload, err := json.Marshal(watch)
+		if err != nil {
+			return slack.EphemeralMessage{Msg: "Could not watch the review status."}.Render()
+		}
+
+		job := &types.ScheduledJob{
+			Kind:           "review_watch",
+			SlackTeamID:    form.TeamId,
+			SlackChannelID: form.ChannelId,
+			Schedule:       reviewWatchSchedule,
+			Timezone:       defaultDigestTimezone,
+			Payload:        string(payload),
+		}
+
+		err = upsertScheduledJob(repos.Jobs, job)
+		if err != nil {
+			return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not watch the review status: %s.", err)}.Render()
+		}
+
+		return slack.EphemeralMessage{Msg: "App Review rejections will be announced in this channel."}.Render()
+	case "unwatch":
+		deleted, err := repos.Jobs.Delete("review_watch", form.TeamId, form.ChannelId)
+		if err != nil || !deleted {
+			return slack.EphemeralMessage{Msg: "The review status is not being watched in this channel."}.Render()
+		}
+
+		return slack.EphemeralMessage{Msg: "App Review rejections will no longer be announced in this channel."}.Render()
+	default:
+		return slack.EphemeralMessage{Msg: "Please use `review_status`, `review_status watch [@user-group]` or `review_status unwatch`."}.Render()
+	}
+}
+
+func runReviewWatchJob(ctx context.Context, job *types.ScheduledJob, repos Repositories, stores StoreFactory) error {
+	var watch reviewWatch
+	if err := json.Unmarshal([]byte(job.Payload), &watch); err != nil {
+		return err
+	}
+
+	var state reviewWatchState
+	if job.State != "" {
+		if err := json.Unmarshal([]byte(job.State), &state); err != nil {
+			return err
+		}
+	}
+
+	user, err := repos.Users.FindByTeamID(job.SlackTeamID)
+	if err != nil {
+		return err
+	}
+
+	if !user.AppStoreBundleID.Valid || !user.SlackAccessToken.Valid {
+		return fmt.Errorf("review watch: workspace %s is not fully connected", job.SlackTeamID)
+	}
+
+	reviewStatus, inflightRelease, err := compileReviewStatus(ctx, stores.appStore(user))
+	if err != nil {
+		return err
+	}
+
+	if inflightRelease.AppStoreState != rejectedState {
+		return nil
+	}
+
+	rejection := fmt.Sprintf("%s:%s", inflightRelease.Id, reviewStatus.SubmissionId)
+	if state.NotifiedRejection == rejection {
+		return nil
+	}
+
+	if watch.UserGroupId != "" {
+		reviewStatus.Mention = fmt.Sprintf("<!subteam^%s>", watch.UserGroupId)
+	}
+
+	err = postMessageToSlack(ctx, user.SlackAccessToken.String, job.SlackChannelID, reviewStatus.Render())
+	if err != nil {
+		return err
+	}
+
+	newState, err := json.Marshal(reviewWatchState{NotifiedRejection: rejection})
+	if err != nil {
+		return err
+	}
+
+	return repos.Jobs.SaveState(job, string(newState))
+}
+
+func compileReviewStatus(ctx context.Context, store StoreClient) (slack.ReviewStatus, types.Release, error) {
+	reviewStatus := slack.ReviewStatus{}
+
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return reviewStatus, types.Release{}, err
+	}
+
+	inflightRelease, err := store.InflightRelease(ctx)
+	if err != nil {
+		return reviewStatus, inflightRelease, err
+	}
+
+	reviewStatus.AppId = appInfo.Id
+	reviewStatus.VersionString = inflightRelease.VersionName
+	reviewStatus.BuildNumber = inflightRelease.BuildNumber
+	reviewStatus.StoreStatus = inflightRelease.AppStoreState
+
+	submission, err := store.ReviewSubmission(ctx)
+	if err == nil {
+		reviewStatus.SubmissionId = submission.Id
+		reviewStatus.SubmissionState = submission.State
+		reviewStatus.SubmittedDate = submission.SubmittedDate
+		for _, item := range submission.Items {
+			reviewStatus.Items = append(reviewStatus.Items, slack.ReviewItem{
+				Type:          item.Type,
+				State:         item.State,
+				VersionString: item.VersionString,
+			})
+		}
+	}
+
+	if inflightRelease.AppStoreState != rejectedState {
+		return reviewStatus, inflightRelease, nil
+	}
+
+	// Not every store can read the resolution center, the rejection is still worth reporting
+	messages, err := store.ResolutionCenterMessages(ctx)
+	if errors.Is(err, errNotSupported) {
+		reviewStatus.MessagesUnavailable = true
+		return reviewStatus, inflightRelease, nil
+	}
+	if err != nil {
+		return reviewStatus, inflightRelease, err
+	}
+
+	for _, message := range messages {
+		reviewMessage := slack.ReviewMessage{
+			Body:        message.Body,
+			CreatedDate: message.CreatedDate,
+		}
+
+		for _, reason := range message.RejectionReasons {
+			reviewMessage.Rejections = append(reviewMessage.Rejections, slack.Rejection{
+				Code:        reason.Code,
+				Section:     reason.Section,
+				Description: reason.Description,
+			})
+		}
+
+		reviewStatus.Messages = append(reviewStatus.Messages, reviewMessage)
+	}
+
+	return reviewStatus, inflightRelease, nil
+}
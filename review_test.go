@@ -0,0 +1,69 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"strings"
+	"testing"
+)
+
+// runTestReviewWatch runs the review watch of the channel C0001 as the
+// scheduler would, from what is stored of it.
+func runTestReviewWatch(t *testing.T, repos Repositories, stores StoreFactory) {
+	t.Helper()
+
+	job, err := repos.Jobs.Find("review_watch", "T0001", "C0001")
+	if err != nil || job == nil {
+		t.Fatalf("the review status is not watched (%v)", err)
+	}
+	if err := runReviewWatchJob(context.Background(), job, repos, stores); err != nil {
+		t.Fatalf("the review watch failed: %s", err)
+	}
+}
+
+func TestReviewWatchAnnouncesARejectionOnce(t *testing.T) {
+	slackAPI := fakeSlackAPI(t)
+	store := newMemoryStore()
+	repos := newMemoryRepositories()
+	connectTestWorkspace(t, repos)
+	stores := func(user *types.User) StoreClient { return store }
+
+	response := responseText(t, handleReviewStatusCommand(context.Background(), testForm("review_status watch <!subteam^S0123|ios-team>"), repos, store))
+	if !strings.Contains(response, "will be announced") {
+		t.Fatalf("the review status is not watched: %s", response)
+	}
+
+	// Nothing is announced until the release is rejected
+	runTestReviewWatch(t, repos, stores)
+	if posts := slackAPI.made("chat.postMessage"); len(posts) != 0 {
+		t.Fatalf("announced %d times before the rejection", len(posts))
+	}
+
+	store.inflightRelease.AppStoreState = rejectedState
+	store.submission.Id = "submission-1"
+	for i := 0; i < 2; i++ {
+		runTestReviewWatch(t, repos, stores)
+	}
+	posts := slackAPI.made("chat.postMessage")
+	if len(posts) != 1 || !strings.Contains(posts[0].Body, "subteam^S0123") {
+		t.Fatalf("the rejection was announced %d times: %v", len(posts), posts)
+	}
+
+	// A rejection of the next submission is news again
+	store.submission.Id = "submission-2"
+	runTestReviewWatch(t, repos, stores)
+	if posts := slackAPI.made("chat.postMessage"); len(posts) != 2 {
+		t.Errorf("the second rejection was announced %d times", len(posts)-1)
+	}
+}
+
+func TestReviewStatusPointsToTheResolutionCenterItCannotRead(t *testing.T) {
+	store := newMemoryStore()
+	store.inflightRelease.AppStoreState = rejectedState
+	store.failing = map[string]error{"ResolutionCenterMessages": errNotSupported}
+
+	text := responseText(t, handleReviewStatusCommand(context.Background(), testForm("review_status"), newMemoryRepositories(), store))
+	if !strings.Contains(text, "REJECTED") || !strings.Contains(text, "find them in the Resolution Center") {
+		t.Errorf("the rejection does not point to the Resolution Center: %s", text)
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	slack "ciderbot/slack"
+	"ciderbot/types"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	reviewsFeedSchedule = "*/30 * * * *"
+	reviewsFetchLimit   = 50
+	defaultReviewsLimit = 10
+	// A new feed only posts this many of the existing reviews, the rest are marked as posted.
+	reviewsFeedBacklog = 5
+	reviewReplyBlock   = "review_reply_body"
+	reviewReplyInput   = "response_body"
+)
+
+type reviewsFilter struct {
+	MinRating int    `json:"min_rating,omitempty"`
+	MaxRating int    `json:"max_rating,omitempty"`
+	Territory string `json:"territory,omitempty"`
+}
+
+type reviewReplyMetadata struct {
+	ReviewId  string `json:"review_id"`
+	ChannelId string `json:"channel_id"`
+}
+
+// parseReviewsFilter reads `--rating 5`, `--rating<=2`, `--rating>=4` and
+// `--territory US`; the comparisons arrive as flags named `rating<` and `rating>`.
+func parseReviewsFilter(flags map[string]string) (reviewsFilter, error) {
+	filter := reviewsFilter{}
+
+	for name, value := range flags {
+		switch name {
+		case "rating", "rating<", "rating>":
+			rating, err := strconv.Atoi(value)
+			if err != nil || rating < 1 || rating > 5 {
+				return filter, fmt.Errorf("the rating should be between 1 and 5")
+			}
+
+			if name != "rating>" {
+				filter.MaxRating = rating
+			}
+			if name != "rating<" {
+				filter.MinRating = rating
+			}
+		case "territory":
+			filter.Territory = strings.ToUpper(value)
+		default:
+			return filter, fmt.Errorf("unknown filter --%s", name)
+		}
+	}
+
+	return filter, nil
+}
+
+func (filter reviewsFilter) matches(review types.CustomerReview) bool {
+	if filter.MinRating > 0 && review.Rating < filter.MinRating {
+		return false
+	}
+
+	if filter.MaxRating > 0 && review.Rating > filter.MaxRating {
+		return false
+	}
+
+	return filter.Territory == "" || strings.EqualFold(filter.Territory, review.Territory)
+}
+
+func (filter reviewsFilter) describe() string {
+	var filters []string
+	switch {
+	case filter.MinRating > 0 && filter.MinRating == filter.MaxRating:
+		filters = append(filters, fmt.Sprintf("rated %d", filter.MinRating))
+	case filter.MaxRating > 0:
+		filters = append(filters, fmt.Sprintf("rated %d or lower", filter.MaxRating))
+	case filter.MinRating > 0:
+		filters = append(filters, fmt.Sprintf("rated %d or higher", filter.MinRating))
+	}
+
+	if filter.Territory != "" {
+		filters = append(filters, fmt.Sprintf("from %s", filter.Territory))
+	}
+
+	return strings.Join(filters, ", ")
+}
+
+func fetchCustomerReviews(ctx context.Context, store StoreClient, filter reviewsFilter) ([]types.CustomerReview, error) {
+	customerReviews, err := store.CustomerReviews(ctx, filter.Territory, reviewsFetchLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []types.CustomerReview
+	for _, review := range customerReviews {
+		if filter.matches(review) {
+			filtered = append(filtered, review)
+		}
+	}
+
+	return filtered, nil
+}
+
+func handleReviewsCommand(ctx context.Context, form types.SlackFormData, repos Repositories, store StoreClient) types.SlackResponse {
+	args := commandArgs(form.Text)
+
+	if len(args) > 0 && args[0] == "unwatch" {
+		deleted, err := repos.Jobs.Delete("reviews_feed", form.TeamId, form.ChannelId)
+		if err != nil || !deleted {
+			return slack.EphemeralMessage{Msg: "Customer reviews are not being posted to this channel."}.Render()
+		}
+
+		return slack.EphemeralMessage{Msg: "Customer reviews will no longer be posted to this channel."}.Render()
+	}
+
+	watch := len(args) > 0 && args[0] == "watch"
+	if watch {
+		args = args[1:]
+	}
+
+	flags, _ := commandFlags(args)
+	filter, err := parseReviewsFilter(flags)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not filter the reviews: %s.", err)}.Render()
+	}
+
+	if watch {
+		return watchCustomerReviews(repos.Jobs, form, filter)
+	}
+
+	customerReviews, err := fetchCustomerReviews(ctx, store, filter)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find customer reviews for your app."}.Render()
+	}
+
+	if len(customerReviews) > defaultReviewsLimit {
+		customerReviews = customerReviews[:defaultReviewsLimit]
+	}
+
+	reviewList := slack.CustomerReviews{Filter: filter.describe()}
+	for _, review := range customerReviews {
+		reviewList.Reviews = append(reviewList.Reviews, toCustomerReview(review))
+	}
+
+	return reviewList.Render()
+}
+
+func watchCustomerReviews(jobs ScheduledJobRepository, form types.SlackFormData, filter reviewsFilter) types.SlackResponse {
+	payload, err := json.Marshal(filter)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not watch the customer reviews."}.Render()
+	}
+
+	job := &types.ScheduledJob{
+		Kind:           "reviews_feed",
+		SlackTeamID:    form.TeamId,
+		SlackChannelID: form.ChannelId,
+		Schedule:       reviewsFeedSchedule,
+		Timezone:       defaultDigestTimezone,
+		Payload:        string(payload),
+	}
+
+	err = upsertScheduledJob(jobs, job)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not watch the customer reviews: %s.", err)}.Render()
+	}
+
+	msg := "New customer reviews will be posted to this channel."
+	if description := filter.describe(); description != "" {
+		msg = fmt.Sprintf("New customer reviews %s will be posted to this channel.", description)
+	}
+
+	return slack.EphemeralMessage{Msg: msg}.Render()
+}
+
+func runReviewsFeedJob(ctx context.Context, job *types.ScheduledJob, repos Repositories, stores StoreFactory) error {
+	var filter reviewsFilter
+	if err := json.Unmarshal([]byte(job.Payload), &filter); err != nil {
+		return err
+	}
+
+	user, err := repos.Users.FindByTeamID(job.SlackTeamID)
+	if err != nil {
+		return err
+	}
+
+	if !user.AppStoreBundleID.Valid || !user.SlackAccessToken.Valid {
+		return fmt.Errorf("reviews feed: workspace %s is not fully connected", job.SlackTeamID)
+	}
+
+	customerReviews, err := fetchCustomerReviews(ctx, stores.appStore(user), filter)
+	if err != nil {
+		return err
+	}
+
+	firstRun := job.State == ""
+
+	// Reviews come newest first, post them in the order they were written
+	for i := len(customerReviews) - 1; i >= 0; i-- {
+		review := customerReviews[i]
+		if review.Response != nil {
+			continue
+		}
+
+		postedReview := types.PostedReview{
+			SlackTeamID:    job.SlackTeamID,
+			ReviewID:       review.Id,
+			SlackChannelID: job.SlackChannelID,
+		}
+
+		posted, err := repos.Reviews.MarkPosted(&postedReview)
+		if err != nil {
+			return err
+		}
+
+		if !posted || (firstRun && i >= reviewsFeedBacklog) {
+			continue
+		}
+
+		reviewMessage := slack.NewCustomerReview{Review: toCustomerReview(review)}
+		err = postMessageToSlack(ctx, user.SlackAccessToken.String, job.SlackChannelID, reviewMessage.Render())
+		if err != nil {
+			// Let the next run pick it up again
+			repos.Reviews.UnmarkPosted(&postedReview)
+			return err
+		}
+	}
+
+	if firstRun {
+		return repos.Jobs.SaveState(job, time.Now().UTC().Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func handleReviewReplyAction(ctx context.Context, interaction types.SlackInteraction, action types.SlackAction, user *types.User, repos Repositories, store StoreClient) types.SlackResponse {
+	postedReview, err := repos.Reviews.FindPosted(interaction.Team.Id, interaction.Channel.Id, action.Value)
+	if err == nil && postedReview != nil && postedReview.RepliedBy.Valid {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("<@%s> has already replied to this review.", postedReview.RepliedBy.String)}.Render()
+	}
+
+	if !user.SlackAccessToken.Valid {
+		return slack.EphemeralMessage{Msg: "Please reconnect Slack to reply to reviews."}.Render()
+	}
+
+	metadata, err := json.Marshal(reviewReplyMetadata{ReviewId: action.Value, ChannelId: interaction.Channel.Id})
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not reply to the review."}.Render()
+	}
+
+	modal := slack.ReviewReplyModal{
+		Metadata: string(metadata),
+		BlockId:  reviewReplyBlock,
+		ActionId: reviewReplyInput,
+	}
+
+	err = openSlackView(ctx, user.SlackAccessToken.String, interaction.TriggerId, modal.View())
+	if err != nil {
+		slog.ErrorContext(ctx, "slack: could not open the review reply modal", "error", err)
+		return slack.EphemeralMessage{Msg: "Could not open the reply form."}.Render()
+	}
+
+	return types.SlackResponse{}
+}
+
+func handleReviewReplySubmission(ctx context.Context, interaction types.SlackInteraction, user *types.User, repos Repositories, store StoreClient) {
+	var metadata reviewReplyMetadata
+	if err := json.Unmarshal([]byte(interaction.View.PrivateMetadata), &metadata); err != nil {
+		slog.ErrorContext(ctx, "slack: could not parse the review reply metadata", "error", err)
+		return
+	}
+
+	reviewResponse, err := store.ReplyToReview(ctx, metadata.ReviewId, reviewReplyBody(interaction))
+	if err != nil {
+		failure := slack.EphemeralMessage{Msg: "Could not reply to the review, please try again."}.Render()
+		postEphemeralToSlack(ctx, user.SlackAccessToken.String, metadata.ChannelId, interaction.User.Id, failure)
+		return
+	}
+
+	postedReview := types.PostedReview{
+		SlackTeamID:    interaction.Team.Id,
+		ReviewID:       metadata.ReviewId,
+		SlackChannelID: metadata.ChannelId,
+		RepliedBy:      sql.NullString{String: interaction.User.Id, Valid: true},
+		RepliedAt:      sql.NullTime{Time: time.Now(), Valid: true},
+	}
+
+	if err := repos.Reviews.SaveReply(&postedReview); err != nil {
+		slog.ErrorContext(ctx, "reviews: could not record the reply", "error", err)
+	}
+
+	reply := slack.ReviewReply{
+		UserId: interaction.User.Id,
+		Body:   reviewResponse.Body,
+		State:  reviewResponse.State,
+	}
+	postMessageToSlack(ctx, user.SlackAccessToken.String, metadata.ChannelId, reply.Render())
+}
+
+func toCustomerReview(review types.CustomerReview) slack.CustomerReview {
+	customerReview := slack.CustomerReview{
+		Id:               review.Id,
+		Rating:           review.Rating,
+		Title:            review.Title,
+		Body:             review.Body,
+		ReviewerNickname: review.ReviewerNickname,
+		Territory:        review.Territory,
+		CreatedDate:      review.CreatedDate,
+	}
+
+	if review.Response != nil {
+		customerReview.ResponseBody = review.Response.Body
+		customerReview.ResponseState = review.Response.State
+	}
+
+	return customerReview
+}
+
+// validateReviewReply keeps the modal open until a reply is written.
+func validateReviewReply(interaction types.SlackInteraction) map[string]string {
+	if strings.TrimSpace(reviewReplyBody(interaction)) == "" {
+		return map[string]string{reviewReplyBlock: "Please write a reply."}
+	}
+
+	return nil
+}
+
+func reviewReplyBody(interaction types.SlackInteraction) string {
+	if interaction.View.State == nil {
+		return ""
+	}
+
+	return interaction.View.State.Values[reviewReplyBlock][reviewReplyInput].Value
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"ciderbot/types"
+	"testing"
+)
+
+func TestReviewIsPostedOncePerChannel(t *testing.T) {
+	for name, repos := range map[string]Repositories{
+		"gorm":   newGormRepositories(testDatabase(t)),
+		"memory": newMemoryRepositories(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, channelID := range []string{"C0001", "C0002"} {
+				review := types.PostedReview{SlackTeamID: "T0001", SlackChannelID: channelID, ReviewID: "review-1"}
+				if posted, err := repos.Reviews.MarkPosted(&review); err != nil || !posted {
+					t.Fatalf("the review was not posted in %s (%v)", channelID, err)
+				}
+			}
+
+			again := types.PostedReview{SlackTeamID: "T0001", SlackChannelID: "C0001", ReviewID: "review-1"}
+			if posted, _ := repos.Reviews.MarkPosted(&again); posted {
+				t.Errorf("the review was posted twice in C0001")
+			}
+
+			if found, err := repos.Reviews.FindPosted("T0001", "C0002", "review-1"); err != nil || found == nil || found.SlackChannelID != "C0002" {
+				t.Errorf("the review posted in C0002 was found as %+v (%v)", found, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	slack "ciderbot/slack"
+	"ciderbot/types"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSalesDays    = 7
+	maxSalesDays        = 31
+	salesTerritoryLimit = 10
+	salesVersionLimit   = 5
+)
+
+// Product types of the summary report, see the Sales and Trends reports reference.
+var (
+	downloadProductTypes   = []string{"1", "1F", "1T", "F1", "1E", "1EP", "1EU"}
+	updateProductTypes     = []string{"7", "7F", "7T", "F7"}
+	redownloadProductTypes = []string{"3", "3F", "3T", "F3"}
+)
+
+type salesTotals struct {
+	Downloads   int
+	Updates     int
+	Redownloads int
+	InAppUnits  int
+	Proceeds    map[string]float64
+}
+
+func handleSalesCommand(ctx context.Context, form types.SlackFormData, user *types.User, repos Repositories, store StoreClient) types.SlackResponse {
+	flags, _ := commandFlags(commandArgs(form.Text))
+
+	days := defaultSalesDays
+	if value, ok := flags["days"]; ok {
+		parsedDays, err := strconv.Atoi(value)
+		if err != nil || parsedDays < 1 || parsedDays > maxSalesDays {
+			return slack.EphemeralMessage{Msg: fmt.Sprintf("The number of days should be between 1 and %d.", maxSalesDays)}.Render()
+		}
+		days = parsedDays
+	}
+
+	if !user.AppStoreVendorNumber.Valid {
+		return slack.EphemeralMessage{Msg: "Please add your vendor number along with the App Store Connect details on the dashboard to get sales reports."}.Render()
+	}
+
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find your app."}.Render()
+	}
+
+	// Daily reports are ready the day after, compare the period with the one before it
+	to := truncateToDay(time.Now()).AddDate(0, 0, -1)
+	from := to.AddDate(0, 0, -(days - 1))
+	previousFrom := from.AddDate(0, 0, -days)
+
+	missingDays, err := syncSalesReports(ctx, repos.Sales, form.TeamId, user.AppStoreVendorNumber.String, store, appInfo, previousFrom, to)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not download the sales reports."}.Render()
+	}
+
+	rows, err := repos.Sales.Rows(form.TeamId, from, to)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not read the sales reports."}.Render()
+	}
+
+	previousRows, err := repos.Sales.Rows(form.TeamId, previousFrom, from.AddDate(0, 0, -1))
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not read the sales reports."}.Render()
+	}
+
+	var missingInPeriod []time.Time
+	for _, day := range missingDays {
+		if !day.Before(from) {
+			missingInPeriod = append(missingInPeriod, day)
+		}
+	}
+
+	return compileSalesReport(rows, previousRows, from, to, missingInPeriod).Render()
+}
+
+// syncSalesReports downloads and stores the daily reports not seen before, and
+// returns the days that are not available (yet). It fails when the reports
+// could not be looked up or the store could not be reached.
+func syncSalesReports(ctx context.Context, sales SalesRepository, teamID string, vendorNumber string, store StoreClient, appInfo types.AppMetadata, from time.Time, to time.Time) ([]time.Time, error) {
+	synced, err := sales.SyncedDays(teamID, from, to)
+	if err != nil {
+		slog.ErrorContext(ctx, "sales: could not find the synced reports", "error", err)
+		return nil, err
+	}
+
+	syncedDays := map[time.Time]bool{}
+	for _, day := range synced {
+		syncedDays[day.ReportDate.UTC()] = true
+	}
+
+	var missingDays []time.Time
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if syncedDays[day] {
+			continue
+		}
+
+		report, err := store.SalesReport(ctx, vendorNumber, day)
+		if isTransientError(err) {
+			return missingDays, err
+		}
+		if err != nil {
+			// Apple has no report for days without sales or that are not processed yet
+			slog.InfoContext(ctx, "sales: no report", "day", day.Format("2006-01-02"), "error", err)
+			missingDays = append(missingDays, day)
+			continue
+		}
+
+		rows, err := parseSalesReport(bytes.NewReader(report))
+		if err != nil {
+			slog.ErrorContext(ctx, "sales: could not parse the report", "day", day.Format("2006-01-02"), "error", err)
+			missingDays = append(missingDays, day)
+			continue
+		}
+
+		err = sales.SaveReport(teamID, day, appRows(rows, appInfo))
+		if err != nil {
+			slog.ErrorContext(ctx, "sales: could not store the report", "day", day.Format("2006-01-02"), "error", err)
+			missingDays = append(missingDays, day)
+		}
+	}
+
+	return missingDays, nil
+}
+
+// appRows keeps the rows of the app and its in-app purchases, a report covers every app of the vendor.
+func appRows(rows []types.SalesReportRow, appInfo types.AppMetadata) []types.SalesReportRow {
+	var filtered []types.SalesReportRow
+	for _, row := range rows {
+		if row.AppleIdentifier == appInfo.Id || (appInfo.Sku != "" && row.ParentIdentifier == appInfo.Sku) {
+			filtered = append(filtered, row)
+		}
+	}
+
+	return filtered
+}
+
+// parseSalesReport reads a Sales and Trends summary report, gzipped or not, as
+// tab-separated values with a header row. Columns are looked up by name since
+// Apple adds new ones over time.
+func parseSalesReport(reader io.Reader) ([]types.SalesReportRow, error) {
+	bufferedReader := bufio.NewReader(reader)
+
+	// Gzip streams start with the magic bytes 1f 8b
+	if magic, err := bufferedReader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(bufferedReader)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		bufferedReader = bufio.NewReader(gzipReader)
+	}
+
+	scanner := bufio.NewScanner(bufferedReader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range strings.Split(strings.TrimRight(scanner.Text(), "\r"), "\t") {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	for _, required := range []string{"Units", "Product Type Identifier", "Apple Identifier"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("the report has no %s column", required)
+		}
+	}
+
+	var rows []types.SalesReportRow
+	line := 1
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(fields) {
+				return ""
+			}
+			return strings.TrimSpace(fields[i])
+		}
+
+		units, err := strconv.Atoi(field("Units"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d has invalid units %q", line, field("Units"))
+		}
+
+		developerProceeds, err := parseAmount(field("Developer Proceeds"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d has invalid proceeds %q", line, field("Developer Proceeds"))
+		}
+
+		customerPrice, err := parseAmount(field("Customer Price"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d has an invalid price %q", line, field("Customer Price"))
+		}
+
+		rows = append(rows, types.SalesReportRow{
+			Sku:                   field("SKU"),
+			Title:                 field("Title"),
+			Version:               field("Version"),
+			ProductTypeIdentifier: field("Product Type Identifier"),
+			Units:                 units,
+			DeveloperProceeds:     developerProceeds,
+			CurrencyOfProceeds:    field("Currency of Proceeds"),
+			CustomerPrice:         customerPrice,
+			CustomerCurrency:      field("Customer Currency"),
+			CountryCode:           field("Country Code"),
+			AppleIdentifier:       field("Apple Identifier"),
+			ParentIdentifier:      field("Parent Identifier"),
+			Device:                field("Device"),
+		})
+	}
+
+	return rows, scanner.Err()
+}
+
+func parseAmount(amount string) (float64, error) {
+	if amount == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseFloat(strings.ReplaceAll(amount, ",", ""), 64)
+}
+
+func compileSalesReport(rows []types.SalesReportRow, previousRows []types.SalesReportRow, from time.Time, to time.Time, missingDays []time.Time) slack.SalesReport {
+	totals := sumSales(rows)
+	previousTotals := sumSales(previousRows)
+
+	report := slack.SalesReport{
+		From:              from,
+		To:                to,
+		Downloads:         totals.Downloads,
+		Updates:           totals.Updates,
+		Redownloads:       totals.Redownloads,
+		InAppUnits:        totals.InAppUnits,
+		PreviousDownloads: previousTotals.Downloads,
+		HasPrevious:       len(previousRows) > 0,
+		MissingDays:       len(missingDays),
+	}
+
+	for currency, amount := range totals.Proceeds {
+		report.Proceeds = append(report.Proceeds, slack.SalesAmount{
+			Currency:       currency,
+			Amount:         amount,
+			PreviousAmount: previousTotals.Proceeds[currency],
+		})
+	}
+	sort.Slice(report.Proceeds, func(i, j int) bool {
+		return report.Proceeds[i].Amount > report.Proceeds[j].Amount
+	})
+
+	versionUnits := map[string]int{}
+	territoryUnits := map[string]int{}
+	for _, row := range rows {
+		if !containsString(downloadProductTypes, row.ProductTypeIdentifier) && !containsString(updateProductTypes, row.ProductTypeIdentifier) {
+			continue
+		}
+
+		if row.Version != "" {
+			versionUnits[row.Version] += row.Units
+		}
+		territoryUnits[row.CountryCode] += row.Units
+	}
+
+	report.Versions = topSalesGroups(versionUnits, salesVersionLimit)
+	report.Territories = topSalesGroups(territoryUnits, salesTerritoryLimit)
+
+	return report
+}
+
+func sumSales(rows []types.SalesReportRow) salesTotals {
+	totals := salesTotals{Proceeds: map[string]float64{}}
+
+	for _, row := range rows {
+		switch {
+		case containsString(downloadProductTypes, row.ProductTypeIdentifier):
+			totals.Downloads += row.Units
+		case containsString(updateProductTypes, row.ProductTypeIdentifier):
+			totals.Updates += row.Units
+		case containsString(redownloadProductTypes, row.ProductTypeIdentifier):
+			totals.Redownloads += row.Units
+		case strings.HasPrefix(row.ProductTypeIdentifier, "IA") || strings.HasPrefix(row.ProductTypeIdentifier, "FI"):
+			totals.InAppUnits += row.Units
+		}
+
+		// Proceeds are per unit, refunds come as rows with negative units
+		if row.DeveloperProceeds != 0 && row.CurrencyOfProceeds != "" {
+			totals.Proceeds[row.CurrencyOfProceeds] += row.DeveloperProceeds * float64(row.Units)
+		}
+	}
+
+	return totals
+}
+
+func topSalesGroups(units map[string]int, limit int) []slack.SalesGroup {
+	var groups []slack.SalesGroup
+	for name, count := range units {
+		groups = append(groups, slack.SalesGroup{Name: name, Units: count})
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Units == groups[j].Units {
+			return groups[i].Name < groups[j].Name
+		}
+		return groups[i].Units > groups[j].Units
+	})
+
+	if len(groups) > limit {
+		groups = groups[:limit]
+	}
+
+	return groups
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const salesFixture = "testdata/sales/S_D_85000000_20261014.txt"
+
+var salesApp = types.AppMetadata{Id: "1234567890", Sku: "CIDERBOT"}
+
+// testDatabase migrates a fresh SQLite database, gone once the test ends.
+func testDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	testDB, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "ciderbot.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open the database: %s", err)
+	}
+	if err := migrateUp(testDB); err != nil {
+		t.Fatalf("could not migrate the database: %s", err)
+	}
+
+	return testDB
+}
+
+func readSalesFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	report, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("could not read the fixture: %s", err)
+	}
+
+	return report
+}
+
+func parseSalesFixture(t *testing.T, name string) []types.SalesReportRow {
+	t.Helper()
+
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("could not open the fixture: %s", err)
+	}
+	defer file.Close()
+
+	rows, err := parseSalesReport(file)
+	if err != nil {
+		t.Fatalf("could not parse %s: %s", name, err)
+	}
+
+	return rows
+}
+
+func TestParseSalesReport(t *testing.T) {
+	rows := parseSalesFixture(t, salesFixture)
+
+	if len(rows) != 9 {
+		t.Fatalf("parsed %d rows, want 9", len(rows))
+	}
+
+	refund := rows[5]
+	if refund.Units != -1 || refund.DeveloperProceeds != 6.99 || refund.CustomerPrice != -9.99 || refund.ParentIdentifier != "CIDERBOT" {
+		t.Errorf("the refund row is %+v", refund)
+	}
+
+	yen := rows[7]
+	if yen.DeveloperProceeds != 1050 || yen.CustomerPrice != 1500 || yen.CurrencyOfProceeds != "JPY" || yen.CountryCode != "JP" {
+		t.Errorf("the yen row is %+v", yen)
+	}
+}
+
+func TestParseGzippedSalesReport(t *testing.T) {
+	plain := parseSalesFixture(t, salesFixture)
+	gzipped := parseSalesFixture(t, salesFixture+".gz")
+
+	if !reflect.DeepEqual(plain, gzipped) {
+		t.Errorf("the gzipped report parsed differently:\n%+v\n%+v", gzipped, plain)
+	}
+}
+
+func TestCompileSalesReport(t *testing.T) {
+	rows := appRows(parseSalesFixture(t, salesFixture), salesApp)
+	if len(rows) != 8 {
+		t.Fatalf("kept %d rows of the app, want 8 without the other app", len(rows))
+	}
+
+	day := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	report := compileSalesReport(rows, nil, day, day, nil)
+
+	if report.Downloads != 17 || report.Updates != 30 || report.Redownloads != 4 || report.InAppUnits != 5 {
+		t.Errorf("the units are %d downloads, %d updates, %d redownloads and %d in-app, want 17, 30, 4 and 5", report.Downloads, report.Updates, report.Redownloads, report.InAppUnits)
+	}
+
+	// The refund takes its proceeds back, every currency is summed on its own
+	want := map[string]float64{"USD": 13.98, "EUR": 14.16, "JPY": 1050}
+	if len(report.Proceeds) != len(want) {
+		t.Fatalf("the proceeds are %+v, want %v", report.Proceeds, want)
+	}
+	for _, proceeds := range report.Proceeds {
+		if math.Abs(proceeds.Amount-want[proceeds.Currency]) > 0.001 {
+			t.Errorf("the %s proceeds are %v, want %v", proceeds.Currency, proceeds.Amount, want[proceeds.Currency])
+		}
+	}
+
+	if len(report.Territories) != 2 || report.Territories[0].Name != "US" || report.Territories[0].Units != 42 || report.Territories[1].Name != "GB" {
+		t.Errorf("the territories are %+v, want US with 42 units then GB", report.Territories)
+	}
+	if len(report.Versions) != 1 || report.Versions[0].Name != "1.2.0" || report.Versions[0].Units != 47 {
+		t.Errorf("the versions are %+v, want 1.2.0 with 47 units", report.Versions)
+	}
+}
+
+func TestSyncSalesReports(t *testing.T) {
+	sales := newGormRepositories(testDatabase(t)).Sales
+
+	store := newMemoryStore()
+	from := time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	store.salesReports[to.Format("2006-01-02")] = readSalesFixture(t, salesFixture+".gz")
+
+	missingDays, err := syncSalesReports(context.Background(), sales, "T0001", "85000000", store, salesApp, from, to)
+	if err != nil {
+		t.Fatalf("could not sync the reports: %s", err)
+	}
+	if len(missingDays) != 1 || !missingDays[0].Equal(from) {
+		t.Errorf("the missing days are %v, want only %s", missingDays, from.Format("2006-01-02"))
+	}
+
+	rows, err := sales.Rows("T0001", from, to)
+	if err != nil || len(rows) != 8 {
+		t.Fatalf("stored %d rows (%v), want the 8 of the app", len(rows), err)
+	}
+
+	// A synced day is not downloaded again
+	store.calls = nil
+	if _, err := syncSalesReports(context.Background(), sales, "T0001", "85000000", store, salesApp, to, to); err != nil {
+		t.Fatalf("could not sync the reports again: %s", err)
+	}
+	if store.called("SalesReport") {
+		t.Errorf("the synced report was downloaded again")
+	}
+}
+
+func TestSyncSalesReportsFailsWhenTheStoreIsDown(t *testing.T) {
+	sales := newGormRepositories(testDatabase(t)).Sales
+
+	store := newMemoryStore()
+	store.err = statusError{service: "memory", statusCode: http.StatusServiceUnavailable}
+	day := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+
+	if _, err := syncSalesReports(context.Background(), sales, "T0001", "85000000", store, salesApp, day, day); err == nil {
+		t.Errorf("an unreachable store was reported as a missing day")
+	}
+}
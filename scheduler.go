@@ -0,0 +1,140 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/robfig/cron/v3"
+)
+
+const schedulerInterval = time.Minute
+
+type scheduledJobRunner func(ctx context.Context, job *types.ScheduledJob, repos Repositories, stores StoreFactory) error
+
+var scheduledJobRunners = map[string]scheduledJobRunner{
+	"digest":           runDigestJob,
+	"guardrail":        runGuardrailJob,
+	"review_watch":     runReviewWatchJob,
+	"reviews_feed":     runReviewsFeedJob,
+	"ratings_snapshot": runRatingsSnapshotJob,
+	"release_tracker":  runReleaseTrackerJob,
+}
+
+func initScheduler(repos Repositories, stores StoreFactory) {
+	go func() {
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				// Waited for on shutdown, there is nobody to tell if it is cut short
+				done, ok := inflight.begin(context.Background(), "scheduled jobs", slackDelivery{}, func() bool { return false })
+				if !ok {
+					return
+				}
+				runDueJobs(repos, stores, time.Now().UTC())
+				done()
+			case <-shuttingDown:
+				return
+			}
+		}
+	}()
+}
+
+func runDueJobs(repos Repositories, stores StoreFactory, now time.Time) {
+	jobs, err := repos.Jobs.Due(now)
+	if err != nil {
+		slog.Error("scheduler: could not fetch due jobs", "error", err)
+		return
+	}
+
+	for i := range jobs {
+		if isShuttingDown() {
+			return
+		}
+
+		job := &jobs[i]
+		runner, ok := scheduledJobRunners[job.Kind]
+		if !ok {
+			slog.Warn("scheduler: unknown job kind", "kind", job.Kind, "job_id", job.ID)
+			continue
+		}
+
+		if !claimJob(repos.Jobs, job, now) {
+			continue
+		}
+
+		// Each run gets its own request id, tying its store calls and messages together
+		ctx := withRequestID(context.Background(), newRequestID())
+		if err := runner(ctx, job, repos, stores); err != nil {
+			slog.ErrorContext(ctx, "scheduler: job failed", "kind", job.Kind, "job_id", job.ID, "error", err)
+		}
+	}
+}
+
+// claimJob moves the job to its next run, guarded by the lock version, so that
+// only one of many running instances gets to run a given occurrence.
+func claimJob(jobs ScheduledJobRepository, job *types.ScheduledJob, now time.Time) bool {
+	nextRunAt, err := nextScheduledRun(job.Schedule, job.Timezone, now)
+	if err != nil {
+		slog.Error("scheduler: invalid schedule", "job_id", job.ID, "schedule", job.Schedule, "error", err)
+		return false
+	}
+
+	claimed, err := jobs.Claim(job, nextRunAt, now)
+	if err != nil {
+		slog.Error("scheduler: could not claim the job", "job_id", job.ID, "error", err)
+	}
+
+	return claimed
+}
+
+// upsertScheduledJob schedules the job, replacing the one of the same kind in
+// the channel.
+func upsertScheduledJob(jobs ScheduledJobRepository, job *types.ScheduledJob) error {
+	nextRunAt, err := nextScheduledRun(job.Schedule, job.Timezone, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	job.NextRunAt = nextRunAt
+	return jobs.Upsert(job)
+}
+
+func createScheduledJob(jobs ScheduledJobRepository, job *types.ScheduledJob) error {
+	nextRunAt, err := nextScheduledRun(job.Schedule, job.Timezone, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	job.NextRunAt = nextRunAt
+	return jobs.Create(job)
+}
+
+func nextScheduledRun(schedule string, timezone string, now time.Time) (time.Time, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unknown timezone %s", timezone)
+	}
+
+	parsedSchedule, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid schedule %s: %w", schedule, err)
+	}
+
+	return parsedSchedule.Next(now.In(location)).UTC(), nil
+}
+
+func formatInTimezone(t time.Time, timezone string) string {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	return t.In(location).Format("Mon, 02 Jan 2006 15:04 MST")
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	slack "ciderbot/slack"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// Orchestrators usually give 30 seconds between SIGTERM and SIGKILL
+	shutdownTimeout        = 25 * time.Second
+	restartNoticeTimeout   = 4 * time.Second
+	restartNoticeTemplate  = "The bot is restarting and could not finish %s. Please try again in a minute."
+	interactionDescription = "your last action"
+)
+
+// shuttingDown is closed once the process is asked to stop: the workers stop
+// picking up work and the instance reports itself as not ready.
+var shuttingDown = make(chan struct{})
+
+func isShuttingDown() bool {
+	select {
+	case <-shuttingDown:
+		return true
+	default:
+		return false
+	}
+}
+
+// inflight is what is being answered right now, waited for on shutdown.
+var inflight = &inflightWork{entries: map[int]inflightEntry{}}
+
+type inflightWork struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	nextID  int
+	entries map[int]inflightEntry
+}
+
+type inflightEntry struct {
+	ctx         context.Context
+	description string
+	delivery    slackDelivery
+	// interrupt claims the work before the user is told about the restart, it
+	// returns false when there is nobody to tell.
+	interrupt func() bool
+}
+
+// begin tracks a piece of work until the returned func is called. Once the
+// process is shutting down no new work is taken, begin returns false and the
+// work must not be started.
+func (work *inflightWork) begin(ctx context.Context, description string, delivery slackDelivery, interrupt func() bool) (func(), bool) {
+	work.mu.Lock()
+	defer work.mu.Unlock()
+
+	// Checked under the lock that drain takes before waiting, so nothing is
+	// added to the wait group once drain waits for it
+	if isShuttingDown() {
+		return nil, false
+	}
+
+	work.nextID++
+	id := work.nextID
+	work.entries[id] = inflightEntry{ctx: ctx, description: description, delivery: delivery, interrupt: interrupt}
+	work.wg.Add(1)
+
+	return func() {
+		work.mu.Lock()
+		delete(work.entries, id)
+		work.mu.Unlock()
+		work.wg.Done()
+	}, true
+}
+
+// drain waits for the work to finish until ctx is done, then tells the users
+// whose answers will not come that the bot is restarting.
+func (work *inflightWork) drain(ctx context.Context, repos Repositories) {
+	// A begin that got the lock before the shutdown has added its work once
+	// the lock is free, the others refuse theirs
+	work.mu.Lock()
+	work.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		work.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	work.mu.Lock()
+	entries := make([]inflightEntry, 0, len(work.entries))
+	for _, entry := range work.entries {
+		entries = append(entries, entry)
+	}
+	work.mu.Unlock()
+
+	slog.Warn("shutdown: giving up on work still in flight", "count", len(entries))
+
+	var notices sync.WaitGroup
+	for _, entry := range entries {
+		if entry.interrupt != nil && !entry.interrupt() {
+			continue
+		}
+
+		notices.Add(1)
+		go func(entry inflightEntry) {
+			defer notices.Done()
+			sendRestartNotice(repos, entry)
+		}(entry)
+	}
+	notices.Wait()
+}
+
+func sendRestartNotice(repos Repositories, entry inflightEntry) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(entry.ctx), restartNoticeTimeout)
+	defer cancel()
+
+	notice := slack.EphemeralMessage{Msg: fmt.Sprintf(restartNoticeTemplate, entry.description)}.Render()
+	if err := deliverSlackResponse(ctx, repos, entry.delivery, notice); err != nil {
+		slog.ErrorContext(ctx, "shutdown: could not tell about the restart", "error", err)
+	}
+}
+
+// shutdown stops taking requests and work, then gives what is in flight until
+// the deadline to finish.
+func shutdown(server *http.Server, repos Repositories) {
+	slog.Info("shutdown: draining")
+	close(shuttingDown)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("shutdown: could not close the server", "error", err)
+	}
+
+	inflight.drain(ctx, repos)
+	slog.Info("shutdown: done")
+}
@@ -4,15 +4,21 @@ import (
 	"bytes"
 	slack "ciderbot/slack"
 	"ciderbot/types"
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 )
 
 var ValidSlackCommands = map[string][2]string{
 	"help":                {":eyes:", "Get the usage guide for App Store SlackBot"},
+	"platform":            {":twisted_rightwards_arrows:", "Switch between your iOS and Android app, e.g. `platform android`; only the release commands work for Android apps"},
 	"app_info":            {":information_source:", "Get some basic information about your app to verify you are working with the correct app"},
 	"beta_groups":         {":test_tube:", "List all the beta groups present in TestFlight"},
 	"overall_status":      {":convenience_store:", "Get an overall store status for your app, what builds are distributed to which channels (TestFlight and AppStore)"},
@@ -21,59 +27,210 @@ var ValidSlackCommands = map[string][2]string{
 	"pause_live_release":  {":double_vertical_bar:", "Pause the phased release of the current live release in the App Store"},
 	"resume_live_release": {":arrow_forward:", "Resume the phased release of the current live release in the App Store"},
 	"release_to_all":      {":roller_coaster:", "Release the current live release in the App Store to all users"},
+	"review_status":       {":female-judge:", "Get the App Review status of the inflight release with the rejection reasons (only through applelink, the App Store Connect API has no Resolution Center), or `review_status watch @user-group` to ping them in this channel on a rejection"},
+	"reviews":             {":star:", "List the recent customer reviews, e.g. `reviews --rating<=2 --territory US`, or `reviews watch [filters]` to post new ones to this channel"},
+	"ratings":             {":chart_with_upwards_trend:", "Get the average rating and review count per territory with week-over-week changes and how the live release moved them"},
+	"sales":               {":moneybag:", "Get the downloads, proceeds and top versions and territories from the Sales and Trends reports, e.g. `sales --days 7`"},
+	"metadata":            {":memo:", "Compare the App Store copy of the inflight release with the live release for every locale, e.g. `metadata --locale en-US`"},
+	"create_version":      {":new:", "Create a new version in the App Store, e.g. `create_version 1.2.0 --release-type manual|after_approval|scheduled --phased`, a scheduled release takes `--release-date \"tomorrow 9am PT\"`"},
+	"builds":              {":hammer_and_wrench:", "List the recent builds with their processing state, e.g. `builds --version 1.2.0 --limit 5`"},
+	"build":               {":mag:", "Get the details of a build, e.g. `build 42`"},
+	"release_type":        {":calendar:", "Change the release type of the inflight release, e.g. `release_type manual|after_approval`, or `release_type scheduled tomorrow 9am PT`"},
+	"release_date":        {":alarm_clock:", "Schedule the inflight release for an earliest release date, e.g. `release_date friday 10am Europe/Berlin`"},
+	"phased_release":      {":chart_with_downwards_trend:", "Turn the phased release of the inflight release on or off, e.g. `phased_release on`"},
+	"release_now":         {":rocket:", "Release the approved inflight release to the App Store after a confirmation, and post its phased release updates to this channel"},
+	"attach_build":        {":link:", "Attach a processed build to the inflight release, e.g. `attach_build 42`, or pick one from the list with `attach_build`"},
+	"digest":              {":newspaper:", "Get a release digest, or `digest schedule \"0 9 * * *\" Europe/Berlin` to post it to this channel on a schedule and `digest off` to stop it"},
+	"guardrails":          {":construction:", "Automate the phased release, e.g. `guardrails add pause \"0 17 * * 5\" America/New_York` or `guardrails add release_to_all day 5`; `guardrails list` and `guardrails remove <id>` to manage them"},
 }
 
-func handleSlackCommand(form types.SlackFormData, user *types.User) types.SlackResponse {
-	if !user.AppStoreBundleID.Valid {
-		return slack.EphemeralMessage{Msg: "No iOS app registered. Please add ASC details to use appstoreslackbot."}.Render()
+// Commands that work the same for a Google Play app, the rest are App Store only.
+var androidSlackCommands = map[string]bool{
+	"help":                true,
+	"platform":            true,
+	"app_info":            true,
+	"overall_status":      true,
+	"live_release":        true,
+	"pause_live_release":  true,
+	"resume_live_release": true,
+	"release_to_all":      true,
+}
+
+var slackActionHandlers = map[string]func(context.Context, types.SlackInteraction, types.SlackAction, *types.User, Repositories, StoreClient) types.SlackResponse{
+	"guardrail_undo":     handleGuardrailUndoAction,
+	"attach_build":       handleAttachBuildAction,
+	"release_now":        handleReleaseNowAction,
+	"release_now_cancel": handleReleaseNowCancelAction,
+	"review_reply":       handleReviewReplyAction,
+}
+
+var slackViewHandlers = map[string]func(context.Context, types.SlackInteraction, *types.User, Repositories, StoreClient){
+	"review_reply": handleReviewReplySubmission,
+}
+
+// slackViewValidators check a submitted view before the modal closes, the
+// errors they return are shown next to the inputs by block id.
+var slackViewValidators = map[string]func(types.SlackInteraction) map[string]string{
+	"review_reply": validateReviewReply,
+}
+
+const (
+	slackPostMessageURL        = "https://slack.com/api/chat.postMessage"
+	slackPostEphemeralURL      = "https://slack.com/api/chat.postEphemeral"
+	slackOpenViewURL           = "https://slack.com/api/views.open"
+	slackGetUploadURL          = "https://slack.com/api/files.getUploadURLExternal"
+	slackCompleteUploadURL     = "https://slack.com/api/files.completeUploadExternal"
+	phasedReleaseChartFileName = "phased-release.png"
+	slackFilesWriteScope       = "files:write"
+)
+
+func handleSlackCommand(ctx context.Context, form types.SlackFormData, user *types.User, repos Repositories) types.SlackResponse {
+	if !user.AppStoreBundleID.Valid && !user.PlayConnected {
+		return slack.EphemeralMessage{Msg: "No app registered. Please add ASC or Google Play details to use appstoreslackbot."}.Render()
 	}
 	command := strings.Split(form.Text, " ")[0]
 	if _, ok := ValidSlackCommands[command]; ok {
-		user.CommandCount += 1
-		db.Save(&user)
-		go handleValidSlackCommand(command, form.ResponseUrl, user)
+		if user.Platform == androidPlatform && !androidSlackCommands[command] {
+			return slack.EphemeralMessage{Msg: fmt.Sprintf("The `%s` command is only available for iOS apps. Use `platform ios` to switch to your iOS app.", command)}.Render()
+		}
+
+		if err := repos.Commands.Enqueue(ctx, command, form); err != nil {
+			slog.ErrorContext(ctx, "slack: could not queue the command", "command", command, "error", err)
+			return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not run the `%s` command, please try again.", command)}.Render()
+		}
+		wakeCommandWorker()
+
+		repos.Users.CountCommand(user)
 		return slack.EphemeralMessage{Msg: fmt.Sprintf("Got the `%s` command, working on it.", command)}.Render()
 	}
 
 	return slack.EphemeralMessage{Msg: "Please input a valid command. Use the `help` command to see all the valid commands."}.Render()
 }
 
-func handleValidSlackCommand(command string, responseURL string, user *types.User) {
-	slackResponse := processValidSlackCommand(command, user)
-	sendResponseToSlack(responseURL, slackResponse)
-}
-
-func processValidSlackCommand(command string, user *types.User) types.SlackResponse {
+func processValidSlackCommand(ctx context.Context, command string, form types.SlackFormData, user *types.User, repos Repositories, store StoreClient) types.SlackResponse {
 	switch command {
 	case "help":
 		return handleHelpCommand(user)
+	case "platform":
+		return handlePlatformCommand(form, user, repos.Users)
 	case "app_info":
-		return handleInfoCommand(user)
+		return handleInfoCommand(ctx, store)
 	case "live_release":
-		return handleLiveReleaseCommand(user)
+		return handleLiveReleaseCommand(ctx, form, user, store)
 	case "overall_status":
-		return handleCurrentStatusCommand(user)
+		return handleCurrentStatusCommand(ctx, store)
 	case "beta_groups":
-		return handleBetaGroupsCommand(user)
+		return handleBetaGroupsCommand(ctx, store)
 	case "inflight_release":
-		return handleInflightReleaseCommand(user)
+		return handleInflightReleaseCommand(ctx, store)
 	case "pause_live_release":
-		return handlePauseReleaseCommand(user)
+		return handlePauseReleaseCommand(ctx, store)
 	case "resume_live_release":
-		return handleResumeReleaseCommand(user)
+		return handleResumeReleaseCommand(ctx, store)
 	case "release_to_all":
-		return handleReleaseToAllCommand(user)
+		return handleReleaseToAllCommand(ctx, store)
+	case "review_status":
+		return handleReviewStatusCommand(ctx, form, repos, store)
+	case "reviews":
+		return handleReviewsCommand(ctx, form, repos, store)
+	case "ratings":
+		return handleRatingsCommand(ctx, form, repos, store)
+	case "sales":
+		return handleSalesCommand(ctx, form, user, repos, store)
+	case "metadata":
+		return handleMetadataCommand(ctx, form, store)
+	case "create_version":
+		return handleCreateVersionCommand(ctx, form, store)
+	case "builds":
+		return handleBuildsCommand(ctx, form, store)
+	case "build":
+		return handleBuildCommand(ctx, form, store)
+	case "release_type":
+		return handleReleaseTypeCommand(ctx, form, store)
+	case "release_date":
+		return handleReleaseDateCommand(ctx, form, store)
+	case "phased_release":
+		return handlePhasedReleaseCommand(ctx, form, store)
+	case "release_now":
+		return handleReleaseNowCommand(ctx, store)
+	case "attach_build":
+		return handleAttachBuildCommand(ctx, form, store)
+	case "digest":
+		return handleDigestCommand(ctx, form, repos, store)
+	case "guardrails":
+		return handleGuardrailsCommand(ctx, form, user, repos)
 	default:
 		return slack.EphemeralMessage{Msg: "Please input a valid command!"}.Render()
 	}
 }
 
+func handleSlackInteraction(ctx context.Context, interaction types.SlackInteraction, user *types.User, repos Repositories, store StoreClient) {
+	delivery := interactionDelivery(interaction)
+
+	if interaction.Type == "view_submission" {
+		handler, ok := slackViewHandlers[interaction.View.CallbackId]
+		if !ok {
+			slog.WarnContext(ctx, "slack: unknown view", "callback_id", interaction.View.CallbackId)
+			return
+		}
+
+		handler(ctx, interaction, user, repos, store)
+		return
+	}
+
+	for _, action := range interaction.Actions {
+		handler, ok := slackActionHandlers[action.ActionId]
+		if !ok {
+			slog.WarnContext(ctx, "slack: unknown action", "action_id", action.ActionId)
+			continue
+		}
+
+		// Actions that open a modal have nothing to say in the channel
+		slackResponse := handler(ctx, interaction, action, user, repos, store)
+		if len(slackResponse.Blocks) > 0 {
+			deliverSlackResponse(ctx, repos, delivery, slackResponse)
+		}
+	}
+}
+
+func interactionDelivery(interaction types.SlackInteraction) slackDelivery {
+	return slackDelivery{
+		TeamID:      interaction.Team.Id,
+		ChannelID:   interaction.Channel.Id,
+		UserID:      interaction.User.Id,
+		ResponseURL: interaction.ResponseUrl,
+		ExpiresAt:   time.Now().Add(responseURLValidity),
+	}
+}
+
 func handleHelpCommand(_user *types.User) types.SlackResponse {
 	return slack.HelpText{Commands: ValidSlackCommands}.Render()
 }
 
-func handleInfoCommand(user *types.User) types.SlackResponse {
-	appMetadata, err := getAppMetadata(userAppleCredentials(user))
+func handlePlatformCommand(form types.SlackFormData, user *types.User, users UserRepository) types.SlackResponse {
+	args := commandArgs(form.Text)
+	if len(args) == 0 {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("The commands are working with your *%s* app.", user.Platform)}.Render()
+	}
+
+	platform := strings.ToLower(args[0])
+	if platform != iosPlatform && platform != androidPlatform {
+		return slack.EphemeralMessage{Msg: "Please use `platform ios` or `platform android`."}.Render()
+	}
+
+	if !platformConnected(user, platform) {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("No %s app registered. Please add its details on the dashboard first.", platform)}.Render()
+	}
+
+	if err := users.SetPlatform(user, platform); err != nil {
+		return slack.EphemeralMessage{Msg: "Could not switch the platform."}.Render()
+	}
+
+	return slack.EphemeralMessage{Msg: fmt.Sprintf("The commands will now work with your *%s* app.", platform)}.Render()
+}
+
+func handleInfoCommand(ctx context.Context, store StoreClient) types.SlackResponse {
+	appMetadata, err := store.AppMetadata(ctx)
 	if err != nil {
 		return slack.EphemeralMessage{Msg: "Could not find an app."}.Render()
 	}
@@ -86,29 +243,31 @@ func handleInfoCommand(user *types.User) types.SlackResponse {
 	}.Render()
 }
 
-func handleLiveReleaseCommand(user *types.User) types.SlackResponse {
-	appInfo, err := getAppMetadata(userAppleCredentials(user))
+func handleLiveReleaseCommand(ctx context.Context, form types.SlackFormData, user *types.User, store StoreClient) types.SlackResponse {
+	appInfo, err := store.AppMetadata(ctx)
 	if err != nil {
 		return slack.EphemeralMessage{Msg: "Could not find your app."}.Render()
 	}
 
-	liveRelease, err := getLiveRelease(userAppleCredentials(user))
+	liveRelease, err := store.LiveRelease(ctx)
 
 	if err != nil {
 		return slack.EphemeralMessage{Msg: "Could not find a live release for your app."}.Render()
 	}
 
-	return slack.LiveRelease{
-		AppId:               appInfo.Id,
-		Version:             liveRelease.VersionName,
-		BuildNumber:         liveRelease.BuildNumber,
-		PhasedReleaseStatus: liveRelease.PhasedRelease.CurrentDayNumber,
-		ReleaseStatus:       liveRelease.PhasedRelease.PhasedReleaseState,
-	}.Render()
+	release := toStoreLiveRelease(ctx, store, appInfo.Id, liveRelease)
+	// The chart follows the days of an App Store phased release, below the release
+	if store.Platform() == iosPlatform && user.SlackAccessToken.Valid && (release.ReleaseStatus == "ACTIVE" || release.ReleaseStatus == "PAUSED") {
+		afterResponse(ctx, func(ctx context.Context) {
+			uploadPhasedReleaseChart(ctx, user, form.ChannelId, form.UserId, release)
+		})
+	}
+
+	return release.Render()
 }
 
-func handleCurrentStatusCommand(user *types.User) types.SlackResponse {
-	appCurrentStatuses, err := getAppCurrentStatus(userAppleCredentials(user))
+func handleCurrentStatusCommand(ctx context.Context, store StoreClient) types.SlackResponse {
+	appCurrentStatuses, err := store.CurrentStatus(ctx)
 	if err != nil {
 		return slack.EphemeralMessage{Msg: "Could not find an app."}.Render()
 	}
@@ -136,8 +295,8 @@ func handleCurrentStatusCommand(user *types.User) types.SlackResponse {
 	return storeStatus.Render()
 }
 
-func handleBetaGroupsCommand(user *types.User) types.SlackResponse {
-	betaGroups, err := getBetaGroups(userAppleCredentials(user))
+func handleBetaGroupsCommand(ctx context.Context, store StoreClient) types.SlackResponse {
+	betaGroups, err := store.BetaGroups(ctx)
 	if err != nil {
 		return slack.EphemeralMessage{Msg: "Could not find an app."}.Render()
 	}
@@ -161,117 +320,378 @@ func handleBetaGroupsCommand(user *types.User) types.SlackResponse {
 	return groups.Render()
 }
 
-func handleInflightReleaseCommand(user *types.User) types.SlackResponse {
-	appInfo, err := getAppMetadata(userAppleCredentials(user))
+func handleInflightReleaseCommand(ctx context.Context, store StoreClient) types.SlackResponse {
+	appInfo, err := store.AppMetadata(ctx)
 	if err != nil {
 		return slack.EphemeralMessage{Msg: "Could not find your app."}.Render()
 	}
 
-	inflightRelease, err := getInflightRelease(userAppleCredentials(user))
+	inflightRelease, err := store.InflightRelease(ctx)
 	if err != nil {
 		return slack.EphemeralMessage{Msg: "Could not find an inflight release for your app."}.Render()
 	}
 
-	phasedReleaseEnabled := true
-
-	if inflightRelease.PhasedRelease.Id == "" {
-		phasedReleaseEnabled = false
-	}
-
-	return slack.InflightRelease{
-		VersionString: inflightRelease.VersionName,
-		BuildNumber:   inflightRelease.BuildNumber,
-		StoreStatus:   inflightRelease.AppStoreState,
-		ReleaseType:   inflightRelease.ReleaseType,
-		PhasedRelease: phasedReleaseEnabled,
-		AppId:         appInfo.Id,
-	}.Render()
+	return toInflightRelease(appInfo.Id, inflightRelease).Render()
 }
 
-func handlePauseReleaseCommand(user *types.User) types.SlackResponse {
-	appInfo, err := getAppMetadata(userAppleCredentials(user))
+func handlePauseReleaseCommand(ctx context.Context, store StoreClient) types.SlackResponse {
+	appInfo, err := store.AppMetadata(ctx)
 	if err != nil {
 		return slack.EphemeralMessage{Msg: "Could not find your app."}.Render()
 	}
-	liveRelease, err := pauseLiveRelease(userAppleCredentials(user))
+	liveRelease, err := store.PauseRollout(ctx)
 	if err != nil {
 		return slack.EphemeralMessage{Msg: "Could not find a live release to pause."}.Render()
 	}
 
-	return slack.LiveRelease{
-		AppId:               appInfo.Id,
-		Version:             liveRelease.VersionName,
-		BuildNumber:         liveRelease.BuildNumber,
-		PhasedReleaseStatus: liveRelease.PhasedRelease.CurrentDayNumber,
-		ReleaseStatus:       liveRelease.PhasedRelease.PhasedReleaseState,
-	}.Render()
+	return toStoreLiveRelease(ctx, store, appInfo.Id, liveRelease).Render()
 }
 
-func handleResumeReleaseCommand(user *types.User) types.SlackResponse {
-	appInfo, err := getAppMetadata(userAppleCredentials(user))
+func handleResumeReleaseCommand(ctx context.Context, store StoreClient) types.SlackResponse {
+	appInfo, err := store.AppMetadata(ctx)
 	if err != nil {
 		return slack.EphemeralMessage{Msg: "Could not find your app."}.Render()
 	}
-	liveRelease, err := resumeLiveRelease(userAppleCredentials(user))
+	liveRelease, err := store.ResumeRollout(ctx)
 	if err != nil {
 		return slack.EphemeralMessage{Msg: "Could not find a paused release to resume."}.Render()
 	}
 
-	return slack.LiveRelease{
-		AppId:               appInfo.Id,
-		Version:             liveRelease.VersionName,
-		BuildNumber:         liveRelease.BuildNumber,
-		PhasedReleaseStatus: liveRelease.PhasedRelease.CurrentDayNumber,
-		ReleaseStatus:       liveRelease.PhasedRelease.PhasedReleaseState,
-	}.Render()
+	return toStoreLiveRelease(ctx, store, appInfo.Id, liveRelease).Render()
 }
 
-func handleReleaseToAllCommand(user *types.User) types.SlackResponse {
-	appInfo, err := getAppMetadata(userAppleCredentials(user))
+func handleReleaseToAllCommand(ctx context.Context, store StoreClient) types.SlackResponse {
+	appInfo, err := store.AppMetadata(ctx)
 	if err != nil {
 		return slack.EphemeralMessage{Msg: "Could not find your app."}.Render()
 	}
-	liveRelease, err := releaseToAll(userAppleCredentials(user))
+	liveRelease, err := store.CompleteRollout(ctx)
 	if err != nil {
 		return slack.EphemeralMessage{Msg: "Could not find a live release."}.Render()
 	}
 
-	return slack.LiveRelease{
-		AppId:               appInfo.Id,
-		Version:             liveRelease.VersionName,
-		BuildNumber:         liveRelease.BuildNumber,
-		PhasedReleaseStatus: liveRelease.PhasedRelease.CurrentDayNumber,
-		ReleaseStatus:       liveRelease.PhasedRelease.PhasedReleaseState,
-	}.Render()
+	return toStoreLiveRelease(ctx, store, appInfo.Id, liveRelease).Render()
 }
 
-func sendResponseToSlack(requestURL string, slackResponse types.SlackResponse) error {
+func sendResponseToSlack(ctx context.Context, requestURL string, slackResponse types.SlackResponse, claimUse func() bool) error {
 	var body bytes.Buffer
 	err := json.NewEncoder(&body).Encode(slackResponse)
 	if err != nil {
-		fmt.Printf("slack: could not encode the response: %s\n", err)
+		slog.ErrorContext(ctx, "slack: could not encode the response", "error", err)
 		return err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, requestURL, &body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, &body)
 	if err != nil {
-		fmt.Printf("slack: could not create request: %s\n", err)
+		slog.ErrorContext(ctx, "slack: could not create request", "error", err)
 		return err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	_, err = http.DefaultClient.Do(req)
+	resp, err := doSlackRequest(req, claimUse)
+	observeSlackDelivery("response_url", err)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+func postMessageToSlack(ctx context.Context, token string, channel string, slackResponse types.SlackResponse) error {
+	message := types.SlackMessage{
+		Channel: channel,
+		Blocks:  slackResponse.Blocks,
+	}
+
+	err := callSlackAPI(ctx, token, slackPostMessageURL, message)
+	observeSlackDelivery("chat.postMessage", err)
+	return err
+}
+
+func postEphemeralToSlack(ctx context.Context, token string, channel string, userID string, slackResponse types.SlackResponse) error {
+	message := types.SlackMessage{
+		Channel: channel,
+		User:    userID,
+		Blocks:  slackResponse.Blocks,
+	}
+
+	err := callSlackAPI(ctx, token, slackPostEphemeralURL, message)
+	observeSlackDelivery("chat.postEphemeral", err)
+	return err
+}
+
+func openSlackView(ctx context.Context, token string, triggerID string, view types.SlackView) error {
+	payload := map[string]interface{}{
+		"trigger_id": triggerID,
+		"view":       view,
+	}
+
+	return callSlackAPI(ctx, token, slackOpenViewURL, payload)
+}
+
+func callSlackAPI(ctx context.Context, token string, apiURL string, payload interface{}) error {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "slack: could not encode the message", "error", err)
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, &body)
+	if err != nil {
+		slog.ErrorContext(ctx, "slack: could not create request", "error", err)
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	var apiResponse types.SlackAPIResponse
+	if err := doSlackAPIRequest(req, &apiResponse); err != nil {
+		return err
+	}
+
+	if !apiResponse.Ok {
+		return fmt.Errorf("slack: %s failed with error - %s", path.Base(apiURL), apiResponse.Error)
+	}
+
+	return nil
+}
+
+// uploadPhasedReleaseChart shares the chart in the channel. The user who asked
+// for it, if any, is told when it could not be shared.
+func uploadPhasedReleaseChart(ctx context.Context, user *types.User, channel string, userID string, release slack.LiveRelease) {
+	notify := func(msg string) {
+		if userID != "" {
+			postEphemeralToSlack(ctx, user.SlackAccessToken.String, channel, userID, slack.EphemeralMessage{Msg: msg}.Render())
+		}
+	}
+
+	// Workspaces installed before the chart have to reinstall the app to let
+	// it upload files
+	if !hasSlackScope(user, slackFilesWriteScope) {
+		notify("Could not share the phased release chart. Please reinstall the app from the dashboard to allow file uploads.")
+		return
+	}
+
+	chart, err := release.PhasedReleaseChart()
+	if err != nil {
+		slog.ErrorContext(ctx, "slack: could not draw the phased release chart", "error", err)
+		return
+	}
+
+	title := fmt.Sprintf("Phased release of %s (%s)", release.Version, release.BuildNumber)
+	err = uploadFileToSlack(ctx, user.SlackAccessToken.String, channel, phasedReleaseChartFileName, title, chart)
+	if err != nil {
+		slog.ErrorContext(ctx, "slack: could not upload the phased release chart", "error", err)
+		notify("Could not upload the phased release chart.")
+	}
+}
+
+// hasSlackScope tells whether Slack granted the scope when the workspace was
+// connected. Scopes are only known for workspaces connected since they are
+// stored.
+func hasSlackScope(user *types.User, scope string) bool {
+	for _, granted := range strings.Split(user.SlackScopes.String, ",") {
+		if strings.TrimSpace(granted) == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// uploadFileToSlack shares a file in a channel using Slack's external upload
+// flow: reserve an upload URL, send the bytes to it and complete the upload.
+func uploadFileToSlack(ctx context.Context, token string, channel string, filename string, title string, content []byte) error {
+	form := url.Values{}
+	form.Set("filename", filename)
+	form.Set("length", strconv.Itoa(len(content)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, slackGetUploadURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	var uploadURL struct {
+		types.SlackAPIResponse
+		UploadURL string `json:"upload_url"`
+		FileID    string `json:"file_id"`
+	}
+	if err := doSlackAPIRequest(req, &uploadURL); err != nil {
+		return err
+	}
+	if !uploadURL.Ok {
+		return fmt.Errorf("slack: files.getUploadURLExternal failed with error - %s", uploadURL.Error)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, uploadURL.UploadURL, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
 
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("slack: file upload failed with status - %d", resp.StatusCode)
+	}
+
+	var body bytes.Buffer
+	err = json.NewEncoder(&body).Encode(map[string]interface{}{
+		"channel_id": channel,
+		"files":      []map[string]string{{"id": uploadURL.FileID, "title": title}},
+	})
 	if err != nil {
-		fmt.Printf("slack: request failed: %s\n", err)
 		return err
 	}
 
-	fmt.Printf("slack: response was delivered!")
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, slackCompleteUploadURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	var completed types.SlackAPIResponse
+	if err := doSlackAPIRequest(req, &completed); err != nil {
+		return err
+	}
+	if !completed.Ok {
+		return fmt.Errorf("slack: files.completeUploadExternal failed with error - %s", completed.Error)
+	}
+
 	return nil
 }
 
+func doSlackAPIRequest(req *http.Request, response interface{}) error {
+	resp, err := doSlackRequest(req, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(response)
+	if err != nil {
+		slog.ErrorContext(req.Context(), "slack: could not parse response body", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func toInflightRelease(appID string, inflightRelease types.Release) slack.InflightRelease {
+	phasedReleaseEnabled := true
+
+	if inflightRelease.PhasedRelease.Id == "" {
+		phasedReleaseEnabled = false
+	}
+
+	return slack.InflightRelease{
+		VersionString:       inflightRelease.VersionName,
+		BuildNumber:         inflightRelease.BuildNumber,
+		StoreStatus:         inflightRelease.AppStoreState,
+		ReleaseType:         inflightRelease.ReleaseType,
+		EarliestReleaseDate: inflightRelease.EarliestReleaseDate,
+		PhasedRelease:       phasedReleaseEnabled,
+		AppId:               appID,
+	}
+}
+
+func toLiveRelease(appID string, liveRelease types.Release) slack.LiveRelease {
+	return slack.LiveRelease{
+		AppId:               appID,
+		Version:             liveRelease.VersionName,
+		BuildNumber:         liveRelease.BuildNumber,
+		PhasedReleaseStatus: liveRelease.PhasedRelease.CurrentDayNumber,
+		ReleaseStatus:       liveRelease.PhasedRelease.PhasedReleaseState,
+		StartDate:           liveRelease.PhasedRelease.StartDate,
+		TotalPauseDuration:  liveRelease.PhasedRelease.TotalPauseDuration,
+		RolloutFraction:     liveRelease.PhasedRelease.UserFraction,
+		Platform:            iosPlatform,
+	}
+}
+
+func toStoreLiveRelease(ctx context.Context, store StoreClient, appID string, liveRelease types.Release) slack.LiveRelease {
+	release := toLiveRelease(appID, liveRelease)
+	release.Platform = store.Platform()
+
+	return release
+}
+
+// commandArgs splits the text following the command name into arguments,
+// keeping double-quoted values together.
+func commandArgs(text string) []string {
+	var args []string
+	var current strings.Builder
+	inQuotes := false
+	hasArg := false
+
+	for _, r := range strings.TrimSpace(text) {
+		switch {
+		case r == '"' || r == '“' || r == '”':
+			inQuotes = !inQuotes
+			hasArg = true
+		case r == ' ' && !inQuotes:
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+
+	if hasArg {
+		args = append(args, current.String())
+	}
+
+	if len(args) == 0 {
+		return args
+	}
+
+	return args[1:]
+}
+
+// commandFlags separates `--name value` and `--name=value` flags from the
+// positional arguments of a command.
+func commandFlags(args []string) (map[string]string, []string) {
+	flags := map[string]string{}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name := strings.TrimPrefix(arg, "--")
+		if key, value, found := strings.Cut(name, "="); found {
+			flags[key] = value
+			continue
+		}
+
+		if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			flags[name] = args[i+1]
+			i++
+		} else {
+			flags[name] = ""
+		}
+	}
+
+	return flags, positional
+}
+
 func userAppleCredentials(user *types.User) *types.AppleCredentials {
 	appleCredentials := types.AppleCredentials{
 		BundleID: user.AppStoreBundleID.String,
@@ -0,0 +1,92 @@
+package slack
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	chartWidth     = 560
+	chartHeight    = 280
+	chartPadding   = 32
+	chartBarGap    = 16
+	chartLabelSize = 16
+)
+
+var (
+	chartBackground = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	chartAxis       = color.RGBA{R: 200, G: 200, B: 200, A: 255}
+	chartDone       = color.RGBA{R: 0, G: 122, B: 255, A: 255}
+	chartCurrent    = color.RGBA{R: 52, G: 199, B: 89, A: 255}
+	chartPaused     = color.RGBA{R: 255, G: 149, B: 0, A: 255}
+	chartPending    = color.RGBA{R: 229, G: 229, B: 234, A: 255}
+	chartText       = color.RGBA{R: 60, G: 60, B: 67, A: 255}
+)
+
+// PhasedReleaseChart draws a bar for every day of the phased release with the
+// share of users it reaches, highlighting the days that have been rolled out.
+func (data LiveRelease) PhasedReleaseChart() ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: chartBackground}, image.Point{}, draw.Src)
+
+	days := len(PhasedReleasePercentages)
+	plotHeight := chartHeight - 2*chartPadding - chartLabelSize
+	barWidth := (chartWidth - 2*chartPadding - (days-1)*chartBarGap) / days
+	baseline := chartHeight - chartPadding - chartLabelSize
+
+	fillRect(img, image.Rect(chartPadding, baseline, chartWidth-chartPadding, baseline+1), chartAxis)
+
+	for i, percentage := range PhasedReleasePercentages {
+		day := i + 1
+		x := chartPadding + i*(barWidth+chartBarGap)
+		barHeight := plotHeight * percentage / 100
+		if barHeight < 2 {
+			barHeight = 2
+		}
+
+		barColor := chartPending
+		switch {
+		case day < data.PhasedReleaseStatus || data.ReleaseStatus == "COMPLETE":
+			barColor = chartDone
+		case day == data.PhasedReleaseStatus && data.ReleaseStatus == "PAUSED":
+			barColor = chartPaused
+		case day == data.PhasedReleaseStatus:
+			barColor = chartCurrent
+		}
+
+		fillRect(img, image.Rect(x, baseline-barHeight, x+barWidth, baseline), barColor)
+		drawLabel(img, fmt.Sprintf("%d%%", percentage), x+barWidth/2, baseline-barHeight-4)
+		drawLabel(img, fmt.Sprintf("Day %d", day), x+barWidth/2, baseline+chartLabelSize)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func fillRect(img *image.RGBA, rect image.Rectangle, c color.Color) {
+	draw.Draw(img, rect, &image.Uniform{C: c}, image.Point{}, draw.Src)
+}
+
+func drawLabel(img *image.RGBA, label string, centerX int, baselineY int) {
+	drawer := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(chartText),
+		Face: basicfont.Face7x13,
+	}
+
+	width := drawer.MeasureString(label).Round()
+	drawer.Dot = fixed.P(centerX-width/2, baselineY)
+	drawer.DrawString(label)
+}
@@ -9,6 +9,7 @@ import (
 
 const appStoreUrl string = "<https://appstoreconnect.apple.com/apps/%s/appstore|App Store Connect>"
 const appStoreIcon string = "https://storage.googleapis.com/tramline-public-assets/app-store.png"
+const playConsoleUrl string = "<https://play.google.com/console|Google Play Console>"
 
 type SlackCommand interface {
 	Render() types.SlackResponse
@@ -30,13 +31,22 @@ type HelpText struct {
 }
 
 type LiveRelease struct {
-	AppId               string `json:"app_id"`
-	Version             string `json:"version"`
-	BuildNumber         string `json:"build_number"`
-	PhasedReleaseStatus int    `json:"phased_release_status"`
-	ReleaseStatus       string `json:"release_status"`
+	AppId               string    `json:"app_id"`
+	Version             string    `json:"version"`
+	BuildNumber         string    `json:"build_number"`
+	PhasedReleaseStatus int       `json:"phased_release_status"`
+	ReleaseStatus       string    `json:"release_status"`
+	StartDate           time.Time `json:"start_date"`
+	TotalPauseDuration  int       `json:"total_pause_duration"`
+	Platform            string    `json:"platform"`
+	RolloutFraction     float64   `json:"rollout_fraction"`
 }
 
+// PhasedReleasePercentages is the share of users that gets the update on each day of the phased release.
+var PhasedReleasePercentages = []int{1, 2, 5, 10, 20, 50, 100}
+
+const progressBarWidth = 20
+
 type CurrentStoreStatus struct {
 	Channels []struct {
 		Name   string `json:"name"`
@@ -59,12 +69,13 @@ type BetaGroups struct {
 }
 
 type InflightRelease struct {
-	VersionString string `json:"version_string"`
-	BuildNumber   string `json:"build_number"`
-	StoreStatus   string `json:"store_status"`
-	ReleaseType   string `json:"release_type"`
-	PhasedRelease bool   `json:"phased_release"`
-	AppId         string `json:"app_id"`
+	VersionString       string     `json:"version_string"`
+	BuildNumber         string     `json:"build_number"`
+	StoreStatus         string     `json:"store_status"`
+	ReleaseType         string     `json:"release_type"`
+	EarliestReleaseDate *time.Time `json:"earliest_release_date"`
+	PhasedRelease       bool       `json:"phased_release"`
+	AppId               string     `json:"app_id"`
 }
 
 func (data InflightRelease) Render() types.SlackResponse {
@@ -78,6 +89,9 @@ func (data InflightRelease) Render() types.SlackResponse {
 
 	line1 := fmt.Sprintf("The upcoming release in progress is *%s (%s)* with the current status of `%s`.", data.VersionString, data.BuildNumber, data.StoreStatus)
 	line2 := fmt.Sprintf("The release type is `%s` and phased release is turned *%s*.", data.ReleaseType, phasedRelease)
+	if data.EarliestReleaseDate != nil {
+		line2 = fmt.Sprintf("The release type is `%s`, not before *%s*, and phased release is turned *%s*.", data.ReleaseType, data.EarliestReleaseDate.Format(time.RFC850), phasedRelease)
+	}
 
 	slackResponse := types.SlackResponse{
 		ResponseType: "in_channel",
@@ -227,7 +241,11 @@ func (data CurrentStoreStatus) Render() types.SlackResponse {
 	return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
 }
 
-func (data LiveRelease) Render() types.SlackResponse {
+func (data LiveRelease) summaryBlocks() []types.Block {
+	if data.Platform == "android" {
+		return data.stagedRolloutBlocks()
+	}
+
 	line1 := fmt.Sprintf("We're on *day %d* of *phased release* with status `%s`.", data.PhasedReleaseStatus, data.ReleaseStatus)
 
 	if data.ReleaseStatus == "COMPLETE" {
@@ -236,64 +254,205 @@ func (data LiveRelease) Render() types.SlackResponse {
 		line1 = "The release was fully rolled out to *all users* without phased release."
 	}
 
-	slackResponse := types.SlackResponse{
-		ResponseType: "in_channel",
-		Blocks: []types.Block{
-			{
-				Type: "header",
-				Text: &types.Text{
-					Type:  "plain_text",
-					Text:  ":iphone: Live Release",
-					Emoji: true,
+	slackBlocks := []types.Block{
+		{
+			Type: "section",
+			Fields: []types.Text{
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("*Version:* %s :package:", data.Version),
+				},
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("*Build Number:* %s :1234:", data.BuildNumber),
 				},
 			},
-			{
-				Type: "divider",
+		},
+		{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: line1,
 			},
-			{
-				Type: "section",
-				Fields: []types.Text{
-					{
-						Type: "mrkdwn",
-						Text: fmt.Sprintf("*Version:* %s :package:", data.Version),
-					},
-					{
-						Type: "mrkdwn",
-						Text: fmt.Sprintf("*Build Number:* %s :1234:", data.BuildNumber),
-					},
+		},
+	}
+
+	if data.ReleaseStatus != "ACTIVE" && data.ReleaseStatus != "PAUSED" {
+		return slackBlocks
+	}
+
+	percentage := data.RolloutPercentage()
+	completion := fmt.Sprintf("*Expected Completion:* %s", data.ExpectedCompletion(time.Now()).Format("Mon, 02 Jan 2006"))
+	if data.ReleaseStatus == "PAUSED" {
+		completion += " _(if resumed today)_"
+	}
+
+	return append(slackBlocks,
+		types.Block{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("`%s` *%d%%* of users", progressBar(percentage), percentage),
+			},
+		},
+		types.Block{
+			Type: "section",
+			Fields: []types.Text{
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("*Started:* %s", data.StartDate.Format("Mon, 02 Jan 2006")),
+				},
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("*Paused For:* %s", pluralize(data.TotalPauseDuration, "day")),
+				},
+				{
+					Type: "mrkdwn",
+					Text: completion,
 				},
 			},
-			{
-				Type: "section",
-				Text: &types.Text{
+		},
+	)
+}
+
+// stagedRolloutBlocks summarizes a Google Play staged rollout, which goes by
+// the share of users picked for it rather than by day.
+func (data LiveRelease) stagedRolloutBlocks() []types.Block {
+	line1 := fmt.Sprintf("We're on a *staged rollout* with status `%s`.", data.ReleaseStatus)
+	if data.ReleaseStatus == "COMPLETE" {
+		line1 = "The release was fully rolled out to *all users*."
+	}
+
+	percentage := data.RolloutPercentage()
+
+	return []types.Block{
+		{
+			Type: "section",
+			Fields: []types.Text{
+				{
 					Type: "mrkdwn",
-					Text: line1,
+					Text: fmt.Sprintf("*Version:* %s :package:", data.Version),
+				},
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("*Version Code:* %s :1234:", data.BuildNumber),
 				},
 			},
-			{
-				Type: "divider",
+		},
+		{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: line1,
 			},
-			{
-				Type: "context",
-				Elements: []types.Element{
-					{
-						Type:     "image",
-						ImageURL: appStoreIcon,
-						AltText:  "app store connect",
-					},
-					{
-						Type: "mrkdwn",
-						Text: fmt.Sprintf(appStoreUrl, data.AppId),
-					},
-				},
+		},
+		{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("`%s` *%d%%* of users", progressBar(percentage), percentage),
 			},
-			{
-				Type: "divider",
+		},
+	}
+}
+
+// RolloutPercentage is the share of users that the phased release has reached on the current day.
+func (data LiveRelease) RolloutPercentage() int {
+	if data.ReleaseStatus == "COMPLETE" || data.ReleaseStatus == "" {
+		return 100
+	}
+
+	if data.Platform == "android" {
+		return int(data.RolloutFraction*100 + 0.5)
+	}
+
+	day := data.PhasedReleaseStatus
+	if day < 1 {
+		return 0
+	}
+
+	if day > len(PhasedReleasePercentages) {
+		day = len(PhasedReleasePercentages)
+	}
+
+	return PhasedReleasePercentages[day-1]
+}
+
+// ExpectedCompletion is the day the phased release reaches all users, pushed
+// out by the days it has been paused for. A paused release is expected to
+// complete as if it were resumed at the given time.
+func (data LiveRelease) ExpectedCompletion(now time.Time) time.Time {
+	remainingDays := len(PhasedReleasePercentages) - data.PhasedReleaseStatus
+	if data.ReleaseStatus == "PAUSED" {
+		return now.AddDate(0, 0, remainingDays)
+	}
+
+	return data.StartDate.AddDate(0, 0, len(PhasedReleasePercentages)-1+data.TotalPauseDuration)
+}
+
+func progressBar(percentage int) string {
+	filled := progressBarWidth * percentage / 100
+	return strings.Repeat("▓", filled) + strings.Repeat("░", progressBarWidth-filled)
+}
+
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+func (data LiveRelease) Render() types.SlackResponse {
+	slackBlocks := []types.Block{
+		{
+			Type: "header",
+			Text: &types.Text{
+				Type:  "plain_text",
+				Text:  ":iphone: Live Release",
+				Emoji: true,
 			},
 		},
+		{
+			Type: "divider",
+		},
 	}
 
-	return slackResponse
+	footer := []types.Element{
+		{
+			Type:     "image",
+			ImageURL: appStoreIcon,
+			AltText:  "app store connect",
+		},
+		{
+			Type: "mrkdwn",
+			Text: fmt.Sprintf(appStoreUrl, data.AppId),
+		},
+	}
+	if data.Platform == "android" {
+		footer = []types.Element{
+			{
+				Type: "mrkdwn",
+				Text: playConsoleUrl,
+			},
+		}
+	}
+
+	slackBlocks = append(slackBlocks, data.summaryBlocks()...)
+	slackBlocks = append(slackBlocks,
+		types.Block{
+			Type: "divider",
+		},
+		types.Block{
+			Type:     "context",
+			Elements: footer,
+		},
+		types.Block{
+			Type: "divider",
+		},
+	)
+
+	return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
 }
 
 func (data EphemeralMessage) Render() types.SlackResponse {
@@ -394,3 +553,1322 @@ func (data AppInfo) Render() types.SlackResponse {
 
 	return slackResponse
 }
+
+type DigestChannel struct {
+	Name          string    `json:"name"`
+	VersionString string    `json:"version_string"`
+	BuildNumber   string    `json:"build_number"`
+	Status        string    `json:"status"`
+	ReleaseDate   time.Time `json:"release_date"`
+}
+
+type ReleaseDigest struct {
+	AppId              string           `json:"app_id"`
+	AppName            string           `json:"app_name"`
+	LiveRelease        *LiveRelease     `json:"live_release"`
+	InflightRelease    *InflightRelease `json:"inflight_release"`
+	Channels           []DigestChannel  `json:"channels"`
+	Changes            []string         `json:"changes"`
+	ComparedToPrevious bool             `json:"compared_to_previous"`
+}
+
+func (data ReleaseDigest) Render() types.SlackResponse {
+	slackBlocks := []types.Block{
+		{
+			Type: "header",
+			Text: &types.Text{
+				Type:  "plain_text",
+				Text:  fmt.Sprintf(":newspaper: Release Digest for %s", data.AppName),
+				Emoji: true,
+			},
+		},
+		{
+			Type: "divider",
+		},
+	}
+
+	liveText := "There is no live release."
+	if data.LiveRelease != nil {
+		liveText = fmt.Sprintf("*%s (%s)* is live.", data.LiveRelease.Version, data.LiveRelease.BuildNumber)
+		if data.LiveRelease.ReleaseStatus == "COMPLETE" || data.LiveRelease.ReleaseStatus == "" {
+			liveText += " It is available to *all users*."
+		} else {
+			liveText += fmt.Sprintf(" We're on *day %d* of *phased release* with status `%s`.", data.LiveRelease.PhasedReleaseStatus, data.LiveRelease.ReleaseStatus)
+		}
+	}
+
+	inflightText := "There is no inflight release."
+	if data.InflightRelease != nil {
+		inflightText = fmt.Sprintf("*%s (%s)* is inflight with the current status of `%s`.", data.InflightRelease.VersionString, data.InflightRelease.BuildNumber, data.InflightRelease.StoreStatus)
+	}
+
+	slackBlocks = append(slackBlocks,
+		types.Block{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf(":iphone: %s", liveText),
+			},
+		},
+		types.Block{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf(":airplane_departure: %s", inflightText),
+			},
+		},
+	)
+
+	if len(data.Channels) > 0 {
+		var fields []types.Text
+		for _, channel := range data.Channels {
+			fields = append(fields, types.Text{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("*%s*\n%s (%s) `%s`", strings.Title(channel.Name), channel.VersionString, channel.BuildNumber, channel.Status),
+			})
+		}
+
+		// Slack allows at most 10 fields in a section
+		for start := 0; start < len(fields); start += 10 {
+			end := start + 10
+			if end > len(fields) {
+				end = len(fields)
+			}
+
+			slackBlocks = append(slackBlocks, types.Block{
+				Type:   "section",
+				Fields: fields[start:end],
+			})
+		}
+	}
+
+	if data.ComparedToPrevious {
+		changesText := "*Since the last digest*\nNothing has changed."
+		if len(data.Changes) > 0 {
+			changesText = "*Since the last digest*\n• " + strings.Join(data.Changes, "\n• ")
+		}
+
+		slackBlocks = append(slackBlocks,
+			types.Block{
+				Type: "divider",
+			},
+			types.Block{
+				Type: "section",
+				Text: &types.Text{
+					Type: "mrkdwn",
+					Text: changesText,
+				},
+			},
+		)
+	}
+
+	slackBlocks = append(slackBlocks,
+		types.Block{
+			Type: "divider",
+		},
+		types.Block{
+			Type: "context",
+			Elements: []types.Element{
+				{
+					Type:     "image",
+					ImageURL: appStoreIcon,
+					AltText:  "app store connect",
+				},
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf(appStoreUrl, data.AppId),
+				},
+			},
+		},
+	)
+
+	return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
+}
+
+type Guardrail struct {
+	Id          uint   `json:"id"`
+	Description string `json:"description"`
+	ChannelId   string `json:"channel_id"`
+}
+
+type Guardrails struct {
+	Rules []Guardrail `json:"rules"`
+}
+
+type GuardrailAction struct {
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	UndoAction  string      `json:"undo_action"`
+	Release     LiveRelease `json:"release"`
+}
+
+func (data Guardrails) Render() types.SlackResponse {
+	slackBlocks := []types.Block{
+		{
+			Type: "header",
+			Text: &types.Text{
+				Type:  "plain_text",
+				Text:  ":construction: Guardrails",
+				Emoji: true,
+			},
+		},
+		{
+			Type: "divider",
+		},
+	}
+
+	if len(data.Rules) == 0 {
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: "There are no guardrails for the phased release. Add one with `guardrails add`.",
+			},
+		})
+	}
+
+	for _, rule := range data.Rules {
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("❖ *#%d* %s, announced in <#%s>.", rule.Id, rule.Description, rule.ChannelId),
+			},
+		})
+	}
+
+	slackBlocks = append(slackBlocks, types.Block{
+		Type: "divider",
+	})
+
+	return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
+}
+
+func (data GuardrailAction) Render() types.SlackResponse {
+	slackBlocks := []types.Block{
+		{
+			Type: "header",
+			Text: &types.Text{
+				Type:  "plain_text",
+				Text:  data.Title,
+				Emoji: true,
+			},
+		},
+		{
+			Type: "divider",
+		},
+		{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: data.Description,
+			},
+		},
+	}
+
+	slackBlocks = append(slackBlocks, data.Release.summaryBlocks()...)
+
+	if data.UndoAction != "" {
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "actions",
+			Elements: []types.Element{
+				{
+					Type:     "button",
+					Text:     fmt.Sprintf("Undo (%s)", data.UndoAction),
+					ActionId: "guardrail_undo",
+					Value:    data.UndoAction,
+				},
+			},
+		})
+	}
+
+	slackBlocks = append(slackBlocks, types.Block{
+		Type: "divider",
+	})
+
+	return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
+}
+
+type ReleaseConfirmation struct {
+	VersionString string `json:"version_string"`
+	BuildNumber   string `json:"build_number"`
+	PhasedRelease bool   `json:"phased_release"`
+	ActionId      string `json:"action_id"`
+	CancelId      string `json:"cancel_id"`
+}
+
+type ReleaseUpdate struct {
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Release     LiveRelease `json:"release"`
+}
+
+func (data ReleaseConfirmation) Render() types.SlackResponse {
+	rollout := "all users at once"
+	if data.PhasedRelease {
+		rollout = "a phased release over 7 days"
+	}
+
+	return types.SlackResponse{
+		ResponseType: "in_channel",
+		Blocks: []types.Block{
+			{
+				Type: "header",
+				Text: &types.Text{
+					Type:  "plain_text",
+					Text:  ":rocket: Release Now?",
+					Emoji: true,
+				},
+			},
+			{
+				Type: "divider",
+			},
+			{
+				Type: "section",
+				Text: &types.Text{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("*%s (%s)* has been approved and is waiting to be released to %s.", data.VersionString, data.BuildNumber, rollout),
+				},
+			},
+			{
+				Type: "actions",
+				Elements: []types.Element{
+					{
+						Type:     "button",
+						Text:     "Release",
+						ActionId: data.ActionId,
+						Value:    data.VersionString,
+						Style:    "primary",
+					},
+					{
+						Type:     "button",
+						Text:     "Cancel",
+						ActionId: data.CancelId,
+						Value:    data.VersionString,
+					},
+				},
+			},
+			{
+				Type: "divider",
+			},
+		},
+	}
+}
+
+func (data ReleaseUpdate) Render() types.SlackResponse {
+	slackBlocks := []types.Block{
+		{
+			Type: "header",
+			Text: &types.Text{
+				Type:  "plain_text",
+				Text:  data.Title,
+				Emoji: true,
+			},
+		},
+		{
+			Type: "divider",
+		},
+		{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: data.Description,
+			},
+		},
+	}
+
+	slackBlocks = append(slackBlocks, data.Release.summaryBlocks()...)
+	slackBlocks = append(slackBlocks, types.Block{
+		Type: "divider",
+	})
+
+	return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
+}
+
+type BuildDetails struct {
+	Id              string    `json:"id"`
+	BuildNumber     string    `json:"build_number"`
+	VersionString   string    `json:"version_string"`
+	ProcessingState string    `json:"processing_state"`
+	UploadedDate    time.Time `json:"uploaded_date"`
+	ExpirationDate  time.Time `json:"expiration_date"`
+	Expired         bool      `json:"expired"`
+	MinOsVersion    string    `json:"min_os_version"`
+	BetaGroups      []string  `json:"beta_groups"`
+	AppStoreVersion string    `json:"app_store_version"`
+}
+
+type Builds struct {
+	VersionString string         `json:"version_string"`
+	Builds        []BuildDetails `json:"builds"`
+}
+
+func (data BuildDetails) attachments() string {
+	var attachments []string
+	if data.AppStoreVersion != "" {
+		attachments = append(attachments, fmt.Sprintf("App Store version *%s*", data.AppStoreVersion))
+	}
+	if len(data.BetaGroups) > 0 {
+		attachments = append(attachments, fmt.Sprintf("TestFlight groups *%s*", strings.Join(data.BetaGroups, ", ")))
+	}
+
+	if len(attachments) == 0 {
+		return "not attached to any TestFlight group or App Store version"
+	}
+
+	return "attached to " + strings.Join(attachments, " and ")
+}
+
+func (data BuildDetails) expiry() string {
+	if data.Expired {
+		return fmt.Sprintf("expired on %s", data.ExpirationDate.Format("Mon, 02 Jan 2006"))
+	}
+
+	return fmt.Sprintf("expires on %s", data.ExpirationDate.Format("Mon, 02 Jan 2006"))
+}
+
+func (data Builds) Render() types.SlackResponse {
+	title := ":hammer_and_wrench: Builds"
+	if data.VersionString != "" {
+		title = fmt.Sprintf(":hammer_and_wrench: Builds for %s", data.VersionString)
+	}
+
+	slackBlocks := []types.Block{
+		{
+			Type: "header",
+			Text: &types.Text{
+				Type:  "plain_text",
+				Text:  title,
+				Emoji: true,
+			},
+		},
+		{
+			Type: "divider",
+		},
+	}
+
+	if len(data.Builds) == 0 {
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: "There are no builds yet.",
+			},
+		})
+	}
+
+	for i, build := range data.Builds {
+		if len(slackBlocks)+2 > maxMessageBlocks {
+			slackBlocks = append(slackBlocks, types.Block{
+				Type: "context",
+				Elements: []types.Element{
+					{
+						Type: "mrkdwn",
+						Text: fmt.Sprintf("…and %d more builds, use `builds --version <version>` to narrow them down.", len(data.Builds)-i),
+					},
+				},
+			})
+			break
+		}
+
+		slackBlocks = append(slackBlocks,
+			types.Block{
+				Type: "section",
+				Text: &types.Text{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("❖ *%s (%s)* is `%s`, uploaded on *%s*", build.VersionString, build.BuildNumber, build.ProcessingState, build.UploadedDate.Format(time.RFC850)),
+				},
+			},
+			types.Block{
+				Type: "context",
+				Elements: []types.Element{
+					{
+						Type: "mrkdwn",
+						Text: fmt.Sprintf("It %s and is %s.", build.expiry(), build.attachments()),
+					},
+				},
+			},
+		)
+	}
+
+	slackBlocks = append(slackBlocks, types.Block{
+		Type: "divider",
+	})
+
+	return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
+}
+
+func (data BuildDetails) Render() types.SlackResponse {
+	betaGroups := "None"
+	if len(data.BetaGroups) > 0 {
+		betaGroups = strings.Join(data.BetaGroups, ", ")
+	}
+
+	appStoreVersion := "None"
+	if data.AppStoreVersion != "" {
+		appStoreVersion = data.AppStoreVersion
+	}
+
+	slackResponse := types.SlackResponse{
+		ResponseType: "in_channel",
+		Blocks: []types.Block{
+			{
+				Type: "header",
+				Text: &types.Text{
+					Type:  "plain_text",
+					Text:  fmt.Sprintf(":mag: Build %s (%s)", data.VersionString, data.BuildNumber),
+					Emoji: true,
+				},
+			},
+			{
+				Type: "divider",
+			},
+			{
+				Type: "section",
+				Fields: []types.Text{
+					{
+						Type: "mrkdwn",
+						Text: fmt.Sprintf("*Processing State:* `%s`", data.ProcessingState),
+					},
+					{
+						Type: "mrkdwn",
+						Text: fmt.Sprintf("*Minimum OS:* %s", data.MinOsVersion),
+					},
+					{
+						Type: "mrkdwn",
+						Text: fmt.Sprintf("*Uploaded:* %s", data.UploadedDate.Format(time.RFC850)),
+					},
+					{
+						Type: "mrkdwn",
+						Text: fmt.Sprintf("*Expiry:* %s", data.expiry()),
+					},
+					{
+						Type: "mrkdwn",
+						Text: fmt.Sprintf("*TestFlight Groups:* %s", betaGroups),
+					},
+					{
+						Type: "mrkdwn",
+						Text: fmt.Sprintf("*App Store Version:* %s", appStoreVersion),
+					},
+					{
+						Type: "mrkdwn",
+						Text: fmt.Sprintf("*ID:* %s :id:", data.Id),
+					},
+				},
+			},
+			{
+				Type: "divider",
+			},
+		},
+	}
+
+	return slackResponse
+}
+
+type BuildPicker struct {
+	VersionString string         `json:"version_string"`
+	ActionId      string         `json:"action_id"`
+	Builds        []BuildDetails `json:"builds"`
+}
+
+// Slack allows at most 100 options in a select menu.
+const maxSelectOptions = 100
+
+func (data BuildPicker) Render() types.SlackResponse {
+	slackBlocks := []types.Block{
+		{
+			Type: "header",
+			Text: &types.Text{
+				Type:  "plain_text",
+				Text:  fmt.Sprintf(":link: Attach a build to %s", data.VersionString),
+				Emoji: true,
+			},
+		},
+		{
+			Type: "divider",
+		},
+	}
+
+	if len(data.Builds) == 0 {
+		slackBlocks = append(slackBlocks,
+			types.Block{
+				Type: "section",
+				Text: &types.Text{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("There are no processed builds for *%s* yet.", data.VersionString),
+				},
+			},
+			types.Block{
+				Type: "divider",
+			},
+		)
+
+		return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
+	}
+
+	var options []types.Option
+	for _, build := range data.Builds {
+		if len(options) == maxSelectOptions {
+			break
+		}
+
+		options = append(options, types.Option{
+			Text: types.Text{
+				Type: "plain_text",
+				Text: fmt.Sprintf("%s (%s), uploaded on %s", build.VersionString, build.BuildNumber, build.UploadedDate.Format("Mon, 02 Jan 15:04")),
+			},
+			Value: build.Id,
+		})
+	}
+
+	slackBlocks = append(slackBlocks,
+		types.Block{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("Pick one of the %s ready for *%s*.", pluralize(len(data.Builds), "processed build"), data.VersionString),
+			},
+		},
+		types.Block{
+			Type: "actions",
+			Elements: []types.Element{
+				{
+					Type:     "static_select",
+					ActionId: data.ActionId,
+					Placeholder: &types.Text{
+						Type: "plain_text",
+						Text: "Select a build",
+					},
+					Options: options,
+				},
+			},
+		},
+		types.Block{
+			Type: "divider",
+		},
+	)
+
+	return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
+}
+
+const reviewGuidelinesUrl = "https://developer.apple.com/app-store/review/guidelines/"
+
+// Slack rejects section texts longer than 3000 characters.
+const maxSectionTextLength = 2900
+
+type ReviewItem struct {
+	Type          string `json:"type"`
+	State         string `json:"state"`
+	VersionString string `json:"version_string"`
+}
+
+type Rejection struct {
+	Code        string `json:"code"`
+	Section     string `json:"section"`
+	Description string `json:"description"`
+}
+
+type ReviewMessage struct {
+	Body        string      `json:"body"`
+	CreatedDate time.Time   `json:"created_date"`
+	Rejections  []Rejection `json:"rejections"`
+}
+
+type ReviewStatus struct {
+	AppId               string          `json:"app_id"`
+	VersionString       string          `json:"version_string"`
+	BuildNumber         string          `json:"build_number"`
+	StoreStatus         string          `json:"store_status"`
+	SubmissionId        string          `json:"submission_id"`
+	SubmissionState     string          `json:"submission_state"`
+	SubmittedDate       time.Time       `json:"submitted_date"`
+	Items               []ReviewItem    `json:"items"`
+	Messages            []ReviewMessage `json:"messages"`
+	MessagesUnavailable bool            `json:"messages_unavailable"`
+	Mention             string          `json:"mention"`
+}
+
+func (data ReviewStatus) Render() types.SlackResponse {
+	slackBlocks := []types.Block{
+		{
+			Type: "header",
+			Text: &types.Text{
+				Type:  "plain_text",
+				Text:  ":female-judge: App Review Status",
+				Emoji: true,
+			},
+		},
+		{
+			Type: "divider",
+		},
+	}
+
+	if data.Mention != "" {
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("%s App Review has rejected *%s (%s)*.", data.Mention, data.VersionString, data.BuildNumber),
+			},
+		})
+	}
+
+	summary := fmt.Sprintf("*%s (%s)* has the current status of `%s`.", data.VersionString, data.BuildNumber, data.StoreStatus)
+	if data.SubmissionId != "" {
+		summary += fmt.Sprintf("\nIt was submitted for review on *%s* and the submission is `%s`.", data.SubmittedDate.Format(time.RFC850), data.SubmissionState)
+	}
+
+	slackBlocks = append(slackBlocks, types.Block{
+		Type: "section",
+		Text: &types.Text{
+			Type: "mrkdwn",
+			Text: summary,
+		},
+	})
+
+	for _, item := range data.Items {
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "context",
+			Elements: []types.Element{
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("%s *%s* is `%s`", item.Type, item.VersionString, item.State),
+				},
+			},
+		})
+	}
+
+	for _, message := range data.Messages {
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "divider",
+		})
+
+		for _, rejection := range message.Rejections {
+			slackBlocks = append(slackBlocks, types.Block{
+				Type: "section",
+				Text: &types.Text{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf(":no_entry: <%s|Guideline %s> %s\n%s", reviewGuidelinesUrl, rejection.Section, rejection.Code, rejection.Description),
+				},
+			})
+		}
+
+		slackBlocks = append(slackBlocks,
+			types.Block{
+				Type: "section",
+				Text: &types.Text{
+					Type: "mrkdwn",
+					Text: truncate(message.Body, maxSectionTextLength),
+				},
+			},
+			types.Block{
+				Type: "context",
+				Elements: []types.Element{
+					{
+						Type: "mrkdwn",
+						Text: fmt.Sprintf("Resolution Center message from *%s*", message.CreatedDate.Format(time.RFC850)),
+					},
+				},
+			},
+		)
+	}
+
+	if data.MessagesUnavailable {
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "context",
+			Elements: []types.Element{
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf(":information_source: The rejection reasons can not be read through the App Store Connect API, find them in the Resolution Center of %s.", fmt.Sprintf(appStoreUrl, data.AppId)),
+				},
+			},
+		})
+	}
+
+	slackBlocks = append(slackBlocks,
+		types.Block{
+			Type: "divider",
+		},
+		types.Block{
+			Type: "context",
+			Elements: []types.Element{
+				{
+					Type:     "image",
+					ImageURL: appStoreIcon,
+					AltText:  "app store connect",
+				},
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf(appStoreUrl, data.AppId),
+				},
+			},
+		},
+	)
+
+	return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
+}
+
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+
+	return string(runes[:length]) + "…"
+}
+
+type CustomerReview struct {
+	Id               string    `json:"id"`
+	Rating           int       `json:"rating"`
+	Title            string    `json:"title"`
+	Body             string    `json:"body"`
+	ReviewerNickname string    `json:"reviewer_nickname"`
+	Territory        string    `json:"territory"`
+	CreatedDate      time.Time `json:"created_date"`
+	ResponseBody     string    `json:"response_body"`
+	ResponseState    string    `json:"response_state"`
+}
+
+type CustomerReviews struct {
+	Filter  string           `json:"filter"`
+	Reviews []CustomerReview `json:"reviews"`
+}
+
+type NewCustomerReview struct {
+	Review CustomerReview `json:"review"`
+}
+
+type ReviewReply struct {
+	UserId string `json:"user_id"`
+	Body   string `json:"body"`
+	State  string `json:"state"`
+}
+
+type ReviewReplyModal struct {
+	Metadata string `json:"metadata"`
+	BlockId  string `json:"block_id"`
+	ActionId string `json:"action_id"`
+}
+
+func stars(rating int) string {
+	if rating < 0 {
+		rating = 0
+	}
+	if rating > 5 {
+		rating = 5
+	}
+
+	return strings.Repeat("★", rating) + strings.Repeat("☆", 5-rating)
+}
+
+func (data CustomerReview) blocks() []types.Block {
+	slackBlocks := []types.Block{
+		{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: truncate(fmt.Sprintf("%s *%s*\n%s", stars(data.Rating), data.Title, data.Body), maxSectionTextLength),
+			},
+		},
+		{
+			Type: "context",
+			Elements: []types.Element{
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("by *%s* from *%s* on %s", data.ReviewerNickname, data.Territory, data.CreatedDate.Format("Mon, 02 Jan 2006")),
+				},
+			},
+		},
+	}
+
+	if data.ResponseBody != "" {
+		return append(slackBlocks, types.Block{
+			Type: "context",
+			Elements: []types.Element{
+				{
+					Type: "mrkdwn",
+					Text: truncate(fmt.Sprintf(":speech_balloon: Replied (`%s`): %s", data.ResponseState, data.ResponseBody), maxSectionTextLength),
+				},
+			},
+		})
+	}
+
+	return append(slackBlocks, types.Block{
+		Type: "actions",
+		Elements: []types.Element{
+			{
+				Type:     "button",
+				Text:     "Reply",
+				ActionId: "review_reply",
+				Value:    data.Id,
+			},
+		},
+	})
+}
+
+func (data CustomerReviews) Render() types.SlackResponse {
+	title := ":star: Customer Reviews"
+	if data.Filter != "" {
+		title = fmt.Sprintf(":star: Customer Reviews %s", data.Filter)
+	}
+
+	slackBlocks := []types.Block{
+		{
+			Type: "header",
+			Text: &types.Text{
+				Type:  "plain_text",
+				Text:  title,
+				Emoji: true,
+			},
+		},
+		{
+			Type: "divider",
+		},
+	}
+
+	if len(data.Reviews) == 0 {
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: "There are no customer reviews yet.",
+			},
+		})
+	}
+
+	for _, review := range data.Reviews {
+		slackBlocks = append(slackBlocks, review.blocks()...)
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "divider",
+		})
+	}
+
+	return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
+}
+
+func (data NewCustomerReview) Render() types.SlackResponse {
+	slackBlocks := []types.Block{
+		{
+			Type: "header",
+			Text: &types.Text{
+				Type:  "plain_text",
+				Text:  ":star: New Customer Review",
+				Emoji: true,
+			},
+		},
+	}
+
+	slackBlocks = append(slackBlocks, data.Review.blocks()...)
+
+	return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
+}
+
+func (data ReviewReply) Render() types.SlackResponse {
+	slackResponse := types.SlackResponse{
+		ResponseType: "in_channel",
+		Blocks: []types.Block{
+			{
+				Type: "section",
+				Text: &types.Text{
+					Type: "mrkdwn",
+					Text: truncate(fmt.Sprintf(":speech_balloon: <@%s> replied to a customer review (`%s`):\n>%s", data.UserId, data.State, data.Body), maxSectionTextLength),
+				},
+			},
+		},
+	}
+
+	return slackResponse
+}
+
+func (data ReviewReplyModal) View() types.SlackView {
+	return types.SlackView{
+		Type:            "modal",
+		CallbackId:      "review_reply",
+		PrivateMetadata: data.Metadata,
+		Title: &types.Text{
+			Type: "plain_text",
+			Text: "Reply to Review",
+		},
+		Submit: &types.Text{
+			Type: "plain_text",
+			Text: "Reply",
+		},
+		Close: &types.Text{
+			Type: "plain_text",
+			Text: "Cancel",
+		},
+		Blocks: []types.Block{
+			{
+				Type:    "input",
+				BlockId: data.BlockId,
+				Label: &types.Text{
+					Type: "plain_text",
+					Text: "Response",
+				},
+				Element: &types.Element{
+					Type:      "plain_text_input",
+					ActionId:  data.ActionId,
+					Multiline: true,
+					MaxLength: 5970,
+				},
+			},
+		},
+	}
+}
+
+type RatingRow struct {
+	Territory     string  `json:"territory"`
+	AverageRating float64 `json:"average_rating"`
+	ReviewCount   int64   `json:"review_count"`
+	HasPrevious   bool    `json:"has_previous"`
+	AverageDelta  float64 `json:"average_delta"`
+	CountDelta    int64   `json:"count_delta"`
+}
+
+type RatingPoint struct {
+	Date          time.Time `json:"date"`
+	AverageRating float64   `json:"average_rating"`
+	ReviewCount   int64     `json:"review_count"`
+}
+
+type Ratings struct {
+	CapturedOn  time.Time     `json:"captured_on"`
+	Overall     RatingRow     `json:"overall"`
+	Territories []RatingRow   `json:"territories"`
+	History     []RatingPoint `json:"history"`
+	LiveVersion string        `json:"live_version"`
+	LiveSince   time.Time     `json:"live_since"`
+	Before      RatingPoint   `json:"before"`
+	Since       RatingPoint   `json:"since"`
+}
+
+func (data RatingRow) tableLine() string {
+	averageDelta, countDelta := "—", "—"
+	if data.HasPrevious {
+		averageDelta = fmt.Sprintf("%+.2f", data.AverageDelta)
+		countDelta = fmt.Sprintf("%+d", data.CountDelta)
+	}
+
+	return fmt.Sprintf("%-9s %5.2f %7s %7d %7s", data.Territory, data.AverageRating, averageDelta, data.ReviewCount, countDelta)
+}
+
+func (data Ratings) Render() types.SlackResponse {
+	slackBlocks := []types.Block{
+		{
+			Type: "header",
+			Text: &types.Text{
+				Type:  "plain_text",
+				Text:  ":chart_with_upwards_trend: Ratings",
+				Emoji: true,
+			},
+		},
+		{
+			Type: "divider",
+		},
+		{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("%s *%.2f* average from *%d* reviews", stars(int(data.Overall.AverageRating+0.5)), data.Overall.AverageRating, data.Overall.ReviewCount),
+			},
+		},
+	}
+
+	table := []string{fmt.Sprintf("%-9s %5s %7s %7s %7s", "Territory", "Avg", "Δ WoW", "Reviews", "Δ WoW")}
+	for _, row := range data.Territories {
+		table = append(table, row.tableLine())
+	}
+	table = append(table, data.Overall.tableLine())
+
+	slackBlocks = append(slackBlocks, types.Block{
+		Type: "section",
+		Text: &types.Text{
+			Type: "mrkdwn",
+			Text: fmt.Sprintf("```%s```", strings.Join(table, "\n")),
+		},
+	})
+
+	if len(data.History) > 0 {
+		var history []string
+		for _, point := range data.History {
+			history = append(history, fmt.Sprintf("• Week of %s: *%.2f* from %d reviews", point.Date.Format("02 Jan"), point.AverageRating, point.ReviewCount))
+		}
+
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: "*Over Time*\n" + strings.Join(history, "\n"),
+			},
+		})
+	}
+
+	if data.LiveVersion != "" {
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf(":iphone: Since *%s* went live on %s, reviews average *%.2f* from %d reviews, against *%.2f* from %d reviews before it.",
+					data.LiveVersion, data.LiveSince.Format("Mon, 02 Jan 2006"), data.Since.AverageRating, data.Since.ReviewCount, data.Before.AverageRating, data.Before.ReviewCount),
+			},
+		})
+	}
+
+	slackBlocks = append(slackBlocks,
+		types.Block{
+			Type: "context",
+			Elements: []types.Element{
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("Computed from the customer reviews seen up to %s", data.CapturedOn.Format("Mon, 02 Jan 2006")),
+				},
+			},
+		},
+		types.Block{
+			Type: "divider",
+		},
+	)
+
+	return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
+}
+
+// Slack rejects messages with more than 50 blocks, leave room for the footer.
+const maxMessageBlocks = 46
+
+type FieldDiff struct {
+	Name string `json:"name"`
+	Diff string `json:"diff"`
+}
+
+type LocaleDiff struct {
+	Locale string      `json:"locale"`
+	Status string      `json:"status"`
+	Fields []FieldDiff `json:"fields"`
+}
+
+type MetadataDiff struct {
+	LiveVersion     string       `json:"live_version"`
+	InflightVersion string       `json:"inflight_version"`
+	Locales         []LocaleDiff `json:"locales"`
+}
+
+func (data MetadataDiff) Render() types.SlackResponse {
+	slackBlocks := []types.Block{
+		{
+			Type: "header",
+			Text: &types.Text{
+				Type:  "plain_text",
+				Text:  fmt.Sprintf(":memo: Metadata Changes %s → %s", data.LiveVersion, data.InflightVersion),
+				Emoji: true,
+			},
+		},
+		{
+			Type: "divider",
+		},
+	}
+
+	if len(data.Locales) == 0 {
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: "The copy of the inflight release is the same as the live release.",
+			},
+		})
+	}
+
+	for i, locale := range data.Locales {
+		localeBlocks := []types.Block{
+			{
+				Type: "section",
+				Text: &types.Text{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf(":globe_with_meridians: *%s* (%s)", locale.Locale, locale.Status),
+				},
+			},
+		}
+
+		for _, field := range locale.Fields {
+			localeBlocks = append(localeBlocks, types.Block{
+				Type: "section",
+				Text: &types.Text{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("*%s*\n```%s```", field.Name, truncate(field.Diff, maxSectionTextLength-len(field.Name)-10)),
+				},
+			})
+		}
+
+		if len(slackBlocks)+len(localeBlocks) > maxMessageBlocks {
+			slackBlocks = append(slackBlocks, types.Block{
+				Type: "context",
+				Elements: []types.Element{
+					{
+						Type: "mrkdwn",
+						Text: fmt.Sprintf("…and %d more locales, use `metadata --locale <locale>` to see them.", len(data.Locales)-i),
+					},
+				},
+			})
+			break
+		}
+
+		slackBlocks = append(slackBlocks, localeBlocks...)
+	}
+
+	slackBlocks = append(slackBlocks, types.Block{
+		Type: "divider",
+	})
+
+	return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
+}
+
+type SalesAmount struct {
+	Currency       string  `json:"currency"`
+	Amount         float64 `json:"amount"`
+	PreviousAmount float64 `json:"previous_amount"`
+}
+
+type SalesGroup struct {
+	Name  string `json:"name"`
+	Units int    `json:"units"`
+}
+
+type SalesReport struct {
+	From              time.Time     `json:"from"`
+	To                time.Time     `json:"to"`
+	Downloads         int           `json:"downloads"`
+	Updates           int           `json:"updates"`
+	Redownloads       int           `json:"redownloads"`
+	InAppUnits        int           `json:"in_app_units"`
+	PreviousDownloads int           `json:"previous_downloads"`
+	HasPrevious       bool          `json:"has_previous"`
+	Proceeds          []SalesAmount `json:"proceeds"`
+	Versions          []SalesGroup  `json:"versions"`
+	Territories       []SalesGroup  `json:"territories"`
+	MissingDays       int           `json:"missing_days"`
+}
+
+func percentageChange(current float64, previous float64) string {
+	if previous == 0 {
+		return "—"
+	}
+
+	return fmt.Sprintf("%+.0f%%", (current-previous)/previous*100)
+}
+
+func salesGroupLines(groups []SalesGroup) string {
+	var lines []string
+	for _, group := range groups {
+		lines = append(lines, fmt.Sprintf("• *%s*: %d", group.Name, group.Units))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (data SalesReport) Render() types.SlackResponse {
+	days := int(data.To.Sub(data.From).Hours()/24) + 1
+
+	downloads := fmt.Sprintf("*Downloads:* %d", data.Downloads)
+	if data.HasPrevious {
+		downloads += fmt.Sprintf(" (%s vs the previous %s)", percentageChange(float64(data.Downloads), float64(data.PreviousDownloads)), pluralize(days, "day"))
+	}
+
+	slackBlocks := []types.Block{
+		{
+			Type: "header",
+			Text: &types.Text{
+				Type:  "plain_text",
+				Text:  ":moneybag: Sales",
+				Emoji: true,
+			},
+		},
+		{
+			Type: "divider",
+		},
+		{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("From *%s* to *%s*", data.From.Format("Mon, 02 Jan 2006"), data.To.Format("Mon, 02 Jan 2006")),
+			},
+		},
+		{
+			Type: "section",
+			Fields: []types.Text{
+				{
+					Type: "mrkdwn",
+					Text: downloads,
+				},
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("*Updates:* %d", data.Updates),
+				},
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("*Redownloads:* %d", data.Redownloads),
+				},
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf("*In-App Purchases:* %d", data.InAppUnits),
+				},
+			},
+		},
+	}
+
+	if len(data.Proceeds) > 0 {
+		var proceeds []string
+		for _, amount := range data.Proceeds {
+			line := fmt.Sprintf("• *%.2f %s*", amount.Amount, amount.Currency)
+			if data.HasPrevious {
+				line += fmt.Sprintf(" (%s)", percentageChange(amount.Amount, amount.PreviousAmount))
+			}
+			proceeds = append(proceeds, line)
+		}
+
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "section",
+			Text: &types.Text{
+				Type: "mrkdwn",
+				Text: truncate("*Proceeds*\n"+strings.Join(proceeds, "\n"), maxSectionTextLength),
+			},
+		})
+	}
+
+	if len(data.Versions) > 0 || len(data.Territories) > 0 {
+		fields := []types.Text{}
+		if len(data.Versions) > 0 {
+			fields = append(fields, types.Text{
+				Type: "mrkdwn",
+				Text: "*Units by Version*\n" + salesGroupLines(data.Versions),
+			})
+		}
+		if len(data.Territories) > 0 {
+			fields = append(fields, types.Text{
+				Type: "mrkdwn",
+				Text: "*Units by Territory*\n" + salesGroupLines(data.Territories),
+			})
+		}
+
+		slackBlocks = append(slackBlocks, types.Block{
+			Type:   "section",
+			Fields: fields,
+		})
+	}
+
+	if data.MissingDays > 0 {
+		slackBlocks = append(slackBlocks, types.Block{
+			Type: "context",
+			Elements: []types.Element{
+				{
+					Type: "mrkdwn",
+					Text: fmt.Sprintf(":warning: The reports of %s are not available yet and were left out.", pluralize(data.MissingDays, "day")),
+				},
+			},
+		})
+	}
+
+	slackBlocks = append(slackBlocks, types.Block{
+		Type: "divider",
+	})
+
+	return types.SlackResponse{Blocks: slackBlocks, ResponseType: "in_channel"}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	slack "ciderbot/slack"
+	"ciderbot/types"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testUser() *types.User {
+	user := &types.User{Email: "owner@example.com", Platform: iosPlatform}
+	user.SlackTeamID.String, user.SlackTeamID.Valid = "T0001", true
+	user.AppStoreBundleID.String, user.AppStoreBundleID.Valid = "com.example.ciderbot", true
+	return user
+}
+
+func testForm(text string) types.SlackFormData {
+	return types.SlackFormData{Text: text, TeamId: "T0001", ChannelId: "C0001", UserId: "U0001"}
+}
+
+// responseText is the response as Slack gets it, to look for what it says.
+func responseText(t *testing.T, response types.SlackResponse) string {
+	t.Helper()
+
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("could not encode the response: %s", err)
+	}
+
+	return string(encoded)
+}
+
+func TestCommandsGoThroughTheStore(t *testing.T) {
+	tests := []struct {
+		text  string
+		call  string
+		wants []string
+	}{
+		{"app_info", "AppMetadata", []string{"Ciderbot Demo"}},
+		{"beta_groups", "BetaGroups", []string{"Public Beta"}},
+		{"overall_status", "CurrentStatus", []string{"1.1.0", "1.2.0"}},
+		{"inflight_release", "InflightRelease", []string{"1.2.0"}},
+		{"live_release", "LiveRelease", []string{"1.1.0"}},
+		{"pause_live_release", "PauseRollout", []string{"1.1.0"}},
+		{"release_to_all", "CompleteRollout", []string{"1.1.0"}},
+		{"builds --version 1.2.0 --limit 2", "Builds", []string{"121", "120"}},
+		{"build 110", "Build", []string{"1.1.0"}},
+		{"attach_build 121", "AttachBuild", []string{"121"}},
+		{"release_type manual", "UpdateInflightRelease", []string{"1.2.0"}},
+		{"phased_release on", "UpdateInflightRelease", []string{"1.2.0"}},
+		{"metadata", "InflightLocalizations", []string{"press"}},
+		{"review_status", "ReviewSubmission", []string{"1.2.0"}},
+		{"reviews --rating<=2", "CustomerReviews", []string{"Crashes on launch"}},
+		{"digest", "CurrentStatus", []string{"Ciderbot Demo"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			store := newMemoryStore()
+			command := strings.Split(test.text, " ")[0]
+
+			text := responseText(t, processValidSlackCommand(context.Background(), command, testForm(test.text), testUser(), newMemoryRepositories(), store))
+
+			if !store.called(test.call) {
+				t.Errorf("%s did not call %s, called %v", test.text, test.call, store.calls)
+			}
+			if strings.Contains(text, "Could not") {
+				t.Errorf("%s failed: %s", test.text, text)
+			}
+			for _, want := range test.wants {
+				if !strings.Contains(text, want) {
+					t.Errorf("%s response has no %q: %s", test.text, want, text)
+				}
+			}
+		})
+	}
+}
+
+func TestCommandReportsStoreFailure(t *testing.T) {
+	store := newMemoryStore()
+	store.err = statusError{service: "memory", statusCode: http.StatusServiceUnavailable}
+
+	for _, text := range []string{"builds", "metadata", "create_version 1.3.0", "phased_release on", "reviews"} {
+		command := strings.Split(text, " ")[0]
+		response := responseText(t, processValidSlackCommand(context.Background(), command, testForm(text), testUser(), newMemoryRepositories(), store))
+		if !strings.Contains(response, "Could not") {
+			t.Errorf("%s did not report the failure: %s", text, response)
+		}
+	}
+}
+
+func TestAttachBuildPickerOffersProcessedBuilds(t *testing.T) {
+	store := newMemoryStore()
+
+	text := responseText(t, processValidSlackCommand(context.Background(), "attach_build", testForm("attach_build"), testUser(), newMemoryRepositories(), store))
+
+	if !strings.Contains(text, "121") {
+		t.Errorf("the picker does not offer the processed build 121: %s", text)
+	}
+	if strings.Contains(text, "119") {
+		t.Errorf("the picker offers the build 119 that is still processing: %s", text)
+	}
+}
+
+func TestAttachBuildFindsTheNumberAmongTheInflightBuilds(t *testing.T) {
+	store := newMemoryStore()
+	store.builds = append([]types.Build{
+		{Id: "build-1.1.0-1", BuildNumber: "1", VersionString: "1.1.0", ProcessingState: "VALID"},
+		{Id: "build-1.2.0-1", BuildNumber: "1", VersionString: "1.2.0", ProcessingState: "VALID"},
+	}, store.builds...)
+
+	// Both versions have a build 1, only the one of the inflight 1.2.0 can be meant
+	text := responseText(t, processValidSlackCommand(context.Background(), "attach_build", testForm("attach_build 1"), testUser(), newMemoryRepositories(), store))
+	if store.inflightRelease.BuildId != "build-1.2.0-1" {
+		t.Errorf("attached %s: %s", store.inflightRelease.BuildId, text)
+	}
+}
+
+func TestCreateVersionNeedsNoInflightVersion(t *testing.T) {
+	store := newMemoryStore()
+
+	text := responseText(t, processValidSlackCommand(context.Background(), "create_version", testForm("create_version 1.3.0"), testUser(), newMemoryRepositories(), store))
+	if !strings.Contains(text, "already inflight") || store.called("CreateVersion") {
+		t.Fatalf("a version was created while 1.2.0 is inflight: %s", text)
+	}
+
+	store.inflightRelease.AppStoreState = "READY_FOR_SALE"
+	text = responseText(t, processValidSlackCommand(context.Background(), "create_version", testForm("create_version 1.3.0 --phased"), testUser(), newMemoryRepositories(), store))
+	if !store.called("CreateVersion") || !strings.Contains(text, "1.3.0") {
+		t.Fatalf("the version was not created: %s", text)
+	}
+	if store.inflightRelease.PhasedRelease.Id == "" {
+		t.Errorf("the version was created without a phased release")
+	}
+}
+
+func TestReleaseNowActionReleasesTheApprovedVersion(t *testing.T) {
+	store := newMemoryStore()
+	store.inflightRelease.AppStoreState = pendingDeveloperReleaseState
+
+	interaction := types.SlackInteraction{}
+	interaction.User.Id = "U0001"
+	action := types.SlackAction{ActionId: "release_now", Value: "1.2.0"}
+
+	text := responseText(t, handleReleaseNowAction(context.Background(), interaction, action, testUser(), newMemoryRepositories(), store))
+
+	if !store.called("ReleaseInflight") {
+		t.Fatalf("the version was not released: %s", text)
+	}
+	if store.liveRelease.VersionName != "1.2.0" {
+		t.Errorf("the live version is %s, want 1.2.0", store.liveRelease.VersionName)
+	}
+}
+
+func TestReleaseNowActionIgnoresStaleConfirmation(t *testing.T) {
+	store := newMemoryStore()
+
+	action := types.SlackAction{ActionId: "release_now", Value: "1.2.0"}
+	text := responseText(t, handleReleaseNowAction(context.Background(), types.SlackInteraction{}, action, testUser(), newMemoryRepositories(), store))
+
+	if store.called("ReleaseInflight") || !strings.Contains(text, "no longer waiting") {
+		t.Fatalf("a version that is not approved was released: %s", text)
+	}
+}
+
+func TestGuardrailUndoActionGoesThroughTheStore(t *testing.T) {
+	store := newMemoryStore()
+	store.liveRelease.PhasedRelease.PhasedReleaseState = "PAUSED"
+
+	action := types.SlackAction{ActionId: "guardrail_undo", Value: "resume"}
+	handleGuardrailUndoAction(context.Background(), types.SlackInteraction{}, action, testUser(), newMemoryRepositories(), store)
+
+	if store.liveRelease.PhasedRelease.PhasedReleaseState != "ACTIVE" {
+		t.Errorf("the phased release is %s, want ACTIVE", store.liveRelease.PhasedRelease.PhasedReleaseState)
+	}
+}
+
+func TestBuildsMessageStaysUnderTheBlockLimit(t *testing.T) {
+	builds := slack.Builds{}
+	for i := 0; i < 30; i++ {
+		builds.Builds = append(builds.Builds, slack.BuildDetails{VersionString: "1.2.0", BuildNumber: strconv.Itoa(100 + i)})
+	}
+
+	response := builds.Render()
+	if len(response.Blocks) > 50 {
+		t.Errorf("the builds message has %d blocks, Slack takes at most 50", len(response.Blocks))
+	}
+	if text := responseText(t, response); !strings.Contains(text, "more builds") {
+		t.Errorf("the builds message does not say that builds were left out: %s", text)
+	}
+}
+
+func TestCreateVersionSchedulesTheRelease(t *testing.T) {
+	store := newMemoryStore()
+	store.inflightRelease.AppStoreState = "READY_FOR_SALE"
+
+	text := responseText(t, processValidSlackCommand(context.Background(), "create_version", testForm("create_version 1.3.0 --release-type scheduled"), testUser(), newMemoryRepositories(), store))
+	if store.called("CreateVersion") || !strings.Contains(text, "--release-date") {
+		t.Fatalf("a scheduled version was created without a date: %s", text)
+	}
+
+	text = responseText(t, processValidSlackCommand(context.Background(), "create_version", testForm(`create_version 1.3.0 --release-type scheduled --release-date "2099-06-01 10:00 UTC"`), testUser(), newMemoryRepositories(), store))
+	releaseDate := store.inflightRelease.EarliestReleaseDate
+	if releaseDate == nil || !releaseDate.Equal(time.Date(2099, 6, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("the version was created with the release date %v: %s", releaseDate, text)
+	}
+}
+
+func TestAttachBuildActionAttachesThePickedBuildByID(t *testing.T) {
+	store := newMemoryStore()
+
+	text := responseText(t, processValidSlackCommand(context.Background(), "attach_build", testForm("attach_build"), testUser(), newMemoryRepositories(), store))
+	if !strings.Contains(text, `"value":"build-121"`) {
+		t.Fatalf("the picker does not offer the build 121 by id: %s", text)
+	}
+
+	action := types.SlackAction{ActionId: "attach_build", SelectedOption: &types.Option{Value: "build-121"}}
+	text = responseText(t, handleAttachBuildAction(context.Background(), types.SlackInteraction{}, action, testUser(), newMemoryRepositories(), store))
+
+	if !store.called("AttachBuild") || store.inflightRelease.BuildId != "build-121" {
+		t.Errorf("the picked build was not attached: %s", text)
+	}
+}
+
+func TestReleasesAreScheduledOnTheHourInUTC(t *testing.T) {
+	if _, err := parseFutureReleaseDate("2099-06-01 10:00 IST"); err == nil {
+		t.Errorf("10:00 IST, which is 04:30 UTC, was taken as on the hour")
+	}
+	if _, err := parseFutureReleaseDate("2099-06-01 10:30 IST"); err != nil {
+		t.Errorf("10:30 IST, which is 05:00 UTC, was refused: %s", err)
+	}
+}
+
+func TestReleaseNowActionReleasesOnce(t *testing.T) {
+	store := newMemoryStore()
+	store.inflightRelease.AppStoreState = pendingDeveloperReleaseState
+	repos := newMemoryRepositories()
+
+	interaction := types.SlackInteraction{}
+	interaction.Team.Id = "T0001"
+	action := types.SlackAction{ActionId: "release_now", Value: "1.2.0"}
+
+	// The second click got the version before it was released
+	claimed := types.ReleaseRequest{SlackTeamID: "T0001", VersionString: "1.2.0"}
+	repos.Releases.Claim(&claimed)
+
+	text := responseText(t, handleReleaseNowAction(context.Background(), interaction, action, testUser(), repos, store))
+	if store.called("ReleaseInflight") || !strings.Contains(text, "already being released") {
+		t.Fatalf("a version was released twice: %s", text)
+	}
+
+	repos.Releases.Unclaim(&claimed)
+	handleReleaseNowAction(context.Background(), interaction, action, testUser(), repos, store)
+	if !store.called("ReleaseInflight") {
+		t.Errorf("the version was not released once the claim was gone")
+	}
+}
+
+func TestReleaseTrackerWaitsForTheVersionToGoLive(t *testing.T) {
+	store := newMemoryStore()
+	repos := newMemoryRepositories()
+	connectTestWorkspace(t, repos)
+	stores := func(user *types.User) StoreClient { return store }
+
+	released := store.liveRelease
+	released.VersionName = "1.2.0"
+	if err := trackRelease(repos.Jobs, "T0001", "C0001", released); err != nil {
+		t.Fatalf("could not track the release: %s", err)
+	}
+
+	runTracker := func() *types.ScheduledJob {
+		t.Helper()
+
+		job, err := repos.Jobs.Find("release_tracker", "T0001", "C0001")
+		if err != nil || job == nil {
+			t.Fatalf("there is no release tracker (%v)", err)
+		}
+		if err := runReleaseTrackerJob(context.Background(), job, repos, stores); err != nil {
+			t.Fatalf("the release tracker failed: %s", err)
+		}
+
+		job, _ = repos.Jobs.Find("release_tracker", "T0001", "C0001")
+		return job
+	}
+
+	// 1.1.0 is still live
+	if job := runTracker(); job == nil || strings.Contains(job.State, `"seen_live":true`) {
+		t.Fatalf("the tracker stopped before 1.2.0 went live: %+v", job)
+	}
+
+	store.liveRelease.VersionName = "1.2.0"
+	if job := runTracker(); job == nil || !strings.Contains(job.State, `"seen_live":true`) {
+		t.Fatalf("the tracker did not see 1.2.0 go live: %+v", job)
+	}
+
+	store.liveRelease.VersionName = "1.3.0"
+	if job := runTracker(); job != nil {
+		t.Errorf("the tracker kept going once 1.3.0 went live")
+	}
+}
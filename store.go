@@ -0,0 +1,210 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	iosPlatform     = "ios"
+	androidPlatform = "android"
+)
+
+var errNotSupported = errors.New("store: not supported for this platform")
+
+// statusError is returned when a store responds with an unsuccessful status.
+type statusError struct {
+	service    string
+	statusCode int
+}
+
+func (err statusError) Error() string {
+	return fmt.Sprintf("%s: request failed with status - %d", err.service, err.statusCode)
+}
+
+// isTransientError tells whether a failed store request is worth retrying:
+// the store was unreachable, overloaded or rate limited us.
+func isTransientError(err error) bool {
+	var requestStatusError statusError
+	if errors.As(err, &requestStatusError) {
+		return requestStatusError.statusCode == http.StatusTooManyRequests || requestStatusError.statusCode >= http.StatusInternalServerError
+	}
+
+	var netError net.Error
+	return errors.As(err, &netError)
+}
+
+// StoreClient is what the commands need from a store. Handlers receive one
+// instead of calling a backend directly, so that the same commands work for
+// an App Store app, a Google Play app or the in-memory store used in tests.
+// A store returns errNotSupported for what its platform does not have.
+type StoreClient interface {
+	Platform() string
+	AppMetadata(ctx context.Context) (types.AppMetadata, error)
+	CurrentStatus(ctx context.Context) ([]types.AppCurrentStatus, error)
+	BetaGroups(ctx context.Context) ([]types.BetaGroup, error)
+	InflightRelease(ctx context.Context) (types.Release, error)
+	LiveRelease(ctx context.Context) (types.Release, error)
+	PauseRollout(ctx context.Context) (types.Release, error)
+	ResumeRollout(ctx context.Context) (types.Release, error)
+	CompleteRollout(ctx context.Context) (types.Release, error)
+	Builds(ctx context.Context, versionString string, limit int) ([]types.Build, error)
+	Build(ctx context.Context, buildNumber string) (types.Build, error)
+	AttachBuild(ctx context.Context, buildID string) (types.Release, error)
+	CreateVersion(ctx context.Context, versionString string, releaseType string, earliestReleaseDate *time.Time, phasedRelease bool) (types.Release, error)
+	UpdateInflightRelease(ctx context.Context, changes map[string]interface{}) (types.Release, error)
+	ReleaseInflight(ctx context.Context) (types.Release, error)
+	InflightLocalizations(ctx context.Context) ([]types.Localization, error)
+	LiveLocalizations(ctx context.Context) ([]types.Localization, error)
+	ReviewSubmission(ctx context.Context) (types.ReviewSubmission, error)
+	ResolutionCenterMessages(ctx context.Context) ([]types.ResolutionCenterMessage, error)
+	CustomerReviews(ctx context.Context, territory string, limit int) ([]types.CustomerReview, error)
+	ReplyToReview(ctx context.Context, reviewID string, responseBody string) (types.CustomerReviewResponse, error)
+	SalesReport(ctx context.Context, vendorNumber string, reportDate time.Time) ([]byte, error)
+}
+
+// StoreFactory hands out the store client for the app a user has selected.
+type StoreFactory func(user *types.User) StoreClient
+
+// appStore hands out the App Store client of the user, whichever platform
+// they have selected since. Scheduled jobs and Slack actions only exist for
+// App Store apps and must not follow a switch to Google Play.
+func (stores StoreFactory) appStore(user *types.User) StoreClient {
+	appStoreUser := *user
+	appStoreUser.Platform = iosPlatform
+
+	return stores(&appStoreUser)
+}
+
+// newStoreFactory picks the store backend of the deployment: applelink (the
+// default), or appstoreconnect to call Apple directly without running applelink.
+func newStoreFactory(backend string) (StoreFactory, error) {
+	switch backend {
+	case "", "applelink":
+		return storeClientFor, nil
+	case "appstoreconnect":
+		return func(user *types.User) StoreClient {
+			if user.Platform == androidPlatform {
+				return newPlayStore(userPlayCredentials(user))
+			}
+
+			return newAppStoreConnectStore(userAppleCredentials(user))
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown store backend %s", backend)
+	}
+}
+
+type applelinkStore struct {
+	credentials *types.AppleCredentials
+}
+
+func (store applelinkStore) Platform() string {
+	return iosPlatform
+}
+
+func (store applelinkStore) AppMetadata(ctx context.Context) (types.AppMetadata, error) {
+	return getAppMetadata(ctx, store.credentials)
+}
+
+func (store applelinkStore) CurrentStatus(ctx context.Context) ([]types.AppCurrentStatus, error) {
+	return getAppCurrentStatus(ctx, store.credentials)
+}
+
+func (store applelinkStore) BetaGroups(ctx context.Context) ([]types.BetaGroup, error) {
+	return getBetaGroups(ctx, store.credentials)
+}
+
+func (store applelinkStore) InflightRelease(ctx context.Context) (types.Release, error) {
+	return getInflightRelease(ctx, store.credentials)
+}
+
+func (store applelinkStore) LiveRelease(ctx context.Context) (types.Release, error) {
+	return getLiveRelease(ctx, store.credentials)
+}
+
+func (store applelinkStore) PauseRollout(ctx context.Context) (types.Release, error) {
+	return pauseLiveRelease(ctx, store.credentials)
+}
+
+func (store applelinkStore) ResumeRollout(ctx context.Context) (types.Release, error) {
+	return resumeLiveRelease(ctx, store.credentials)
+}
+
+func (store applelinkStore) CompleteRollout(ctx context.Context) (types.Release, error) {
+	return releaseToAll(ctx, store.credentials)
+}
+
+func (store applelinkStore) Builds(ctx context.Context, versionString string, limit int) ([]types.Build, error) {
+	return getBuilds(ctx, store.credentials, versionString, limit)
+}
+
+func (store applelinkStore) Build(ctx context.Context, buildNumber string) (types.Build, error) {
+	return getBuild(ctx, store.credentials, buildNumber)
+}
+
+func (store applelinkStore) AttachBuild(ctx context.Context, buildID string) (types.Release, error) {
+	return attachBuild(ctx, store.credentials, buildID)
+}
+
+func (store applelinkStore) CreateVersion(ctx context.Context, versionString string, releaseType string, earliestReleaseDate *time.Time, phasedRelease bool) (types.Release, error) {
+	return createVersion(ctx, store.credentials, versionString, releaseType, earliestReleaseDate, phasedRelease)
+}
+
+func (store applelinkStore) UpdateInflightRelease(ctx context.Context, changes map[string]interface{}) (types.Release, error) {
+	return updateInflightRelease(ctx, store.credentials, changes)
+}
+
+func (store applelinkStore) ReleaseInflight(ctx context.Context) (types.Release, error) {
+	return releaseInflightVersion(ctx, store.credentials)
+}
+
+func (store applelinkStore) InflightLocalizations(ctx context.Context) ([]types.Localization, error) {
+	return getInflightLocalizations(ctx, store.credentials)
+}
+
+func (store applelinkStore) LiveLocalizations(ctx context.Context) ([]types.Localization, error) {
+	return getLiveLocalizations(ctx, store.credentials)
+}
+
+func (store applelinkStore) ReviewSubmission(ctx context.Context) (types.ReviewSubmission, error) {
+	return getReviewSubmission(ctx, store.credentials)
+}
+
+func (store applelinkStore) ResolutionCenterMessages(ctx context.Context) ([]types.ResolutionCenterMessage, error) {
+	return getResolutionCenterMessages(ctx, store.credentials)
+}
+
+func (store applelinkStore) CustomerReviews(ctx context.Context, territory string, limit int) ([]types.CustomerReview, error) {
+	return getCustomerReviews(ctx, store.credentials, territory, limit)
+}
+
+func (store applelinkStore) ReplyToReview(ctx context.Context, reviewID string, responseBody string) (types.CustomerReviewResponse, error) {
+	return replyToCustomerReview(ctx, store.credentials, reviewID, responseBody)
+}
+
+func (store applelinkStore) SalesReport(ctx context.Context, vendorNumber string, reportDate time.Time) ([]byte, error) {
+	return getSalesReport(ctx, store.credentials, vendorNumber, reportDate)
+}
+
+// storeClientFor picks the store of the platform the user has selected.
+func storeClientFor(user *types.User) StoreClient {
+	if user.Platform == androidPlatform {
+		return newPlayStore(userPlayCredentials(user))
+	}
+
+	return applelinkStore{credentials: userAppleCredentials(user)}
+}
+
+func platformConnected(user *types.User, platform string) bool {
+	if platform == androidPlatform {
+		return user.PlayConnected && user.PlayPackageName.Valid
+	}
+
+	return user.AppStoreBundleID.Valid
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const serviceName = "ciderbot"
+
+// tracer hands out no-op spans until initTracing installs a provider.
+var tracer = otel.Tracer(serviceName)
+
+// applelinkClient traces every applelink call and passes the trace on to
+// applelink in the traceparent header.
+var applelinkClient = &http.Client{
+	Transport: otelhttp.NewTransport(http.DefaultTransport,
+		otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+			return "applelink " + req.Method + " " + applelinkEndpoint(req.URL.String())
+		}),
+	),
+}
+
+// initTracing exports spans to the OTLP endpoint over HTTP. Tracing stays off
+// without an endpoint.
+func initTracing(endpoint string) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	return setTracerProvider(exporter).Shutdown, nil
+}
+
+// setTracerProvider sends the spans to the given exporter, the OTLP one or an
+// in-memory one when testing. The provider flushes them on Shutdown.
+func setTracerProvider(exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider
+}
+
+// traceParent is the W3C trace context of ctx, to be stored with queued work.
+func traceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// withTraceParent continues the trace a queued command was started in.
+func withTraceParent(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+
+	carrier := propagation.MapCarrier{"traceparent": traceparent}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
+
+// endSpan records the error, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+func commandAttributes(command string, jobID uint, attempt int) trace.SpanStartOption {
+	return trace.WithAttributes(
+		attribute.String("ciderbot.command", command),
+		attribute.Int("ciderbot.job_id", int(jobID)),
+		attribute.Int("ciderbot.attempt", attempt),
+	)
+}
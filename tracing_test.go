@@ -0,0 +1,122 @@
+package main
+
+import (
+	"ciderbot/types"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	spanExporter     = tracetest.NewInMemoryExporter()
+	spanProvider     *sdktrace.TracerProvider
+	spanProviderOnce sync.Once
+)
+
+// recordSpans installs a provider exporting to memory. The tracers handed out
+// before stick to the first provider, so every test shares it.
+func recordSpans(t *testing.T) func() tracetest.SpanStubs {
+	t.Helper()
+
+	spanProviderOnce.Do(func() { spanProvider = setTracerProvider(spanExporter) })
+	spanExporter.Reset()
+
+	return func() tracetest.SpanStubs {
+		t.Helper()
+
+		// Spans are exported in batches
+		if err := spanProvider.ForceFlush(context.Background()); err != nil {
+			t.Fatalf("could not flush the spans: %s", err)
+		}
+		return spanExporter.GetSpans()
+	}
+}
+
+func findSpan(t *testing.T, spans tracetest.SpanStubs, match func(span tracetest.SpanStub) bool, description string) tracetest.SpanStub {
+	t.Helper()
+
+	for _, span := range spans {
+		if match(span) {
+			return span
+		}
+	}
+
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	t.Fatalf("there is no %s span among %v", description, names)
+	return tracetest.SpanStub{}
+}
+
+func assertChildOf(t *testing.T, child tracetest.SpanStub, parent tracetest.SpanStub) {
+	t.Helper()
+
+	if child.Parent.SpanID() != parent.SpanContext.SpanID() || child.SpanContext.TraceID() != parent.SpanContext.TraceID() {
+		t.Errorf("the %s span is not a child of the %s span", child.Name, parent.Name)
+	}
+}
+
+func TestCommandIsTracedFromSlackToApplelink(t *testing.T) {
+	spans := recordSpans(t)
+
+	var traceparents []string
+	applelink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		w.Write([]byte(`{"id": "app-1", "name": "Ciderbot Demo"}`))
+	}))
+	t.Cleanup(applelink.Close)
+	responseURL, _ := fakeResponseURL(t)
+
+	host, credentials := applelinkHost, applelinkCredentials
+	applelinkHost, applelinkCredentials = applelink.URL, &types.ApplelinkCredentials{Secret: "secret"}
+	t.Cleanup(func() { applelinkHost, applelinkCredentials = host, credentials })
+
+	storeCredentials := testAppStoreConnectStore(t).credentials
+	stores := func(user *types.User) StoreClient { return applelinkStore{credentials: storeCredentials} }
+	router, repos := testRouterWithStores(t, stores)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, signedSlackCommand("app_info", "T0001"))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("the command was answered with %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	// What the worker does with the command it claims
+	job, err := repos.Commands.ClaimNext(time.Now().UTC())
+	if err != nil || job == nil {
+		t.Fatalf("no command was queued (%v)", err)
+	}
+	job.ResponseURL = responseURL.URL
+	runCommandJob(withTraceParent(context.Background(), job.TraceParent), repos, stores, job)
+
+	recorded := spans()
+	request := findSpan(t, recorded, func(span tracetest.SpanStub) bool {
+		return span.SpanKind == trace.SpanKindServer && strings.Contains(span.Name, "/slack/listen")
+	}, "/slack/listen request")
+	command := findSpan(t, recorded, func(span tracetest.SpanStub) bool { return span.Name == "command app_info" }, "command")
+	execute := findSpan(t, recorded, func(span tracetest.SpanStub) bool { return span.Name == "command.execute" }, "command.execute")
+	applelinkCall := findSpan(t, recorded, func(span tracetest.SpanStub) bool {
+		return span.SpanKind == trace.SpanKindClient && strings.HasPrefix(span.Name, "applelink ")
+	}, "applelink client")
+	deliver := findSpan(t, recorded, func(span tracetest.SpanStub) bool { return span.Name == "slack.deliver" }, "slack.deliver")
+
+	assertChildOf(t, command, request)
+	assertChildOf(t, execute, command)
+	assertChildOf(t, applelinkCall, execute)
+	assertChildOf(t, deliver, command)
+
+	// applelink continues the trace from the header
+	want := "00-" + applelinkCall.SpanContext.TraceID().String() + "-" + applelinkCall.SpanContext.SpanID().String() + "-01"
+	if len(traceparents) != 1 || traceparents[0] != want {
+		t.Errorf("applelink got the traceparent %v, want %s", traceparents, want)
+	}
+}
@@ -2,28 +2,42 @@ package types
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 )
 
 type User struct {
-	Email             string `gorm:"primary_key"`
-	ProviderID        string `gorm:"index:idx_name,unique"`
-	Provider          string
-	Name              sql.NullString
-	AvatarURL         sql.NullString
-	SlackAccessToken  sql.NullString
-	SlackRefreshToken sql.NullString
-	SlackTeamID       sql.NullString
-	SlackTeamName     sql.NullString
-	AppStoreBundleID  sql.NullString
-	AppStoreIssuerID  sql.NullString
-	AppStoreKeyID     sql.NullString
-	AppStoreConnected bool `gorm:"default:false"`
-	AppStoreP8File    []byte
-	AppStoreP8FileIV  []byte
-	CommandCount      int64
-	CreatedAt         time.Time `gorm:"autoCreateTime"`
-	UpdatedAt         time.Time `gorm:"autoUpdateTime"`
+	Email                string `gorm:"primary_key"`
+	ProviderID           string `gorm:"index:idx_name,unique"`
+	Provider             string
+	Name                 sql.NullString
+	AvatarURL            sql.NullString
+	SlackAccessToken     sql.NullString
+	SlackRefreshToken    sql.NullString
+	SlackTeamID          sql.NullString
+	SlackTeamName        sql.NullString
+	SlackScopes          sql.NullString
+	AppStoreBundleID     sql.NullString
+	AppStoreIssuerID     sql.NullString
+	AppStoreKeyID        sql.NullString
+	AppStoreVendorNumber sql.NullString
+	AppStoreConnected    bool `gorm:"default:false"`
+	AppStoreP8File       []byte
+	AppStoreP8FileIV     []byte
+	PlayPackageName      sql.NullString
+	PlayConnected        bool `gorm:"default:false"`
+	PlayServiceAccount   []byte
+	PlayServiceAccountIV []byte
+	Platform             string `gorm:"default:ios"`
+	CommandCount         int64
+	CreatedAt            time.Time `gorm:"autoCreateTime"`
+	UpdatedAt            time.Time `gorm:"autoUpdateTime"`
+}
+
+type SchemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time `gorm:"autoCreateTime"`
 }
 
 type Metrics struct {
@@ -31,6 +45,136 @@ type Metrics struct {
 	DeletedUsers int64
 }
 
+type AuditEvent struct {
+	ID          uint `gorm:"primary_key"`
+	ActorEmail  string
+	SlackTeamID string `gorm:"index"`
+	Action      string
+	Detail      string
+	CreatedAt   time.Time `gorm:"autoCreateTime;index"`
+}
+
+type ScheduledJob struct {
+	ID             uint   `gorm:"primary_key"`
+	Kind           string `gorm:"index:idx_scheduled_job_owner"`
+	SlackTeamID    string `gorm:"index:idx_scheduled_job_owner"`
+	SlackChannelID string `gorm:"index:idx_scheduled_job_owner"`
+	Schedule       string
+	Timezone       string
+	Payload        string
+	State          string
+	NextRunAt      time.Time `gorm:"index"`
+	LastRunAt      sql.NullTime
+	LockVersion    int64
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
+}
+
+type CommandJob struct {
+	ID            uint   `gorm:"primary_key"`
+	SlackTeamID   string `gorm:"index"`
+	Command       string
+	Form          string
+	ResponseURL   string
+	RequestID     string
+	TraceParent   string
+	Status        string `gorm:"index"`
+	Attempts      int
+	NextAttemptAt time.Time `gorm:"index"`
+	LockedUntil   sql.NullTime
+	ExpiresAt     time.Time
+	Response      string
+	LastError     string
+	LockVersion   int64
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
+
+type SlackResponseURL struct {
+	ID        uint   `gorm:"primary_key"`
+	URLHash   string `gorm:"uniqueIndex"`
+	Uses      int
+	CreatedAt time.Time `gorm:"autoCreateTime;index"`
+}
+
+type SlackDeliveryFailure struct {
+	ID             uint   `gorm:"primary_key"`
+	SlackTeamID    string `gorm:"index"`
+	SlackChannelID string
+	Method         string
+	StatusCode     int
+	Error          string
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
+
+type PostedReview struct {
+	ID             uint   `gorm:"primary_key"`
+	SlackTeamID    string `gorm:"uniqueIndex:idx_posted_review"`
+	SlackChannelID string `gorm:"uniqueIndex:idx_posted_review"`
+	ReviewID       string `gorm:"uniqueIndex:idx_posted_review"`
+	RepliedBy      sql.NullString
+	RepliedAt      sql.NullTime
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
+}
+
+// ReleaseRequest claims the release of a version, so that it is released once
+// however many times its confirmation is clicked.
+type ReleaseRequest struct {
+	ID            uint   `gorm:"primary_key"`
+	SlackTeamID   string `gorm:"uniqueIndex:idx_release_request"`
+	VersionString string `gorm:"uniqueIndex:idx_release_request"`
+	RequestedBy   string
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+}
+
+type ReviewRating struct {
+	ID          uint   `gorm:"primary_key"`
+	SlackTeamID string `gorm:"uniqueIndex:idx_review_rating"`
+	ReviewID    string `gorm:"uniqueIndex:idx_review_rating"`
+	Territory   string
+	Rating      int
+	CreatedDate time.Time
+}
+
+type RatingSnapshot struct {
+	ID            uint      `gorm:"primary_key"`
+	SlackTeamID   string    `gorm:"uniqueIndex:idx_rating_snapshot"`
+	Territory     string    `gorm:"uniqueIndex:idx_rating_snapshot"`
+	CapturedOn    time.Time `gorm:"uniqueIndex:idx_rating_snapshot"`
+	ReviewCount   int64
+	AverageRating float64
+}
+
+type SalesReportDay struct {
+	ID          uint      `gorm:"primary_key"`
+	SlackTeamID string    `gorm:"uniqueIndex:idx_sales_report_day"`
+	ReportDate  time.Time `gorm:"uniqueIndex:idx_sales_report_day"`
+	RowCount    int
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+// SalesReportRow is a line of a daily Sales and Trends summary report, only
+// the columns we aggregate on are kept.
+type SalesReportRow struct {
+	ID                    uint      `gorm:"primary_key"`
+	SlackTeamID           string    `gorm:"index:idx_sales_report_row"`
+	ReportDate            time.Time `gorm:"index:idx_sales_report_row"`
+	Sku                   string
+	Title                 string
+	Version               string
+	ProductTypeIdentifier string
+	Units                 int
+	DeveloperProceeds     float64
+	CurrencyOfProceeds    string
+	CustomerPrice         float64
+	CustomerCurrency      string
+	CountryCode           string
+	AppleIdentifier       string
+	ParentIdentifier      string
+	Device                string
+}
+
 type AppleCredentials struct {
 	BundleID string
 	IssuerID string
@@ -38,6 +182,11 @@ type AppleCredentials struct {
 	P8File   []byte
 }
 
+type PlayCredentials struct {
+	PackageName        string
+	ServiceAccountJSON []byte
+}
+
 type ApplelinkCredentials struct {
 	Aud    string
 	Issuer string
@@ -72,33 +221,90 @@ type BetaGroup struct {
 	} `json:"testers"`
 }
 
+type Build struct {
+	Id              string    `json:"id"`
+	BuildNumber     string    `json:"build_number"`
+	VersionString   string    `json:"version_string"`
+	ProcessingState string    `json:"processing_state"`
+	UploadedDate    time.Time `json:"uploaded_date"`
+	ExpirationDate  time.Time `json:"expiration_date"`
+	Expired         bool      `json:"expired"`
+	MinOsVersion    string    `json:"min_os_version"`
+	BetaGroups      []string  `json:"beta_groups"`
+	AppStoreVersion string    `json:"app_store_version"`
+}
+
 type Release struct {
-	Id                  string      `json:"id"`
-	VersionName         string      `json:"version_name"`
-	AppStoreState       string      `json:"app_store_state"`
-	ReleaseType         string      `json:"release_type"`
-	EarliestReleaseDate interface{} `json:"earliest_release_date"`
-	Downloadable        bool        `json:"downloadable"`
-	CreatedDate         time.Time   `json:"created_date"`
-	BuildNumber         string      `json:"build_number"`
-	BuildId             string      `json:"build_id"`
+	Id                  string     `json:"id"`
+	VersionName         string     `json:"version_name"`
+	AppStoreState       string     `json:"app_store_state"`
+	ReleaseType         string     `json:"release_type"`
+	EarliestReleaseDate *time.Time `json:"earliest_release_date"`
+	Downloadable        bool       `json:"downloadable"`
+	CreatedDate         time.Time  `json:"created_date"`
+	BuildNumber         string     `json:"build_number"`
+	BuildId             string     `json:"build_id"`
 	PhasedRelease       struct {
 		Id                 string    `json:"id"`
 		PhasedReleaseState string    `json:"phased_release_state"`
 		StartDate          time.Time `json:"start_date"`
 		TotalPauseDuration int       `json:"total_pause_duration"`
 		CurrentDayNumber   int       `json:"current_day_number"`
+		UserFraction       float64   `json:"user_fraction,omitempty"`
 	} `json:"phased_release"`
-	Details struct {
-		Id              string      `json:"id"`
-		Description     string      `json:"description"`
-		Locale          string      `json:"locale"`
-		Keywords        string      `json:"keywords"`
-		MarketingUrl    interface{} `json:"marketing_url"`
-		PromotionalText interface{} `json:"promotional_text"`
-		SupportUrl      string      `json:"support_url"`
-		WhatsNew        string      `json:"whats_new"`
-	} `json:"details"`
+	Details Localization `json:"details"`
+}
+
+type Localization struct {
+	Id              string `json:"id"`
+	Description     string `json:"description"`
+	Locale          string `json:"locale"`
+	Keywords        string `json:"keywords"`
+	MarketingUrl    string `json:"marketing_url"`
+	PromotionalText string `json:"promotional_text"`
+	SupportUrl      string `json:"support_url"`
+	WhatsNew        string `json:"whats_new"`
+}
+
+type ReviewSubmission struct {
+	Id            string    `json:"id"`
+	State         string    `json:"state"`
+	SubmittedDate time.Time `json:"submitted_date"`
+	Items         []struct {
+		Id            string `json:"id"`
+		State         string `json:"state"`
+		Type          string `json:"type"`
+		VersionString string `json:"version_string"`
+	} `json:"items"`
+}
+
+type ResolutionCenterMessage struct {
+	Id               string    `json:"id"`
+	Body             string    `json:"body"`
+	CreatedDate      time.Time `json:"created_date"`
+	RejectionReasons []struct {
+		Code        string `json:"code"`
+		Section     string `json:"section"`
+		Description string `json:"description"`
+	} `json:"rejection_reasons"`
+}
+
+type CustomerReview struct {
+	Id               string                  `json:"id"`
+	Rating           int                     `json:"rating"`
+	Title            string                  `json:"title"`
+	Body             string                  `json:"body"`
+	ReviewerNickname string                  `json:"reviewer_nickname"`
+	Territory        string                  `json:"territory"`
+	CreatedDate      time.Time               `json:"created_date"`
+	Response         *CustomerReviewResponse `json:"response"`
+}
+
+type CustomerReviewResponse struct {
+	Id               string    `json:"id"`
+	Body             string    `json:"response_body"`
+	State            string    `json:"state"`
+	LastModifiedDate time.Time `json:"last_modified_date"`
 }
 
 type SlackFormData struct {
@@ -118,16 +324,81 @@ type SlackFormData struct {
 	ApiAppId       string `form:"api_app_id"`
 }
 
+type SlackMessage struct {
+	Channel string  `json:"channel"`
+	User    string  `json:"user,omitempty"`
+	Text    string  `json:"text,omitempty"`
+	Blocks  []Block `json:"blocks"`
+}
+
+type SlackAPIResponse struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type SlackInteraction struct {
+	Type string `json:"type"`
+	Team struct {
+		Id     string `json:"id"`
+		Domain string `json:"domain"`
+	} `json:"team"`
+	User struct {
+		Id       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Channel struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channel"`
+	Token       string        `json:"token"`
+	TriggerId   string        `json:"trigger_id"`
+	ResponseUrl string        `json:"response_url"`
+	Actions     []SlackAction `json:"actions"`
+	View        SlackView     `json:"view"`
+}
+
+type SlackView struct {
+	Id              string          `json:"id,omitempty"`
+	Type            string          `json:"type"`
+	CallbackId      string          `json:"callback_id,omitempty"`
+	PrivateMetadata string          `json:"private_metadata,omitempty"`
+	Title           *Text           `json:"title,omitempty"`
+	Submit          *Text           `json:"submit,omitempty"`
+	Close           *Text           `json:"close,omitempty"`
+	Blocks          []Block         `json:"blocks,omitempty"`
+	State           *SlackViewState `json:"state,omitempty"`
+}
+
+type SlackViewState struct {
+	Values map[string]map[string]SlackAction `json:"values"`
+}
+
+type SlackAction struct {
+	ActionId       string  `json:"action_id"`
+	BlockId        string  `json:"block_id"`
+	Value          string  `json:"value"`
+	SelectedOption *Option `json:"selected_option,omitempty"`
+}
+
+type Option struct {
+	Text  Text   `json:"text"`
+	Value string `json:"value"`
+}
+
 type SlackResponse struct {
-	Blocks       []Block `json:"blocks"`
-	ResponseType string  `json:"response_type"`
+	Blocks          []Block `json:"blocks"`
+	ResponseType    string  `json:"response_type"`
+	ReplaceOriginal bool    `json:"replace_original,omitempty"`
 }
 
 type Block struct {
 	Type     string    `json:"type"`
+	BlockId  string    `json:"block_id,omitempty"`
 	Text     *Text     `json:"text,omitempty"`
 	Fields   []Text    `json:"fields,omitempty"`
 	Elements []Element `json:"elements,omitempty"`
+	Label    *Text     `json:"label,omitempty"`
+	Element  *Element  `json:"element,omitempty"`
 }
 
 type Text struct {
@@ -137,9 +408,35 @@ type Text struct {
 }
 
 type Element struct {
-	Type     string `json:"type"`
-	ImageURL string `json:"image_url,omitempty"`
-	AltText  string `json:"alt_text,omitempty"`
-	Text     string `json:"text,omitempty"`
-	Emoji    bool   `json:"emoji,omitempty"`
+	Type        string   `json:"type"`
+	ImageURL    string   `json:"image_url,omitempty"`
+	AltText     string   `json:"alt_text,omitempty"`
+	Text        string   `json:"text,omitempty"`
+	Emoji       bool     `json:"emoji,omitempty"`
+	ActionId    string   `json:"action_id,omitempty"`
+	Value       string   `json:"value,omitempty"`
+	Style       string   `json:"style,omitempty"`
+	Multiline   bool     `json:"multiline,omitempty"`
+	MaxLength   int      `json:"max_length,omitempty"`
+	Placeholder *Text    `json:"placeholder,omitempty"`
+	Options     []Option `json:"options,omitempty"`
+}
+
+// MarshalJSON renders the text of interactive elements as a plain text
+// object, which is what Slack expects for them unlike context elements.
+func (e Element) MarshalJSON() ([]byte, error) {
+	type element Element
+
+	if e.Type != "button" {
+		return json.Marshal(element(e))
+	}
+
+	return json.Marshal(struct {
+		element
+		Text  Text `json:"text"`
+		Emoji bool `json:"emoji,omitempty"`
+	}{
+		element: element(e),
+		Text:    Text{Type: "plain_text", Text: e.Text, Emoji: e.Emoji},
+	})
 }
@@ -0,0 +1,240 @@
+package main
+
+import (
+	slack "ciderbot/slack"
+	"ciderbot/types"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var releaseTypes = map[string]string{
+	"manual":         "MANUAL",
+	"after_approval": "AFTER_APPROVAL",
+	"scheduled":      "SCHEDULED",
+}
+
+// Versions that are still being worked on, an app can only have one of these at a time.
+var editableStoreStates = map[string]bool{
+	"PREPARE_FOR_SUBMISSION":    true,
+	"DEVELOPER_REJECTED":        true,
+	"REJECTED":                  true,
+	"METADATA_REJECTED":         true,
+	"WAITING_FOR_REVIEW":        true,
+	"IN_REVIEW":                 true,
+	"INVALID_BINARY":            true,
+	"PENDING_DEVELOPER_RELEASE": true,
+}
+
+// Versions that have not been submitted to App Review, only these can change their build and release settings.
+var unsubmittedStoreStates = map[string]bool{
+	"PREPARE_FOR_SUBMISSION": true,
+	"DEVELOPER_REJECTED":     true,
+	"REJECTED":               true,
+	"METADATA_REJECTED":      true,
+	"INVALID_BINARY":         true,
+}
+
+func handleCreateVersionCommand(ctx context.Context, form types.SlackFormData, store StoreClient) types.SlackResponse {
+	flags, args := commandFlags(commandArgs(form.Text))
+	if len(args) == 0 {
+		return slack.EphemeralMessage{Msg: "Please provide a version, e.g. `create_version 1.2.0 --release-type after_approval --phased`."}.Render()
+	}
+
+	versionString := args[0]
+	version, err := parseVersion(versionString)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("`%s` is not a valid version, it should look like `1.2.0`.", versionString)}.Render()
+	}
+
+	releaseType := releaseTypes["after_approval"]
+	if value, ok := flags["release-type"]; ok {
+		releaseType, ok = releaseTypes[value]
+		if !ok {
+			return slack.EphemeralMessage{Msg: "The release type should be one of `manual`, `after_approval` or `scheduled`."}.Render()
+		}
+	}
+	_, phasedRelease := flags["phased"]
+
+	var earliestReleaseDate *time.Time
+	if value, ok := flags["release-date"]; ok {
+		if releaseType != releaseTypes["scheduled"] {
+			return slack.EphemeralMessage{Msg: "Only a `scheduled` release takes a date."}.Render()
+		}
+
+		releaseDate, err := parseFutureReleaseDate(value)
+		if err != nil {
+			return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not schedule the release: %s.", err)}.Render()
+		}
+		earliestReleaseDate = &releaseDate
+	} else if releaseType == releaseTypes["scheduled"] {
+		return slack.EphemeralMessage{Msg: "Please provide the date of a scheduled release, e.g. `create_version 1.2.0 --release-type scheduled --release-date \"tomorrow 9am PT\"`."}.Render()
+	}
+
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find your app."}.Render()
+	}
+
+	inflightRelease, err := store.InflightRelease(ctx)
+	if err == nil && editableStoreStates[inflightRelease.AppStoreState] {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("*%s* is already inflight with the status `%s`.", inflightRelease.VersionName, inflightRelease.AppStoreState)}.Render()
+	}
+
+	liveRelease, err := store.LiveRelease(ctx)
+	if err == nil && liveRelease.VersionName != "" {
+		liveVersion, err := parseVersion(liveRelease.VersionName)
+		if err == nil && compareVersions(version, liveVersion) <= 0 {
+			return slack.EphemeralMessage{Msg: fmt.Sprintf("The new version should be higher than the live version *%s*.", liveRelease.VersionName)}.Render()
+		}
+	}
+
+	newRelease, err := store.CreateVersion(ctx, versionString, releaseType, earliestReleaseDate, phasedRelease)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not create the version *%s*.", versionString)}.Render()
+	}
+
+	return toInflightRelease(appInfo.Id, newRelease).Render()
+}
+
+func handleReleaseTypeCommand(ctx context.Context, form types.SlackFormData, store StoreClient) types.SlackResponse {
+	args := commandArgs(form.Text)
+	if len(args) == 0 {
+		return slack.EphemeralMessage{Msg: "Please provide a release type, e.g. `release_type manual`, `release_type after_approval` or `release_type scheduled tomorrow 9am PT`."}.Render()
+	}
+
+	releaseType, ok := releaseTypes[args[0]]
+	if !ok {
+		return slack.EphemeralMessage{Msg: "The release type should be one of `manual`, `after_approval` or `scheduled`."}.Render()
+	}
+
+	changes := map[string]interface{}{"release_type": releaseType}
+	if releaseType != releaseTypes["scheduled"] {
+		if len(args) > 1 {
+			return slack.EphemeralMessage{Msg: "Only a `scheduled` release takes a date."}.Render()
+		}
+
+		// App Store Connect only keeps a date for scheduled releases
+		changes["earliest_release_date"] = nil
+		return updateReleaseSettings(ctx, store, changes)
+	}
+
+	if len(args) > 1 {
+		releaseDate, err := parseFutureReleaseDate(strings.Join(args[1:], " "))
+		if err != nil {
+			return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not schedule the release: %s.", err)}.Render()
+		}
+		changes["earliest_release_date"] = releaseDate.UTC().Format(time.RFC3339)
+	}
+
+	return updateReleaseSettings(ctx, store, changes)
+}
+
+func handleReleaseDateCommand(ctx context.Context, form types.SlackFormData, store StoreClient) types.SlackResponse {
+	args := commandArgs(form.Text)
+	if len(args) == 0 {
+		return slack.EphemeralMessage{Msg: "Please provide a date, e.g. `release_date tomorrow 9am PT` or `release_date 2023-06-01 10:00 Europe/Berlin`."}.Render()
+	}
+
+	releaseDate, err := parseFutureReleaseDate(strings.Join(args, " "))
+	if err != nil {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not schedule the release: %s.", err)}.Render()
+	}
+
+	return updateReleaseSettings(ctx, store, map[string]interface{}{
+		"release_type":          releaseTypes["scheduled"],
+		"earliest_release_date": releaseDate.UTC().Format(time.RFC3339),
+	})
+}
+
+func handlePhasedReleaseCommand(ctx context.Context, form types.SlackFormData, store StoreClient) types.SlackResponse {
+	args := commandArgs(form.Text)
+	if len(args) == 0 || (args[0] != "on" && args[0] != "off") {
+		return slack.EphemeralMessage{Msg: "Please use `phased_release on` or `phased_release off`."}.Render()
+	}
+
+	return updateReleaseSettings(ctx, store, map[string]interface{}{"is_phased_release": args[0] == "on"})
+}
+
+func updateReleaseSettings(ctx context.Context, store StoreClient, changes map[string]interface{}) types.SlackResponse {
+	appInfo, err := store.AppMetadata(ctx)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find your app."}.Render()
+	}
+
+	inflightRelease, err := store.InflightRelease(ctx)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: "Could not find an inflight release for your app."}.Render()
+	}
+
+	if !unsubmittedStoreStates[inflightRelease.AppStoreState] {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("The release settings of *%s* can not be changed while it is `%s`.", inflightRelease.VersionName, inflightRelease.AppStoreState)}.Render()
+	}
+
+	if changes["release_type"] == releaseTypes["scheduled"] && changes["earliest_release_date"] == nil && inflightRelease.EarliestReleaseDate == nil {
+		return slack.EphemeralMessage{Msg: "A scheduled release needs a date, e.g. `release_type scheduled tomorrow 9am PT`."}.Render()
+	}
+
+	updatedRelease, err := store.UpdateInflightRelease(ctx, changes)
+	if err != nil {
+		return slack.EphemeralMessage{Msg: fmt.Sprintf("Could not update the release settings of *%s*.", inflightRelease.VersionName)}.Render()
+	}
+
+	return toInflightRelease(appInfo.Id, updatedRelease).Render()
+}
+
+// parseFutureReleaseDate reads a release date that App Store Connect accepts,
+// which is in the future and on the hour.
+func parseFutureReleaseDate(text string) (time.Time, error) {
+	releaseDate, err := parseReleaseDate(text, time.Now())
+	if err != nil {
+		return releaseDate, err
+	}
+
+	if !releaseDate.After(time.Now()) {
+		return releaseDate, fmt.Errorf("*%s* is in the past", releaseDate.Format(time.RFC850))
+	}
+
+	// App Store Connect releases on the hour in UTC, which is not the hour of
+	// zones with a half hour offset
+	if releaseDate.UTC().Minute() != 0 {
+		return releaseDate, fmt.Errorf("releases can only be scheduled on the hour in UTC")
+	}
+
+	return releaseDate, nil
+}
+
+// parseVersion reads the up to three period-separated integers of an App Store version string.
+func parseVersion(versionString string) ([3]int, error) {
+	var version [3]int
+
+	parts := strings.Split(versionString, ".")
+	if len(parts) > len(version) {
+		return version, fmt.Errorf("version %s has too many parts", versionString)
+	}
+
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return version, fmt.Errorf("version %s has an invalid part %s", versionString, part)
+		}
+		version[i] = number
+	}
+
+	return version, nil
+}
+
+func compareVersions(a [3]int, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}